@@ -2,40 +2,222 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"log"
+	"strconv"
 
 	"insider/models"
 
 	_ "github.com/mattn/go-sqlite3" // SQLite driver
 )
 
+var (
+	// ErrNotFound is returned when a requested record does not exist
+	ErrNotFound = errors.New("record not found")
+	// ErrConflict is returned when a record changed between being read and being written
+	ErrConflict = errors.New("record changed concurrently")
+)
+
+// Database gives access to the data of a single league, see ForLeague.
+// League management methods are not scoped and work from any handle.
 type Database interface {
 	Initialize()
 	Close() error
 
+	ForLeague(leagueID int) Database
+	// Transaction runs fn against a handle whose changes are committed together once fn returns nil,
+	// or not at all. Inside fn only that handle may be used.
+	Transaction(fn func(tx Database) error) error
+
+	GetLeagues() ([]models.League, error)
+	GetLeague(leagueID int) (*models.League, error)
+	CreateLeague(name string, teamIDs []int, rules models.LeagueRules) (int, error)
+	DeleteLeague(leagueID int) error
+	GetMatchLeague(matchID int) (int, error)
+
+	GetPyramids() ([]models.Pyramid, error)
+	GetPyramid(pyramidID int) (*models.Pyramid, error)
+	// GetLeaguePyramid returns the pyramid a league is a division of, ErrNotFound if it stands alone
+	GetLeaguePyramid(leagueID int) (*models.Pyramid, error)
+	CreatePyramid(pyramid models.Pyramid) (int, error)
+	DeletePyramid(pyramidID int) error
+
+	GetAllTeams() ([]models.Team, error)
+	GetTeam(teamID int) (*models.Team, error)
+	InsertTeam(team models.Team) (int, error)
+	UpdateTeam(team models.Team) error
+	DeleteTeam(teamID int) error
+
 	GetTeams() ([]models.Team, error)
+	SetTeams(teamIDs []int) error
+	UpdateRules(rules models.LeagueRules) error
+
 	GetMatches() ([]models.Match, error)
 	GetMatchesForWeek(week int) ([]models.Match, error)
 	GetSimulationState() (*models.SimulationState, error)
+	GetDeductions() ([]models.PointDeduction, error)
 
 	InsertMatches(matches []models.Match) error
 
-	UpdateMatchResult(matchID int, result models.MatchResult) error
-	UpdateCurrentWeek(week int) error
+	UpdateMatchResult(matchID int, result models.MatchResult, origin models.Origin) error
+	ClearMatchResult(matchID int) error
+	ClearResultsAfterWeek(week int) error
+	// UpdateCurrentWeek moves the league from week from to week to, failing with ErrConflict if it is no longer at from
+	UpdateCurrentWeek(from, to int) error
+	UpdateMaxWeeks(weeks int) error
+
+	InsertDeduction(deduction models.PointDeduction) (int, error)
+	DeleteDeduction(deductionID int) error
+
+	// The audit log is append-only
+	InsertEvent(event models.Event) error
+	// GetEvents returns a page of the audit log, newest first, with the number of events in total.
+	// A match ID of 0 selects every event.
+	GetEvents(matchID, limit, offset int) ([]models.Event, int, error)
+	// GetSeasonEvents returns the events of the current season, oldest first
+	GetSeasonEvents() ([]models.Event, error)
+
+	// A season is archived under its number, archiving it again replaces the archive
+	SaveSeason(season models.Season) error
+	GetSeasons() ([]models.Season, error)
+	GetSeason(number int) (*models.Season, error)
+	DeleteSeason(number int) error
+
+	// GetRatings returns the rating history of the season by week, then team
+	GetRatings() ([]models.Rating, error)
+	// SaveRatings replaces the rating history of the season
+	SaveRatings(ratings []models.Rating) error
+
+	// The undo step is single, saving one replaces the previous
+	GetUndo() (*models.UndoStep, error)
+	SaveUndo(step models.UndoStep) error
+	DeleteUndo() error
 
-	ResetSimulation() error
+	// ResetSimulation starts the given season afresh, deleting its matches and everything that belongs to them
+	ResetSimulation(seed int64, season int) error
 }
 
 type SQLiteDatabase struct {
-	db   *sql.DB
-	path string
+	db       *sql.DB
+	tx       *sql.Tx // set on the handles of a Transaction
+	path     string
+	leagueID int
 }
 
+// querier runs statements on the connection pool or, inside a Transaction, on the transaction
+type querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// transaction is a transaction of a single method, which joins an enclosing Transaction if there is one
+type transaction struct {
+	*sql.Tx
+	joined bool
+}
+
+func (t *transaction) Commit() error {
+	if t.joined {
+		return nil
+	}
+	return t.Tx.Commit()
+}
+
+func (t *transaction) Rollback() error {
+	if t.joined {
+		return nil
+	}
+	return t.Tx.Rollback()
+}
+
+// NewSQLiteDatabase returns a handle scoped to the default league
 func NewSQLiteDatabase(databasePath string) *SQLiteDatabase {
 	return &SQLiteDatabase{
-		db:   nil,
-		path: databasePath,
+		db:       nil,
+		path:     databasePath,
+		leagueID: models.DefaultLeagueID,
+	}
+}
+
+// ForLeague returns a handle scoped to the given league.
+// It shares the connection pool with its parent, so it must not be closed separately.
+func (sqlite *SQLiteDatabase) ForLeague(leagueID int) Database {
+	return &SQLiteDatabase{
+		db:       sqlite.db,
+		tx:       sqlite.tx,
+		path:     sqlite.path,
+		leagueID: leagueID,
+	}
+}
+
+func (sqlite *SQLiteDatabase) Transaction(fn func(tx Database) error) error {
+	if sqlite.tx != nil {
+		return fn(sqlite)
+	}
+
+	tx, err := sqlite.db.Begin()
+	if err != nil {
+		log.Printf("Failed to begin transaction: %v", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	handle := &SQLiteDatabase{
+		db:       sqlite.db,
+		tx:       tx,
+		path:     sqlite.path,
+		leagueID: sqlite.leagueID,
+	}
+	if err := fn(handle); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (sqlite *SQLiteDatabase) conn() querier {
+	if sqlite.tx != nil {
+		return sqlite.tx
 	}
+	return sqlite.db
+}
+
+func (sqlite *SQLiteDatabase) begin() (*transaction, error) {
+	if sqlite.tx != nil {
+		return &transaction{Tx: sqlite.tx, joined: true}, nil
+	}
+
+	tx, err := sqlite.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &transaction{Tx: tx}, nil
+}
+
+// schemaVersion is the version of the schema Initialize leaves a database at, kept in PRAGMA user_version.
+// Databases of version 0 were created before versions were kept, by any earlier build, or are new.
+const schemaVersion int = 8
+
+// addedColumns are the columns added to tables after they were first created, which CREATE TABLE IF NOT
+// EXISTS does not add to a database created before them. Matches and the state of a database from before
+// leagues belong to the default league.
+var addedColumns = []struct {
+	table, column, definition string
+}{
+	{"simulation_state", "seed", "INTEGER NOT NULL DEFAULT 0"},
+	{"simulation_state", "season", "INTEGER NOT NULL DEFAULT 1"},
+	{"leagues", "rules", "TEXT NOT NULL DEFAULT '{}'"},
+	{"matches", "league_id", "INTEGER NOT NULL DEFAULT 1"},
+	{"matches", "home_discipline", "INTEGER NOT NULL DEFAULT 0"},
+	{"matches", "away_discipline", "INTEGER NOT NULL DEFAULT 0"},
+	{"matches", "home_penalties", "INTEGER NOT NULL DEFAULT 0"},
+	{"matches", "away_penalties", "INTEGER NOT NULL DEFAULT 0"},
+	{"matches", "origin", "TEXT NOT NULL DEFAULT ''"},
+	// Deductions made before their week was kept count from the start of the season
+	{"point_deductions", "week", "INTEGER NOT NULL DEFAULT 0"},
+	{"events", "origin", "TEXT NOT NULL DEFAULT ''"},
+	{"events", "rules", "TEXT"},
 }
 
 func (sqlite *SQLiteDatabase) Initialize() {
@@ -44,6 +226,9 @@ func (sqlite *SQLiteDatabase) Initialize() {
 		log.Fatalf("Failed to open database: %v", err)
 	}
 
+	// SQLite has a single writer. One connection queues transactions instead of failing them as busy,
+	// and keeps an in-memory database the same database for every statement.
+	db.SetMaxOpenConns(1)
 	sqlite.db = db
 
 	const createTablesQuery string = `
@@ -57,71 +242,674 @@ func (sqlite *SQLiteDatabase) Initialize() {
 		play_style TEXT NOT NULL DEFAULT 'balanced'
 	);
 	
+	CREATE TABLE IF NOT EXISTS leagues (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		rules TEXT NOT NULL DEFAULT '{}'
+	);
+
+	CREATE TABLE IF NOT EXISTS league_teams (
+		league_id INTEGER NOT NULL,
+		team_id INTEGER NOT NULL,
+		PRIMARY KEY (league_id, team_id),
+		FOREIGN KEY (league_id) REFERENCES leagues(id),
+		FOREIGN KEY (team_id) REFERENCES teams(id)
+	);
+
 	CREATE TABLE IF NOT EXISTS matches (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		league_id INTEGER NOT NULL,
 		week INTEGER NOT NULL,
 		home_team_id INTEGER NOT NULL,
 		away_team_id INTEGER NOT NULL,
 		home_score INTEGER,
 		away_score INTEGER,
 		is_played BOOLEAN NOT NULL DEFAULT FALSE,
+		home_discipline INTEGER NOT NULL DEFAULT 0,
+		away_discipline INTEGER NOT NULL DEFAULT 0,
+		home_penalties INTEGER NOT NULL DEFAULT 0,
+		away_penalties INTEGER NOT NULL DEFAULT 0,
+		origin TEXT NOT NULL DEFAULT '',
+		FOREIGN KEY (league_id) REFERENCES leagues(id),
 		FOREIGN KEY (home_team_id) REFERENCES teams(id),
 		FOREIGN KEY (away_team_id) REFERENCES teams(id)
 	);
 
+	CREATE TABLE IF NOT EXISTS point_deductions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		league_id INTEGER NOT NULL,
+		team_id INTEGER NOT NULL,
+		points INTEGER NOT NULL,
+		reason TEXT NOT NULL,
+		week INTEGER NOT NULL DEFAULT 0,
+		FOREIGN KEY (league_id) REFERENCES leagues(id),
+		FOREIGN KEY (team_id) REFERENCES teams(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		league_id INTEGER NOT NULL,
+		type TEXT NOT NULL,
+		source TEXT NOT NULL,
+		week INTEGER NOT NULL,
+		match_id INTEGER,
+		old_result TEXT,
+		new_result TEXT,
+		origin TEXT NOT NULL DEFAULT '',
+		new_week INTEGER,
+		seed INTEGER,
+		rules TEXT,
+		created_at DATETIME NOT NULL,
+		FOREIGN KEY (league_id) REFERENCES leagues(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS seasons (
+		league_id INTEGER NOT NULL,
+		season INTEGER NOT NULL,
+		data TEXT NOT NULL,
+		PRIMARY KEY (league_id, season),
+		FOREIGN KEY (league_id) REFERENCES leagues(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS ratings (
+		league_id INTEGER NOT NULL,
+		week INTEGER NOT NULL,
+		team_id INTEGER NOT NULL,
+		rating REAL NOT NULL,
+		PRIMARY KEY (league_id, week, team_id),
+		FOREIGN KEY (league_id) REFERENCES leagues(id),
+		FOREIGN KEY (team_id) REFERENCES teams(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS league_undo (
+		league_id INTEGER PRIMARY KEY,
+		step TEXT NOT NULL,
+		FOREIGN KEY (league_id) REFERENCES leagues(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS pyramids (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		places INTEGER NOT NULL,
+		playoffs BOOLEAN NOT NULL DEFAULT FALSE
+	);
+
+	CREATE TABLE IF NOT EXISTS pyramid_divisions (
+		pyramid_id INTEGER NOT NULL,
+		level INTEGER NOT NULL,
+		league_id INTEGER NOT NULL UNIQUE,
+		PRIMARY KEY (pyramid_id, level),
+		FOREIGN KEY (pyramid_id) REFERENCES pyramids(id),
+		FOREIGN KEY (league_id) REFERENCES leagues(id)
+	);
+
 	CREATE TABLE IF NOT EXISTS simulation_state (
-		id INTEGER PRIMARY KEY DEFAULT 1,
+		league_id INTEGER PRIMARY KEY,
 		current_week INTEGER NOT NULL DEFAULT 1,
-		max_weeks INTEGER NOT NULL DEFAULT 6
+		max_weeks INTEGER NOT NULL DEFAULT 6,
+		seed INTEGER NOT NULL DEFAULT 0,
+		season INTEGER NOT NULL DEFAULT 1,
+		FOREIGN KEY (league_id) REFERENCES leagues(id)
 	);
 	`
-	_, err = sqlite.db.Exec(createTablesQuery)
+	var version int
+	if err := sqlite.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		log.Fatalf("Failed to read schema version: %v", err)
+	}
+
+	// Creating, migrating and seeding commit together, so a failed start leaves the database as it was
+	tx, err := sqlite.db.Begin()
+	if err != nil {
+		log.Fatalf("Failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(createTablesQuery)
 	if err != nil {
 		log.Fatalf("Failed to create tables: %v", err)
 	}
+	if version >= schemaVersion {
+		if err := tx.Commit(); err != nil {
+			log.Fatalf("Failed to commit tables: %v", err)
+		}
+		return
+	}
+
+	if err := migrate(tx); err != nil {
+		log.Fatalf("Failed to migrate database from version %d: %v", version, err)
+	}
+	if version == 0 {
+		if err := seed(tx); err != nil {
+			log.Fatalf("Failed to seed database: %v", err)
+		}
+	}
+	if _, err := tx.Exec("PRAGMA user_version = " + strconv.Itoa(schemaVersion)); err != nil {
+		log.Fatalf("Failed to write schema version: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		log.Fatalf("Failed to commit migration: %v", err)
+	}
+}
+
+// migrate brings the tables of a database created by an earlier build up to the current schema. Tables it
+// did not have yet are created as they are now, columns added to older tables since are added here.
+func migrate(tx *sql.Tx) error {
+	columns := make(map[string]map[string]bool)
+	tableColumns := func(table string) (map[string]bool, error) {
+		if names, ok := columns[table]; ok {
+			return names, nil
+		}
+		rows, err := tx.Query("SELECT name FROM pragma_table_info(?)", table)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		names := make(map[string]bool)
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				return nil, err
+			}
+			names[name] = true
+		}
+		columns[table] = names
+		return names, rows.Err()
+	}
+
+	// Before leagues, the single simulation state was keyed by a constant id, the ID of the default league
+	state, err := tableColumns("simulation_state")
+	if err != nil {
+		log.Printf("Failed to read columns of simulation_state: %v", err)
+		return err
+	}
+	if state["id"] && !state["league_id"] {
+		if _, err := tx.Exec("ALTER TABLE simulation_state RENAME COLUMN id TO league_id"); err != nil {
+			log.Printf("Failed to migrate simulation_state: %v", err)
+			return err
+		}
+		delete(columns, "simulation_state")
+	}
 
+	for _, added := range addedColumns {
+		existing, err := tableColumns(added.table)
+		if err != nil {
+			log.Printf("Failed to read columns of %s: %v", added.table, err)
+			return err
+		}
+		if existing[added.column] {
+			continue
+		}
+		_, err = tx.Exec("ALTER TABLE " + added.table + " ADD COLUMN " + added.column + " " + added.definition)
+		if err != nil {
+			log.Printf("Failed to add %s.%s: %v", added.table, added.column, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// seed fills a database that has never been initialized with the initial teams and the default league. It
+// runs once, so teams renamed, deleted or moved out of the default league since stay that way.
+func seed(tx *sql.Tx) error {
+	// A database from before schema versions was seeded with the teams already
 	const insertTeamsQuery string = `
-	INSERT OR IGNORE INTO teams
-	(name, attack, defense, midfield, home_boost, play_style) VALUES
-	('Manchester City', 0.95, 0.85, 0.92, 0.42, 'possession'),
-	('Liverpool', 0.90, 0.80, 0.85, 0.45, 'attacking'),
-	('Arsenal', 0.85, 0.75, 0.88, 0.40, 'balanced'),
-	('Chelsea', 0.78, 0.88, 0.80, 0.37, 'defensive');
+	INSERT INTO teams (name, attack, defense, midfield, home_boost, play_style)
+	SELECT name, attack, defense, midfield, home_boost, play_style FROM (
+		SELECT 'Manchester City' AS name, 0.95 AS attack, 0.85 AS defense, 0.92 AS midfield, 0.42 AS home_boost,
+			'possession' AS play_style
+		UNION ALL SELECT 'Liverpool', 0.90, 0.80, 0.85, 0.45, 'attacking'
+		UNION ALL SELECT 'Arsenal', 0.85, 0.75, 0.88, 0.40, 'balanced'
+		UNION ALL SELECT 'Chelsea', 0.78, 0.88, 0.80, 0.37, 'defensive'
+	)
+	WHERE NOT EXISTS (SELECT 1 FROM teams);
+	`
+	if _, err := tx.Exec(insertTeamsQuery); err != nil {
+		log.Printf("Failed to insert initial teams list: %v", err)
+		return err
+	}
+
+	// The default league plays the initial teams only, teams added later join leagues explicitly
+	const insertDefaultLeagueQuery string = `
+	INSERT OR IGNORE INTO leagues (id, name) VALUES (1, 'Premier League');
+
+	INSERT INTO league_teams (league_id, team_id)
+	SELECT 1, id FROM teams
+	WHERE name IN ('Manchester City', 'Liverpool', 'Arsenal', 'Chelsea')
+	AND NOT EXISTS (SELECT 1 FROM league_teams WHERE league_id = 1);
+	`
+	if _, err := tx.Exec(insertDefaultLeagueQuery); err != nil {
+		log.Printf("Failed to insert default league: %v", err)
+		return err
+	}
+
+	const insertStateQuery string = `
+	INSERT OR IGNORE INTO simulation_state (league_id, current_week, max_weeks)
+	VALUES (1, 1, 6);
 	`
-	_, err = sqlite.db.Exec(insertTeamsQuery)
+	if _, err := tx.Exec(insertStateQuery); err != nil {
+		log.Printf("Failed to insert initial state: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (sqlite *SQLiteDatabase) Close() error {
+	if sqlite.db != nil {
+		err := sqlite.db.Close()
+		if err != nil {
+			return err
+		}
+		sqlite.db = nil
+	}
+	return nil
+}
+
+func (sqlite *SQLiteDatabase) GetLeagues() ([]models.League, error) {
+	rows, err := sqlite.conn().Query(getLeaguesQuery)
+	if err != nil {
+		log.Printf("Failed to query leagues: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	leagues := make([]models.League, 0)
+	for rows.Next() {
+		var league models.League
+		var rules string
+		err := rows.Scan(&league.ID, &league.Name, &rules, &league.CurrentWeek, &league.MaxWeeks, &league.Seed)
+		if err != nil {
+			log.Printf("Failed to scan league row: %v", err)
+			return nil, err
+		}
+		if league.Rules, err = parseRules(rules); err != nil {
+			log.Printf("Failed to parse rules of league %d: %v", league.ID, err)
+			return nil, err
+		}
+		leagues = append(leagues, league)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Printf("Error occurred during row iteration: %v", err)
+		return nil, err
+	}
+
+	for i := range leagues {
+		leagues[i].Teams, err = sqlite.ForLeague(leagues[i].ID).GetTeams()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return leagues, nil
+}
+
+func (sqlite *SQLiteDatabase) GetLeague(leagueID int) (*models.League, error) {
+	var league models.League
+	var rules string
+	err := sqlite.conn().QueryRow(getLeagueQuery, leagueID).
+		Scan(&league.ID, &league.Name, &rules, &league.CurrentWeek, &league.MaxWeeks, &league.Seed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		log.Printf("Failed to retrieve league %d: %v", leagueID, err)
+		return nil, err
+	}
+
+	if league.Rules, err = parseRules(rules); err != nil {
+		log.Printf("Failed to parse rules of league %d: %v", leagueID, err)
+		return nil, err
+	}
+
+	league.Teams, err = sqlite.ForLeague(leagueID).GetTeams()
+	if err != nil {
+		return nil, err
+	}
+	return &league, nil
+}
+
+func (sqlite *SQLiteDatabase) CreateLeague(name string, teamIDs []int, rules models.LeagueRules) (int, error) {
+	encodedRules, err := json.Marshal(rules)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := sqlite.begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(insertLeagueQuery, name, string(encodedRules))
+	if err != nil {
+		log.Printf("Failed to insert league %q: %v", name, err)
+		return 0, err
+	}
+
+	leagueID, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, teamID := range teamIDs {
+		if _, err := tx.Exec(insertLeagueTeamQuery, leagueID, teamID); err != nil {
+			log.Printf("Failed to add team %d to league %d: %v", teamID, leagueID, err)
+			return 0, err
+		}
+	}
+
+	if _, err := tx.Exec(insertLeagueStateQuery, leagueID); err != nil {
+		log.Printf("Failed to insert state for league %d: %v", leagueID, err)
+		return 0, err
+	}
+
+	return int(leagueID), tx.Commit()
+}
+
+func (sqlite *SQLiteDatabase) DeleteLeague(leagueID int) error {
+	tx, err := sqlite.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, query := range []string{deleteMatchesQuery, deleteDeductionsQuery, deleteEventsQuery, deleteSeasonsQuery, deleteRatingsQuery, deleteUndoQuery, deleteLeagueTeamsQuery, deleteLeagueStateQuery} {
+		if _, err := tx.Exec(query, leagueID); err != nil {
+			log.Printf("Failed to delete data of league %d: %v", leagueID, err)
+			return err
+		}
+	}
+
+	res, err := tx.Exec(deleteLeagueQuery, leagueID)
+	if err != nil {
+		log.Printf("Failed to delete league %d: %v", leagueID, err)
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+	return tx.Commit()
+}
+
+// GetMatchLeague returns the league a match is played in
+func (sqlite *SQLiteDatabase) GetMatchLeague(matchID int) (int, error) {
+	var leagueID int
+	err := sqlite.conn().QueryRow(getMatchLeagueQuery, matchID).Scan(&leagueID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		log.Printf("Failed to retrieve league of match %d: %v", matchID, err)
+		return 0, err
+	}
+	return leagueID, nil
+}
+
+func (sqlite *SQLiteDatabase) GetPyramids() ([]models.Pyramid, error) {
+	rows, err := sqlite.conn().Query(getPyramidsQuery)
 	if err != nil {
-		log.Fatalf("Failed to insert initial teams list: %v", err)
+		log.Printf("Failed to query pyramids: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	pyramids := make([]models.Pyramid, 0)
+	for rows.Next() {
+		var pyramid models.Pyramid
+		if err := rows.Scan(&pyramid.ID, &pyramid.Name, &pyramid.Places, &pyramid.Playoffs); err != nil {
+			log.Printf("Failed to scan pyramid row: %v", err)
+			return nil, err
+		}
+		pyramids = append(pyramids, pyramid)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Printf("Error occurred during row iteration: %v", err)
+		return nil, err
+	}
+
+	for i := range pyramids {
+		if pyramids[i].Divisions, err = sqlite.getDivisions(pyramids[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return pyramids, nil
+}
+
+func (sqlite *SQLiteDatabase) GetPyramid(pyramidID int) (*models.Pyramid, error) {
+	var pyramid models.Pyramid
+	err := sqlite.conn().QueryRow(getPyramidQuery, pyramidID).
+		Scan(&pyramid.ID, &pyramid.Name, &pyramid.Places, &pyramid.Playoffs)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		log.Printf("Failed to retrieve pyramid %d: %v", pyramidID, err)
+		return nil, err
+	}
+
+	if pyramid.Divisions, err = sqlite.getDivisions(pyramidID); err != nil {
+		return nil, err
+	}
+	return &pyramid, nil
+}
+
+func (sqlite *SQLiteDatabase) getDivisions(pyramidID int) ([]models.Division, error) {
+	rows, err := sqlite.conn().Query(getDivisionsQuery, pyramidID)
+	if err != nil {
+		log.Printf("Failed to query divisions of pyramid %d: %v", pyramidID, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	divisions := make([]models.Division, 0)
+	for rows.Next() {
+		var division models.Division
+		if err := rows.Scan(&division.Level, &division.LeagueID, &division.Name); err != nil {
+			log.Printf("Failed to scan division row: %v", err)
+			return nil, err
+		}
+		divisions = append(divisions, division)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Printf("Error occurred during row iteration: %v", err)
+		return nil, err
+	}
+	return divisions, nil
+}
+
+func (sqlite *SQLiteDatabase) GetLeaguePyramid(leagueID int) (*models.Pyramid, error) {
+	var pyramidID int
+	err := sqlite.conn().QueryRow(getLeaguePyramidQuery, leagueID).Scan(&pyramidID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		log.Printf("Failed to retrieve pyramid of league %d: %v", leagueID, err)
+		return nil, err
+	}
+	return sqlite.GetPyramid(pyramidID)
+}
+
+func (sqlite *SQLiteDatabase) CreatePyramid(pyramid models.Pyramid) (int, error) {
+	tx, err := sqlite.begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(insertPyramidQuery, pyramid.Name, pyramid.Places, pyramid.Playoffs)
+	if err != nil {
+		log.Printf("Failed to insert pyramid %q: %v", pyramid.Name, err)
+		return 0, err
+	}
+
+	pyramidID, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, division := range pyramid.Divisions {
+		if _, err := tx.Exec(insertDivisionQuery, pyramidID, division.Level, division.LeagueID); err != nil {
+			log.Printf("Failed to add league %d to pyramid %d: %v", division.LeagueID, pyramidID, err)
+			return 0, err
+		}
+	}
+	return int(pyramidID), tx.Commit()
+}
+
+func (sqlite *SQLiteDatabase) DeletePyramid(pyramidID int) error {
+	tx, err := sqlite.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(deleteDivisionsQuery, pyramidID); err != nil {
+		log.Printf("Failed to delete divisions of pyramid %d: %v", pyramidID, err)
+		return err
+	}
+
+	res, err := tx.Exec(deletePyramidQuery, pyramidID)
+	if err != nil {
+		log.Printf("Failed to delete pyramid %d: %v", pyramidID, err)
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+	return tx.Commit()
+}
+
+func (sqlite *SQLiteDatabase) GetAllTeams() ([]models.Team, error) {
+	rows, err := sqlite.conn().Query(getAllTeamsQuery)
+	if err != nil {
+		log.Printf("Failed to query teams: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	return sqlite.populateTeams(rows)
+}
+
+func (sqlite *SQLiteDatabase) GetTeam(teamID int) (*models.Team, error) {
+	var team models.Team
+	err := sqlite.conn().QueryRow(getTeamQuery, teamID).
+		Scan(&team.ID, &team.Name, &team.Attributes.Attack, &team.Attributes.Defense,
+			&team.Attributes.Midfield, &team.Attributes.HomeBoost, &team.PlayStyle)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		log.Printf("Failed to retrieve team %d: %v", teamID, err)
+		return nil, err
+	}
+	return &team, nil
+}
+
+func (sqlite *SQLiteDatabase) InsertTeam(team models.Team) (int, error) {
+	attrs := team.Attributes
+	res, err := sqlite.conn().Exec(insertTeamQuery, team.Name,
+		attrs.Attack, attrs.Defense, attrs.Midfield, attrs.HomeBoost, team.PlayStyle)
+	if err != nil {
+		log.Printf("Failed to insert team %q: %v", team.Name, err)
+		return 0, err
+	}
+
+	teamID, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(teamID), nil
+}
+
+func (sqlite *SQLiteDatabase) UpdateTeam(team models.Team) error {
+	attrs := team.Attributes
+	res, err := sqlite.conn().Exec(updateTeamQuery, team.Name,
+		attrs.Attack, attrs.Defense, attrs.Midfield, attrs.HomeBoost, team.PlayStyle, team.ID)
+	if err != nil {
+		log.Printf("Failed to update team %d: %v", team.ID, err)
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (sqlite *SQLiteDatabase) DeleteTeam(teamID int) error {
+	res, err := sqlite.conn().Exec(deleteTeamQuery, teamID)
+	if err != nil {
+		log.Printf("Failed to delete team %d: %v", teamID, err)
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SetTeams replaces the roster of the league
+func (sqlite *SQLiteDatabase) SetTeams(teamIDs []int) error {
+	tx, err := sqlite.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(deleteLeagueTeamsQuery, sqlite.leagueID); err != nil {
+		log.Printf("Failed to clear roster of league %d: %v", sqlite.leagueID, err)
+		return err
 	}
 
-	const insertStateQuery string = `
-	INSERT OR IGNORE INTO simulation_state (id, current_week, max_weeks)
-	VALUES (1, 1, 6);
-	`
-	_, err = sqlite.db.Exec(insertStateQuery)
-	if err != nil {
-		log.Fatalf("Failed to insert initial state: %v", err)
+	for _, teamID := range teamIDs {
+		if _, err := tx.Exec(insertLeagueTeamQuery, sqlite.leagueID, teamID); err != nil {
+			log.Printf("Failed to add team %d to league %d: %v", teamID, sqlite.leagueID, err)
+			return err
+		}
 	}
+	return tx.Commit()
 }
 
-func (sqlite *SQLiteDatabase) Close() error {
-	if sqlite.db != nil {
-		err := sqlite.db.Close()
-		if err != nil {
-			return err
-		}
-		sqlite.db = nil
+// UpdateRules replaces the competition rules of the league
+func (sqlite *SQLiteDatabase) UpdateRules(rules models.LeagueRules) error {
+	encodedRules, err := json.Marshal(rules)
+	if err != nil {
+		return err
+	}
+
+	if _, err := sqlite.conn().Exec(updateLeagueRulesQuery, string(encodedRules), sqlite.leagueID); err != nil {
+		log.Printf("Failed to update rules of league %d: %v", sqlite.leagueID, err)
+		return err
 	}
 	return nil
 }
 
 func (sqlite *SQLiteDatabase) GetTeams() ([]models.Team, error) {
-	rows, err := sqlite.db.Query(getTeamsQuery)
+	rows, err := sqlite.conn().Query(getTeamsQuery, sqlite.leagueID)
 	if err != nil {
-		log.Printf("Failed to query teams: %v", err)
+		log.Printf("Failed to query teams of league %d: %v", sqlite.leagueID, err)
 		return nil, err
 	}
 	defer rows.Close()
 
+	return sqlite.populateTeams(rows)
+}
+
+func (sqlite *SQLiteDatabase) populateTeams(rows *sql.Rows) ([]models.Team, error) {
 	var teams []models.Team
 	for rows.Next() {
 		var team models.Team
@@ -134,7 +922,7 @@ func (sqlite *SQLiteDatabase) GetTeams() ([]models.Team, error) {
 		teams = append(teams, team)
 	}
 
-	if err = rows.Err(); err != nil {
+	if err := rows.Err(); err != nil {
 		log.Printf("Error occurred during row iteration: %v", err)
 		return nil, err
 	}
@@ -142,7 +930,7 @@ func (sqlite *SQLiteDatabase) GetTeams() ([]models.Team, error) {
 }
 
 func (sqlite *SQLiteDatabase) GetMatches() ([]models.Match, error) {
-	rows, err := sqlite.db.Query(getMatchesQuery)
+	rows, err := sqlite.conn().Query(getMatchesQuery, sqlite.leagueID)
 
 	if err != nil {
 		log.Printf("Failed to query matches: %v", err)
@@ -154,7 +942,7 @@ func (sqlite *SQLiteDatabase) GetMatches() ([]models.Match, error) {
 }
 
 func (sqlite *SQLiteDatabase) GetMatchesForWeek(week int) ([]models.Match, error) {
-	rows, err := sqlite.db.Query(getMatchesForWeekQuery, week)
+	rows, err := sqlite.conn().Query(getMatchesForWeekQuery, sqlite.leagueID, week)
 
 	if err != nil {
 		log.Printf("Failed to query matches for week %d: %v", week, err)
@@ -167,8 +955,8 @@ func (sqlite *SQLiteDatabase) GetMatchesForWeek(week int) ([]models.Match, error
 
 func (sqlite *SQLiteDatabase) GetSimulationState() (*models.SimulationState, error) {
 	var state models.SimulationState
-	if err := sqlite.db.QueryRow(getStateQuery).
-		Scan(&state.ID, &state.CurrentWeek, &state.MaxWeeks); err != nil {
+	if err := sqlite.conn().QueryRow(getStateQuery, sqlite.leagueID).
+		Scan(&state.LeagueID, &state.CurrentWeek, &state.MaxWeeks, &state.Seed, &state.Season); err != nil {
 		log.Printf("Failed to retrieve simulation state: %v", err)
 		return nil, err
 	}
@@ -181,7 +969,7 @@ func (sqlite *SQLiteDatabase) InsertMatches(matches []models.Match) error {
 		return nil
 	}
 
-	tx, err := sqlite.db.Begin()
+	tx, err := sqlite.begin()
 	if err != nil {
 		return err
 	}
@@ -194,7 +982,7 @@ func (sqlite *SQLiteDatabase) InsertMatches(matches []models.Match) error {
 	defer stmt.Close()
 
 	for _, match := range matches {
-		if _, err := stmt.Exec(match.Week, match.HomeTeam.ID, match.AwayTeam.ID); err != nil {
+		if _, err := stmt.Exec(sqlite.leagueID, match.Week, match.HomeTeam.ID, match.AwayTeam.ID); err != nil {
 			log.Printf("Failed to insert match for week %d between team %d and team %d: %v",
 				match.Week, match.HomeTeam.ID, match.AwayTeam.ID, err)
 			return err
@@ -204,47 +992,403 @@ func (sqlite *SQLiteDatabase) InsertMatches(matches []models.Match) error {
 	return tx.Commit()
 }
 
-func (sqlite *SQLiteDatabase) UpdateMatchResult(matchID int, result models.MatchResult) error {
-	if _, err := sqlite.db.Exec(updateMatchQuery, result.HomeScore, result.AwayScore, matchID); err != nil {
+func (sqlite *SQLiteDatabase) UpdateMatchResult(matchID int, result models.MatchResult, origin models.Origin) error {
+	if _, err := sqlite.conn().Exec(updateMatchQuery, result.HomeScore, result.AwayScore, result.HomeDiscipline,
+		result.AwayDiscipline, result.HomePenalties, result.AwayPenalties, origin, matchID, sqlite.leagueID); err != nil {
 		log.Printf("Failed to update match result for match ID %d: %v", matchID, err)
 		return err
 	}
 	return nil
 }
 
-func (sqlite *SQLiteDatabase) UpdateCurrentWeek(week int) error {
-	if _, err := sqlite.db.Exec(updateWeekQuery, week); err != nil {
-		log.Printf("Failed to update current week to %d: %v", week, err)
+func (sqlite *SQLiteDatabase) UpdateCurrentWeek(from, to int) error {
+	res, err := sqlite.conn().Exec(updateWeekQuery, to, sqlite.leagueID, from)
+	if err != nil {
+		log.Printf("Failed to update current week to %d: %v", to, err)
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
 		return err
+	} else if n == 0 {
+		return ErrConflict
 	}
 	return nil
 }
 
-func (sqlite *SQLiteDatabase) ResetSimulation() error {
-	tx, err := sqlite.db.Begin()
+func (sqlite *SQLiteDatabase) UpdateMaxWeeks(weeks int) error {
+	if _, err := sqlite.conn().Exec(updateMaxWeeksQuery, weeks, sqlite.leagueID); err != nil {
+		log.Printf("Failed to update max weeks to %d: %v", weeks, err)
+		return err
+	}
+	return nil
+}
+
+func (sqlite *SQLiteDatabase) ClearMatchResult(matchID int) error {
+	if _, err := sqlite.conn().Exec(clearMatchQuery, matchID, sqlite.leagueID); err != nil {
+		log.Printf("Failed to clear result of match ID %d: %v", matchID, err)
+		return err
+	}
+	return nil
+}
+
+func (sqlite *SQLiteDatabase) ClearResultsAfterWeek(week int) error {
+	if _, err := sqlite.conn().Exec(clearMatchesAfterWeekQuery, sqlite.leagueID, week); err != nil {
+		log.Printf("Failed to clear results after week %d: %v", week, err)
+		return err
+	}
+	return nil
+}
+
+func (sqlite *SQLiteDatabase) InsertEvent(event models.Event) error {
+	var matchID, newWeek sql.NullInt64
+	if event.MatchID != 0 {
+		matchID = sql.NullInt64{Int64: int64(event.MatchID), Valid: true}
+	}
+	if event.NewWeek != 0 {
+		newWeek = sql.NullInt64{Int64: int64(event.NewWeek), Valid: true}
+	}
+
+	oldResult, err := encodeResult(event.OldResult)
+	if err != nil {
+		return err
+	}
+	newResult, err := encodeResult(event.NewResult)
+	if err != nil {
+		return err
+	}
+	rules, err := encodeRules(event.Rules)
+	if err != nil {
+		return err
+	}
+
+	if _, err := sqlite.conn().Exec(insertEventQuery, sqlite.leagueID, event.Type, event.Source, event.Week, matchID,
+		oldResult, newResult, event.Origin, newWeek, event.Seed, rules, event.CreatedAt); err != nil {
+		log.Printf("Failed to insert %s event: %v", event.Type, err)
+		return err
+	}
+	return nil
+}
+
+func (sqlite *SQLiteDatabase) GetEvents(matchID, limit, offset int) ([]models.Event, int, error) {
+	var total int
+	if err := sqlite.conn().QueryRow(countEventsQuery, sqlite.leagueID, matchID, matchID).Scan(&total); err != nil {
+		log.Printf("Failed to count events: %v", err)
+		return nil, 0, err
+	}
+
+	rows, err := sqlite.conn().Query(getEventsQuery, sqlite.leagueID, matchID, matchID, limit, offset)
+	if err != nil {
+		log.Printf("Failed to query events: %v", err)
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	events, err := scanEvents(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+	return events, total, nil
+}
+
+func (sqlite *SQLiteDatabase) GetSeasonEvents() ([]models.Event, error) {
+	rows, err := sqlite.conn().Query(getSeasonEventsQuery, sqlite.leagueID, sqlite.leagueID, models.EventSeasonReset)
+	if err != nil {
+		log.Printf("Failed to query season events: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanEvents(rows)
+}
+
+func scanEvents(rows *sql.Rows) ([]models.Event, error) {
+	events := make([]models.Event, 0)
+	for rows.Next() {
+		var event models.Event
+		var eventMatchID, newWeek, seed sql.NullInt64
+		var oldResult, newResult, rules sql.NullString
+		if err := rows.Scan(&event.ID, &event.Type, &event.Source, &event.Week, &eventMatchID, &oldResult, &newResult,
+			&event.Origin, &newWeek, &seed, &rules, &event.CreatedAt); err != nil {
+			log.Printf("Failed to scan event row: %v", err)
+			return nil, err
+		}
+
+		event.MatchID = int(eventMatchID.Int64)
+		event.NewWeek = int(newWeek.Int64)
+		if seed.Valid {
+			event.Seed = &seed.Int64
+		}
+		var err error
+		if event.OldResult, err = decodeResult(oldResult); err != nil {
+			return nil, err
+		}
+		if event.NewResult, err = decodeResult(newResult); err != nil {
+			return nil, err
+		}
+		if event.Rules, err = decodeRules(rules); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("Error occurred during row iteration: %v", err)
+		return nil, err
+	}
+	return events, nil
+}
+
+func (sqlite *SQLiteDatabase) SaveSeason(season models.Season) error {
+	encoded, err := json.Marshal(season)
+	if err != nil {
+		return err
+	}
+
+	if _, err := sqlite.conn().Exec(saveSeasonQuery, sqlite.leagueID, season.Number, string(encoded)); err != nil {
+		log.Printf("Failed to archive season %d: %v", season.Number, err)
+		return err
+	}
+	return nil
+}
+
+func (sqlite *SQLiteDatabase) GetSeasons() ([]models.Season, error) {
+	rows, err := sqlite.conn().Query(getSeasonsQuery, sqlite.leagueID)
+	if err != nil {
+		log.Printf("Failed to query seasons: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	seasons := make([]models.Season, 0)
+	for rows.Next() {
+		var raw string
+		if err := rows.Scan(&raw); err != nil {
+			log.Printf("Failed to scan season row: %v", err)
+			return nil, err
+		}
+
+		var season models.Season
+		if err := json.Unmarshal([]byte(raw), &season); err != nil {
+			log.Printf("Failed to parse archived season: %v", err)
+			return nil, err
+		}
+		seasons = append(seasons, season)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("Error occurred during row iteration: %v", err)
+		return nil, err
+	}
+	return seasons, nil
+}
+
+func (sqlite *SQLiteDatabase) GetSeason(number int) (*models.Season, error) {
+	var raw string
+	err := sqlite.conn().QueryRow(getSeasonQuery, sqlite.leagueID, number).Scan(&raw)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		log.Printf("Failed to retrieve season %d: %v", number, err)
+		return nil, err
+	}
+
+	var season models.Season
+	if err := json.Unmarshal([]byte(raw), &season); err != nil {
+		log.Printf("Failed to parse archived season %d: %v", number, err)
+		return nil, err
+	}
+	return &season, nil
+}
+
+func (sqlite *SQLiteDatabase) DeleteSeason(number int) error {
+	if _, err := sqlite.conn().Exec(deleteSeasonQuery, sqlite.leagueID, number); err != nil {
+		log.Printf("Failed to delete archived season %d: %v", number, err)
+		return err
+	}
+	return nil
+}
+
+func (sqlite *SQLiteDatabase) GetRatings() ([]models.Rating, error) {
+	rows, err := sqlite.conn().Query(getRatingsQuery, sqlite.leagueID)
+	if err != nil {
+		log.Printf("Failed to query ratings: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	ratings := make([]models.Rating, 0)
+	for rows.Next() {
+		var rating models.Rating
+		if err := rows.Scan(&rating.Week, &rating.TeamID, &rating.Rating); err != nil {
+			log.Printf("Failed to scan rating row: %v", err)
+			return nil, err
+		}
+		ratings = append(ratings, rating)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("Error occurred during row iteration: %v", err)
+		return nil, err
+	}
+	return ratings, nil
+}
+
+func (sqlite *SQLiteDatabase) SaveRatings(ratings []models.Rating) error {
+	tx, err := sqlite.begin()
 	if err != nil {
-		log.Printf("Failed to begin transaction for reset: %v", err)
 		return err
 	}
 	defer tx.Rollback()
 
-	_, err = tx.Exec(resetMatchesQuery)
+	if _, err := tx.Exec(deleteRatingsQuery, sqlite.leagueID); err != nil {
+		log.Printf("Failed to delete ratings: %v", err)
+		return err
+	}
+
+	stmt, err := tx.Prepare(insertRatingQuery)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, rating := range ratings {
+		if _, err := stmt.Exec(sqlite.leagueID, rating.Week, rating.TeamID, rating.Rating); err != nil {
+			log.Printf("Failed to insert rating of team %d for week %d: %v", rating.TeamID, rating.Week, err)
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (sqlite *SQLiteDatabase) GetUndo() (*models.UndoStep, error) {
+	var raw string
+	err := sqlite.conn().QueryRow(getUndoQuery, sqlite.leagueID).Scan(&raw)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		log.Printf("Failed to retrieve undo step: %v", err)
+		return nil, err
+	}
+
+	var step models.UndoStep
+	if err := json.Unmarshal([]byte(raw), &step); err != nil {
+		log.Printf("Failed to parse undo step: %v", err)
+		return nil, err
+	}
+	return &step, nil
+}
+
+func (sqlite *SQLiteDatabase) SaveUndo(step models.UndoStep) error {
+	encodedStep, err := json.Marshal(step)
+	if err != nil {
+		return err
+	}
+
+	if _, err := sqlite.conn().Exec(saveUndoQuery, sqlite.leagueID, string(encodedStep)); err != nil {
+		log.Printf("Failed to save undo step: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (sqlite *SQLiteDatabase) DeleteUndo() error {
+	if _, err := sqlite.conn().Exec(deleteUndoQuery, sqlite.leagueID); err != nil {
+		log.Printf("Failed to delete undo step: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (sqlite *SQLiteDatabase) GetDeductions() ([]models.PointDeduction, error) {
+	rows, err := sqlite.conn().Query(getDeductionsQuery, sqlite.leagueID)
+	if err != nil {
+		log.Printf("Failed to query point deductions: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	deductions := make([]models.PointDeduction, 0)
+	for rows.Next() {
+		var deduction models.PointDeduction
+		if err := rows.Scan(&deduction.ID, &deduction.TeamID, &deduction.Points, &deduction.Reason, &deduction.Week); err != nil {
+			log.Printf("Failed to scan point deduction row: %v", err)
+			return nil, err
+		}
+		deductions = append(deductions, deduction)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Printf("Error occurred during row iteration: %v", err)
+		return nil, err
+	}
+	return deductions, nil
+}
+
+func (sqlite *SQLiteDatabase) InsertDeduction(deduction models.PointDeduction) (int, error) {
+	res, err := sqlite.conn().Exec(insertDeductionQuery, sqlite.leagueID, deduction.TeamID, deduction.Points, deduction.Reason,
+		deduction.Week)
+	if err != nil {
+		log.Printf("Failed to insert point deduction for team %d: %v", deduction.TeamID, err)
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+func (sqlite *SQLiteDatabase) DeleteDeduction(deductionID int) error {
+	res, err := sqlite.conn().Exec(deleteDeductionQuery, deductionID, sqlite.leagueID)
+	if err != nil {
+		log.Printf("Failed to delete point deduction %d: %v", deductionID, err)
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (sqlite *SQLiteDatabase) ResetSimulation(seed int64, season int) error {
+	tx, err := sqlite.begin()
 	if err != nil {
-		log.Printf("Failed to reset matches: %v", err)
+		log.Printf("Failed to begin transaction for reset: %v", err)
 		return err
 	}
+	defer tx.Rollback()
 
-	_, err = tx.Exec(resetStateQuery)
+	_, err = tx.Exec(resetStateQuery, seed, season, sqlite.leagueID)
 	if err != nil {
 		log.Printf("Failed to reset simulation state: %v", err)
 		return err
 	}
 
-	_, err = tx.Exec(deleteMatchesQuery)
+	_, err = tx.Exec(deleteMatchesQuery, sqlite.leagueID)
 	if err != nil {
 		log.Printf("Failed to delete all matches: %v", err)
 		return err
 	}
+
+	// Deductions belong to the season they were handed out in
+	_, err = tx.Exec(deleteDeductionsQuery, sqlite.leagueID)
+	if err != nil {
+		log.Printf("Failed to delete point deductions: %v", err)
+		return err
+	}
+
+	_, err = tx.Exec(deleteUndoQuery, sqlite.leagueID)
+	if err != nil {
+		log.Printf("Failed to delete undo step: %v", err)
+		return err
+	}
 	return tx.Commit()
 }
 
@@ -260,6 +1404,7 @@ func (sqlite *SQLiteDatabase) populateMatches(rows *sql.Rows) ([]models.Match, e
 
 		err := rows.Scan(
 			&match.ID, &match.Week, &homeScore, &awayScore, &match.IsPlayed,
+			&match.Result.HomeDiscipline, &match.Result.AwayDiscipline, &match.Result.HomePenalties, &match.Result.AwayPenalties, &match.Origin,
 			&ht.ID, &ht.Name, &htAttrs.Attack, &htAttrs.Defense, &htAttrs.Midfield, &htAttrs.HomeBoost, &ht.PlayStyle,
 			&at.ID, &at.Name, &atAttrs.Attack, &atAttrs.Defense, &atAttrs.Midfield, &atAttrs.HomeBoost, &at.PlayStyle,
 		)
@@ -282,3 +1427,60 @@ func (sqlite *SQLiteDatabase) populateMatches(rows *sql.Rows) ([]models.Match, e
 	}
 	return matches, nil
 }
+
+// parseRules decodes the rules stored with a league, filling in defaults for anything left unset
+func parseRules(raw string) (models.LeagueRules, error) {
+	var rules models.LeagueRules
+	if err := json.Unmarshal([]byte(raw), &rules); err != nil {
+		return rules, err
+	}
+	return rules.WithDefaults(), nil
+}
+
+// encodeResult stores an optional result of an event as JSON
+func encodeResult(result *models.MatchResult) (sql.NullString, error) {
+	if result == nil {
+		return sql.NullString{}, nil
+	}
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(encoded), Valid: true}, nil
+}
+
+func decodeResult(raw sql.NullString) (*models.MatchResult, error) {
+	if !raw.Valid {
+		return nil, nil
+	}
+	var result models.MatchResult
+	if err := json.Unmarshal([]byte(raw.String), &result); err != nil {
+		log.Printf("Failed to parse result of event: %v", err)
+		return nil, err
+	}
+	return &result, nil
+}
+
+// encodeRules stores the optional rules of an event as JSON
+func encodeRules(rules *models.LeagueRules) (sql.NullString, error) {
+	if rules == nil {
+		return sql.NullString{}, nil
+	}
+	encoded, err := json.Marshal(rules)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(encoded), Valid: true}, nil
+}
+
+func decodeRules(raw sql.NullString) (*models.LeagueRules, error) {
+	if !raw.Valid {
+		return nil, nil
+	}
+	var rules models.LeagueRules
+	if err := json.Unmarshal([]byte(raw.String), &rules); err != nil {
+		log.Printf("Failed to parse rules of event: %v", err)
+		return nil, err
+	}
+	return &rules, nil
+}
@@ -4,62 +4,280 @@ package database
 
 const (
 	getTeamsQuery string = `
+	SELECT t.id, t.name, t.attack, t.defense, t.midfield, t.home_boost, t.play_style
+	FROM teams t
+	JOIN league_teams lt ON lt.team_id = t.id
+	WHERE lt.league_id = ?
+	ORDER BY t.name;
+	`
+
+	getAllTeamsQuery string = `
 	SELECT id, name, attack, defense, midfield, home_boost, play_style FROM teams ORDER BY name;
 	`
 
+	getTeamQuery string = `
+	SELECT id, name, attack, defense, midfield, home_boost, play_style FROM teams WHERE id = ?;
+	`
+
+	insertTeamQuery string = `
+	INSERT INTO teams (name, attack, defense, midfield, home_boost, play_style)
+	VALUES (?, ?, ?, ?, ?, ?);
+	`
+
+	updateTeamQuery string = `
+	UPDATE teams
+	SET name = ?, attack = ?, defense = ?, midfield = ?, home_boost = ?, play_style = ?
+	WHERE id = ?;
+	`
+
+	deleteTeamQuery string = `
+	DELETE FROM teams WHERE id = ?;
+	`
+
 	getMatchesQuery string = `
-	SELECT m.id, m.week, m.home_score, m.away_score, m.is_played,
+	SELECT m.id, m.week, m.home_score, m.away_score, m.is_played, m.home_discipline, m.away_discipline, m.home_penalties, m.away_penalties, m.origin,
 		ht.id as home_id, ht.name as home_name, ht.attack as home_attack, ht.defense as home_defense, ht.midfield as home_midfield, ht.home_boost as home_boost, ht.play_style as home_style,
 		at.id as away_id, at.name as away_name, at.attack as away_attack, at.defense as away_defense, at.midfield as away_midfield, at.home_boost as away_boost, at.play_style as away_style
 	FROM matches m
 	JOIN teams ht ON m.home_team_id = ht.id
 	JOIN teams at ON m.away_team_id = at.id
+	WHERE m.league_id = ?
 	ORDER BY m.week, m.id;
 	`
 
 	getMatchesForWeekQuery string = `
-	SELECT m.id, m.week, m.home_score, m.away_score, m.is_played,
+	SELECT m.id, m.week, m.home_score, m.away_score, m.is_played, m.home_discipline, m.away_discipline, m.home_penalties, m.away_penalties, m.origin,
 		ht.id as home_id, ht.name as home_name, ht.attack as home_attack, ht.defense as home_defense, ht.midfield as home_midfield, ht.home_boost as home_boost, ht.play_style as home_style,
 		at.id as away_id, at.name as away_name, at.attack as away_attack, at.defense as away_defense, at.midfield as away_midfield, at.home_boost as away_boost, at.play_style as away_style
 	FROM matches m
 	JOIN teams ht ON m.home_team_id = ht.id
 	JOIN teams at ON m.away_team_id = at.id
-	WHERE m.week = ?
+	WHERE m.league_id = ? AND m.week = ?
 	ORDER BY m.id;
 	`
 
 	getStateQuery string = `
-	SELECT id, current_week, max_weeks FROM simulation_state WHERE id = 1;
+	SELECT league_id, current_week, max_weeks, seed, season FROM simulation_state WHERE league_id = ?;
+	`
+
+	getLeaguesQuery string = `
+	SELECT l.id, l.name, l.rules, s.current_week, s.max_weeks, s.seed
+	FROM leagues l
+	JOIN simulation_state s ON s.league_id = l.id
+	ORDER BY l.id;
+	`
+
+	getLeagueQuery string = `
+	SELECT l.id, l.name, l.rules, s.current_week, s.max_weeks, s.seed
+	FROM leagues l
+	JOIN simulation_state s ON s.league_id = l.id
+	WHERE l.id = ?;
 	`
 
 	insertMatchQuery string = `
-	INSERT INTO matches (week, home_team_id, away_team_id, is_played)
-	VALUES (?, ?, ?, FALSE);
+	INSERT INTO matches (league_id, week, home_team_id, away_team_id, is_played)
+	VALUES (?, ?, ?, ?, FALSE);
+	`
+
+	insertLeagueQuery string = `
+	INSERT INTO leagues (name, rules) VALUES (?, ?);
+	`
+
+	updateLeagueRulesQuery string = `
+	UPDATE leagues SET rules = ? WHERE id = ?;
+	`
+
+	insertLeagueTeamQuery string = `
+	INSERT INTO league_teams (league_id, team_id) VALUES (?, ?);
+	`
+
+	insertLeagueStateQuery string = `
+	INSERT INTO simulation_state (league_id, current_week, max_weeks) VALUES (?, 1, 0);
 	`
 
 	updateMatchQuery string = `
 	UPDATE matches
-	SET home_score = ?, away_score = ?, is_played = TRUE
-	WHERE id = ?;
+	SET home_score = ?, away_score = ?, home_discipline = ?, away_discipline = ?,
+		home_penalties = ?, away_penalties = ?, origin = ?, is_played = TRUE
+	WHERE id = ? AND league_id = ?;
+	`
+
+	getMatchLeagueQuery string = `
+	SELECT league_id FROM matches WHERE id = ?;
+	`
+
+	clearMatchQuery string = `
+	UPDATE matches
+	SET home_score = NULL, away_score = NULL, home_discipline = 0, away_discipline = 0,
+		home_penalties = 0, away_penalties = 0, origin = '', is_played = FALSE
+	WHERE id = ? AND league_id = ?;
+	`
+
+	clearMatchesAfterWeekQuery string = `
+	UPDATE matches
+	SET home_score = NULL, away_score = NULL, home_discipline = 0, away_discipline = 0,
+		home_penalties = 0, away_penalties = 0, origin = '', is_played = FALSE
+	WHERE league_id = ? AND week > ?;
+	`
+
+	insertEventQuery string = `
+	INSERT INTO events (league_id, type, source, week, match_id, old_result, new_result, origin, new_week, seed,
+		rules, created_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
+	`
+
+	// A match ID of 0 matches every event
+	getEventsQuery string = `
+	SELECT id, type, source, week, match_id, old_result, new_result, origin, new_week, seed, rules, created_at
+	FROM events
+	WHERE league_id = ? AND (? = 0 OR match_id = ?)
+	ORDER BY id DESC
+	LIMIT ? OFFSET ?;
+	`
+
+	// The season starts with its season_reset event
+	getSeasonEventsQuery string = `
+	SELECT id, type, source, week, match_id, old_result, new_result, origin, new_week, seed, rules, created_at
+	FROM events
+	WHERE league_id = ? AND id >= COALESCE((SELECT MAX(id) FROM events WHERE league_id = ? AND type = ?), 0)
+	ORDER BY id;
+	`
+
+	countEventsQuery string = `
+	SELECT COUNT(*) FROM events WHERE league_id = ? AND (? = 0 OR match_id = ?);
+	`
+
+	deleteEventsQuery string = `
+	DELETE FROM events WHERE league_id = ?;
+	`
+
+	saveSeasonQuery string = `
+	INSERT OR REPLACE INTO seasons (league_id, season, data) VALUES (?, ?, ?);
+	`
+
+	getSeasonsQuery string = `
+	SELECT data FROM seasons WHERE league_id = ? ORDER BY season;
+	`
+
+	getSeasonQuery string = `
+	SELECT data FROM seasons WHERE league_id = ? AND season = ?;
+	`
+
+	deleteSeasonQuery string = `
+	DELETE FROM seasons WHERE league_id = ? AND season = ?;
+	`
+
+	deleteSeasonsQuery string = `
+	DELETE FROM seasons WHERE league_id = ?;
+	`
+
+	getRatingsQuery string = `
+	SELECT week, team_id, rating FROM ratings WHERE league_id = ? ORDER BY week, team_id;
+	`
+
+	insertRatingQuery string = `
+	INSERT INTO ratings (league_id, week, team_id, rating) VALUES (?, ?, ?, ?);
+	`
+
+	deleteRatingsQuery string = `
+	DELETE FROM ratings WHERE league_id = ?;
+	`
+
+	getUndoQuery string = `
+	SELECT step FROM league_undo WHERE league_id = ?;
+	`
+
+	saveUndoQuery string = `
+	INSERT OR REPLACE INTO league_undo (league_id, step) VALUES (?, ?);
+	`
+
+	deleteUndoQuery string = `
+	DELETE FROM league_undo WHERE league_id = ?;
 	`
 
 	updateWeekQuery string = `
 	UPDATE simulation_state
 	SET current_week = ?
-	WHERE id = 1;
+	WHERE league_id = ? AND current_week = ?;
 	`
 
-	resetMatchesQuery string = `
-	UPDATE matches
-	SET home_score = NULL, away_score = NULL, is_played = FALSE;
+	updateMaxWeeksQuery string = `
+	UPDATE simulation_state
+	SET max_weeks = ?
+	WHERE league_id = ?;
 	`
 
 	resetStateQuery string = `
 	UPDATE simulation_state
-	SET current_week = 1;
+	SET current_week = 1, seed = ?, season = ?
+	WHERE league_id = ?;
+	`
+
+	getDeductionsQuery string = `
+	SELECT id, team_id, points, reason, week FROM point_deductions WHERE league_id = ? ORDER BY id;
+	`
+
+	insertDeductionQuery string = `
+	INSERT INTO point_deductions (league_id, team_id, points, reason, week) VALUES (?, ?, ?, ?, ?);
+	`
+
+	deleteDeductionQuery string = `
+	DELETE FROM point_deductions WHERE id = ? AND league_id = ?;
+	`
+
+	deleteDeductionsQuery string = `
+	DELETE FROM point_deductions WHERE league_id = ?;
 	`
 
 	deleteMatchesQuery string = `
-	DELETE FROM matches;
+	DELETE FROM matches WHERE league_id = ?;
+	`
+
+	deleteLeagueTeamsQuery string = `
+	DELETE FROM league_teams WHERE league_id = ?;
+	`
+
+	deleteLeagueStateQuery string = `
+	DELETE FROM simulation_state WHERE league_id = ?;
+	`
+
+	deleteLeagueQuery string = `
+	DELETE FROM leagues WHERE id = ?;
+	`
+
+	getPyramidsQuery string = `
+	SELECT id, name, places, playoffs FROM pyramids ORDER BY id;
+	`
+
+	getPyramidQuery string = `
+	SELECT id, name, places, playoffs FROM pyramids WHERE id = ?;
+	`
+
+	getDivisionsQuery string = `
+	SELECT d.level, d.league_id, l.name
+	FROM pyramid_divisions d
+	JOIN leagues l ON l.id = d.league_id
+	WHERE d.pyramid_id = ?
+	ORDER BY d.level;
+	`
+
+	getLeaguePyramidQuery string = `
+	SELECT pyramid_id FROM pyramid_divisions WHERE league_id = ?;
+	`
+
+	insertPyramidQuery string = `
+	INSERT INTO pyramids (name, places, playoffs) VALUES (?, ?, ?);
+	`
+
+	insertDivisionQuery string = `
+	INSERT INTO pyramid_divisions (pyramid_id, level, league_id) VALUES (?, ?, ?);
+	`
+
+	deleteDivisionsQuery string = `
+	DELETE FROM pyramid_divisions WHERE pyramid_id = ?;
+	`
+
+	deletePyramidQuery string = `
+	DELETE FROM pyramids WHERE id = ?;
 	`
 )
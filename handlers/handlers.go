@@ -1,19 +1,102 @@
 package handlers
 
 import (
+	"errors"
+	"io"
 	"net/http"
+	"strconv"
 
+	"insider/models"
 	"insider/services"
 
 	"github.com/gin-gonic/gin"
 )
 
 // GetSimulationState returns the current state of the league simulation
-func GetSimulationState(service services.LeagueService) gin.HandlerFunc {
+func GetSimulationState(leagues services.LeagueManager) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		state, err := service.GetCurrentState()
+		service, ok := leagueService(c, leagues)
+		if !ok {
+			return
+		}
+
+		options, ok := stateOptions(c)
+		if !ok {
+			return
+		}
+
+		if param := c.Query("week"); param != "" {
+			week, err := strconv.Atoi(param)
+			if err != nil || week < 1 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid week"})
+				return
+			}
+			options.Week = week
+		}
+
+		state, err := service.GetCurrentState(options)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, state)
+	}
+}
+
+// GetWeekState returns the table, results and odds as they stood at the end of a completed week
+func GetWeekState(leagues services.LeagueManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		service, ok := leagueService(c, leagues)
+		if !ok {
+			return
+		}
+
+		options, ok := stateOptions(c)
+		if !ok {
+			return
+		}
+
+		week, err := strconv.Atoi(c.Param("week"))
+		if err != nil || week < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid week"})
+			return
+		}
+		options.Week = week
+
+		state, err := service.GetCurrentState(options)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, state)
+	}
+}
+
+// PredictScenario returns the table and odds as if the given hypothetical results had happened
+func PredictScenario(leagues services.LeagueManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		service, ok := leagueService(c, leagues)
+		if !ok {
+			return
+		}
+
+		options, ok := stateOptions(c)
+		if !ok {
+			return
+		}
+
+		var req struct {
+			Scenarios []models.Scenario `json:"scenarios"`
+		}
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+
+		state, err := service.PredictScenario(req.Scenarios, options)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			writeError(c, err)
 			return
 		}
 		c.JSON(http.StatusOK, state)
@@ -21,11 +104,16 @@ func GetSimulationState(service services.LeagueService) gin.HandlerFunc {
 }
 
 // SimulateNextWeek simulates the next week of matches
-func SimulateNextWeek(service services.LeagueService) gin.HandlerFunc {
+func SimulateNextWeek(leagues services.LeagueManager) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		service, ok := leagueChange(c, leagues)
+		if !ok {
+			return
+		}
+
 		state, err := service.SimulateNextWeek()
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			writeError(c, err)
 			return
 		}
 		c.JSON(http.StatusOK, state)
@@ -33,37 +121,121 @@ func SimulateNextWeek(service services.LeagueService) gin.HandlerFunc {
 }
 
 // SimulateRemainingWeeks simulates all remaining weeks of the league
-func SimulateRemainingWeeks(service services.LeagueService) gin.HandlerFunc {
+func SimulateRemainingWeeks(leagues services.LeagueManager) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		service, ok := leagueChange(c, leagues)
+		if !ok {
+			return
+		}
+
 		state, err := service.SimulateRemainingWeeks()
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, state)
+	}
+}
+
+// SimulateUntilWeek plays every week up to and including the given one
+func SimulateUntilWeek(leagues services.LeagueManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		service, ok := leagueChange(c, leagues)
+		if !ok {
+			return
+		}
+
+		var req struct {
+			Week int `json:"week" binding:"required"`
+		}
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+
+		state, err := service.SimulateUntilWeek(req.Week)
+		if err != nil {
+			writeError(c, err)
 			return
 		}
 		c.JSON(http.StatusOK, state)
 	}
 }
 
-// ResetSimulation resets the entire simulation
-func ResetSimulation(service services.LeagueService) gin.HandlerFunc {
+// SimulateMatch plays a single match of the current week
+func SimulateMatch(leagues services.LeagueManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		service, ok := leagueChange(c, leagues)
+		if !ok {
+			return
+		}
+
+		matchID, err := strconv.Atoi(c.Param("matchId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid match ID"})
+			return
+		}
+
+		match, err := service.SimulateMatch(matchID)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, match)
+	}
+}
+
+// ResetSimulation resets the entire simulation, optionally with a season seed to replay
+func ResetSimulation(leagues services.LeagueManager) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		err := service.ResetSimulation()
+		service, ok := leagueChange(c, leagues)
+		if !ok {
+			return
+		}
+
+		var req struct {
+			Seed *int64 `json:"seed"`
+		}
+
+		// The body is optional, an empty one falls back to a fresh seed
+		if c.Request.Body != nil && c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+				return
+			}
+		}
+
+		seed := services.NewSeed()
+		if req.Seed != nil {
+			seed = *req.Seed
+		}
+
+		err := service.ResetSimulation(seed)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			writeError(c, err)
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"message": "Simulation reset successfully"})
+		c.JSON(http.StatusOK, gin.H{"message": "Simulation reset successfully", "seed": seed})
 	}
 }
 
 // EditMatchResult allows editing the result of a match
-func EditMatchResult(service services.LeagueService) gin.HandlerFunc {
+func EditMatchResult(leagues services.LeagueManager) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		service, ok := leagueChange(c, leagues)
+		if !ok {
+			return
+		}
+
+		// Pointers, so that a score of 0 counts as given
 		var req struct {
-			MatchID   int `json:"match_id" binding:"required"`
-			HomeScore int `json:"home_score" binding:"required"`
-			AwayScore int `json:"away_score" binding:"required"`
+			MatchID       int  `json:"match_id" binding:"required"`
+			HomeScore     *int `json:"home_score" binding:"required"`
+			AwayScore     *int `json:"away_score" binding:"required"`
+			HomePenalties int  `json:"home_penalties"`
+			AwayPenalties int  `json:"away_penalties"`
 		}
 
 		if err := c.ShouldBindJSON(&req); err != nil {
@@ -71,24 +243,900 @@ func EditMatchResult(service services.LeagueService) gin.HandlerFunc {
 			return
 		}
 
-		err := service.UpdateMatchResult(req.MatchID, req.HomeScore, req.AwayScore)
+		err := service.UpdateMatchResult(req.MatchID, models.MatchResult{
+			HomeScore:     *req.HomeScore,
+			AwayScore:     *req.AwayScore,
+			HomePenalties: req.HomePenalties,
+			AwayPenalties: req.AwayPenalties,
+		})
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			writeError(c, err)
 			return
 		}
 
-		sim, err := service.GetCurrentState()
+		sim, err := service.GetCurrentState(services.StateOptions{})
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			writeError(c, err)
 			return
 		}
 		c.JSON(http.StatusOK, sim)
 	}
 }
 
-// ServeIndex serves the main HTML page
-func ServeIndex() gin.HandlerFunc {
+// SetMatchResult sets the score of a match, in whichever league it is played
+func SetMatchResult(leagues services.LeagueManager) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.HTML(http.StatusOK, "index.html", gin.H{})
+		service, matchID, ok := matchLeague(c, leagues)
+		if !ok {
+			return
+		}
+
+		var req struct {
+			HomeScore     *int `json:"home_score" binding:"required"`
+			AwayScore     *int `json:"away_score" binding:"required"`
+			HomePenalties int  `json:"home_penalties"`
+			AwayPenalties int  `json:"away_penalties"`
+		}
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+
+		err := service.UpdateMatchResult(matchID, models.MatchResult{
+			HomeScore:     *req.HomeScore,
+			AwayScore:     *req.AwayScore,
+			HomePenalties: req.HomePenalties,
+			AwayPenalties: req.AwayPenalties,
+		})
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+
+		sim, err := service.GetCurrentState(services.StateOptions{})
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, sim)
+	}
+}
+
+// ImportResults sets the scores of many matches at once, from a JSON array or a CSV file
+func ImportResults(leagues services.LeagueManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		service, ok := leagueChange(c, leagues)
+		if !ok {
+			return
+		}
+
+		entries, ok := readResults(c)
+		if !ok {
+			return
+		}
+
+		if err := service.UpdateMatchResults(entries); err != nil {
+			writeError(c, err)
+			return
+		}
+
+		sim, err := service.GetCurrentState(services.StateOptions{})
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, sim)
+	}
+}
+
+// ClearMatchResult sets a played match back to unplayed
+func ClearMatchResult(leagues services.LeagueManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		service, matchID, ok := matchLeague(c, leagues)
+		if !ok {
+			return
+		}
+
+		if err := service.ClearMatchResult(matchID); err != nil {
+			writeError(c, err)
+			return
+		}
+
+		sim, err := service.GetCurrentState(services.StateOptions{})
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, sim)
+	}
+}
+
+// RewindSimulation takes the league back to the end of an earlier week, keeping the schedule
+func RewindSimulation(leagues services.LeagueManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		service, ok := leagueChange(c, leagues)
+		if !ok {
+			return
+		}
+
+		var req struct {
+			Week *int `json:"week" binding:"required"`
+		}
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+
+		if err := service.RewindToWeek(*req.Week); err != nil {
+			writeError(c, err)
+			return
+		}
+
+		sim, err := service.GetCurrentState(services.StateOptions{})
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, sim)
+	}
+}
+
+// UndoSimulation reverts the last simulated week, result edit or rewind
+func UndoSimulation(leagues services.LeagueManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		service, ok := leagueChange(c, leagues)
+		if !ok {
+			return
+		}
+
+		if err := service.Undo(); err != nil {
+			writeError(c, err)
+			return
+		}
+
+		sim, err := service.GetCurrentState(services.StateOptions{})
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, sim)
+	}
+}
+
+// GetDeductions lists the points taken off teams this season
+func GetDeductions(leagues services.LeagueManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		service, ok := leagueService(c, leagues)
+		if !ok {
+			return
+		}
+
+		deductions, err := service.GetDeductions()
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, deductions)
+	}
+}
+
+// GetEvents pages through the audit log of the league, newest first, optionally for a single match
+func GetEvents(leagues services.LeagueManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		service, ok := leagueService(c, leagues)
+		if !ok {
+			return
+		}
+
+		var query services.EventQuery
+		params := []struct {
+			name, label string
+			value       *int
+		}{
+			{"limit", "limit", &query.Limit},
+			{"offset", "offset", &query.Offset},
+			{"match_id", "match ID", &query.MatchID},
+		}
+		for _, p := range params {
+			if param := c.Query(p.name); param != "" {
+				n, err := strconv.Atoi(param)
+				if err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + p.label})
+					return
+				}
+				*p.value = n
+			}
+		}
+
+		page, err := service.GetEvents(query)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, page)
+	}
+}
+
+// GetSeasons lists the completed and reset seasons in the history of the league
+func GetSeasons(leagues services.LeagueManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		service, ok := leagueService(c, leagues)
+		if !ok {
+			return
+		}
+
+		seasons, err := service.GetSeasons()
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, seasons)
+	}
+}
+
+// GetSeason returns the final table and results of an archived season
+func GetSeason(leagues services.LeagueManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		service, ok := leagueService(c, leagues)
+		if !ok {
+			return
+		}
+
+		number, err := strconv.Atoi(c.Param("season"))
+		if err != nil || number < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid season"})
+			return
+		}
+
+		season, err := service.GetSeason(number)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, season)
+	}
+}
+
+// GetRecords returns the all-time records of the league
+func GetRecords(leagues services.LeagueManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		service, ok := leagueService(c, leagues)
+		if !ok {
+			return
+		}
+
+		records, err := service.GetRecords()
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, records)
+	}
+}
+
+// GetPredictions returns the exact outcome probabilities of every unplayed match
+func GetPredictions(leagues services.LeagueManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		service, ok := leagueService(c, leagues)
+		if !ok {
+			return
+		}
+
+		predictions, err := service.PredictMatches()
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, predictions)
+	}
+}
+
+// GetMatchPrediction returns the exact outcome probabilities of an unplayed match with its scoreline grid
+func GetMatchPrediction(leagues services.LeagueManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		service, ok := leagueService(c, leagues)
+		if !ok {
+			return
+		}
+
+		matchID, err := strconv.Atoi(c.Param("matchId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid match ID"})
+			return
+		}
+
+		prediction, err := service.PredictMatch(matchID)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, prediction)
+	}
+}
+
+// GetMarkets returns the odds of every unplayed match and of the title, with ?margin= overriding the margin
+func GetMarkets(leagues services.LeagueManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		service, ok := leagueService(c, leagues)
+		if !ok {
+			return
+		}
+
+		stateOptions, ok := stateOptions(c)
+		if !ok {
+			return
+		}
+		options := services.MarketOptions{Iterations: stateOptions.Iterations}
+		if param := c.Query("margin"); param != "" {
+			margin, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid margin"})
+				return
+			}
+			options.Margin = &margin
+		}
+
+		board, err := service.GetMarkets(options)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, board)
+	}
+}
+
+// GetRatings returns the Elo ratings of the teams with their history over the season
+func GetRatings(leagues services.LeagueManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		service, ok := leagueService(c, leagues)
+		if !ok {
+			return
+		}
+
+		ratings, err := service.GetRatings()
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, ratings)
+	}
+}
+
+// AddDeduction takes points off a team, with a reason
+func AddDeduction(leagues services.LeagueManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		service, ok := leagueChange(c, leagues)
+		if !ok {
+			return
+		}
+
+		var req struct {
+			TeamID int    `json:"team_id" binding:"required"`
+			Points int    `json:"points"`
+			Reason string `json:"reason"`
+		}
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+
+		deduction, err := service.AddDeduction(models.PointDeduction{TeamID: req.TeamID, Points: req.Points, Reason: req.Reason})
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusCreated, deduction)
+	}
+}
+
+// RemoveDeduction gives back the points of a deduction
+func RemoveDeduction(leagues services.LeagueManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		service, ok := leagueChange(c, leagues)
+		if !ok {
+			return
+		}
+
+		deductionID, err := strconv.Atoi(c.Param("deductionId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid deduction ID"})
+			return
+		}
+
+		if err := service.RemoveDeduction(deductionID); err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Point deduction removed successfully"})
+	}
+}
+
+// GetLeagues lists all leagues with their teams and progress
+func GetLeagues(leagues services.LeagueManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		list, err := leagues.GetLeagues()
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, list)
+	}
+}
+
+// CreateLeague creates a league for a set of teams and generates its schedule
+func CreateLeague(leagues services.LeagueManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Name    string             `json:"name" binding:"required"`
+			TeamIDs []int              `json:"team_ids" binding:"required"`
+			Seed    *int64             `json:"seed"`
+			Rules   models.LeagueRules `json:"rules"`
+		}
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+
+		seed := services.NewSeed()
+		if req.Seed != nil {
+			seed = *req.Seed
+		}
+
+		league, err := leagues.CreateLeague(req.Name, req.TeamIDs, seed, req.Rules)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusCreated, league)
+	}
+}
+
+// DeleteLeague deletes a league together with its schedule and state
+func DeleteLeague(leagues services.LeagueManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		leagueID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid league ID"})
+			return
+		}
+
+		if err := leagues.DeleteLeague(leagueID); err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "League deleted successfully"})
+	}
+}
+
+// SetLeagueTeams replaces the roster of a league and regenerates its schedule
+func SetLeagueTeams(leagues services.LeagueManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		leagueID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid league ID"})
+			return
+		}
+
+		var req struct {
+			TeamIDs []int `json:"team_ids" binding:"required"`
+			Restart bool  `json:"restart"`
+		}
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+
+		league, err := leagues.SetLeagueTeams(leagueID, req.TeamIDs, req.Restart)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, league)
+	}
+}
+
+// SetLeagueRules changes the rules a league ranks its table by
+func SetLeagueRules(leagues services.LeagueManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		leagueID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid league ID"})
+			return
+		}
+
+		week, ok := expectedWeekParam(c)
+		if !ok {
+			return
+		}
+
+		var rules models.LeagueRules
+		if err := c.ShouldBindJSON(&rules); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+
+		league, err := leagues.SetLeagueRules(leagueID, rules, week)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, league)
+	}
+}
+
+// GetPyramids lists the pyramids with their divisions
+func GetPyramids(leagues services.LeagueManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		list, err := leagues.GetPyramids()
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, list)
+	}
+}
+
+// GetPyramid returns a pyramid with its divisions
+func GetPyramid(leagues services.LeagueManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		pyramidID, ok := pyramidID(c)
+		if !ok {
+			return
+		}
+
+		pyramid, err := leagues.GetPyramid(pyramidID)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, pyramid)
+	}
+}
+
+// CreatePyramid links leagues as divisions, listed from the top down
+func CreatePyramid(leagues services.LeagueManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Name      string `json:"name" binding:"required"`
+			LeagueIDs []int  `json:"league_ids" binding:"required"`
+			Places    int    `json:"places" binding:"required"`
+			Playoffs  bool   `json:"playoffs"`
+		}
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+
+		pyramid, err := leagues.CreatePyramid(req.Name, req.LeagueIDs, req.Places, req.Playoffs)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusCreated, pyramid)
+	}
+}
+
+// DeletePyramid unlinks the divisions of a pyramid, the leagues themselves are kept
+func DeletePyramid(leagues services.LeagueManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		pyramidID, ok := pyramidID(c)
+		if !ok {
+			return
+		}
+
+		if err := leagues.DeletePyramid(pyramidID); err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Pyramid deleted successfully"})
+	}
+}
+
+// StartNextSeason promotes and relegates teams between the divisions of a finished pyramid season
+// and starts the next one
+func StartNextSeason(leagues services.LeagueManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		pyramidID, ok := pyramidID(c)
+		if !ok {
+			return
+		}
+
+		season, err := leagues.StartNextSeason(pyramidID)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, season)
+	}
+}
+
+// GetTeams lists all teams with their attributes
+func GetTeams(teams services.TeamService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		list, err := teams.GetTeams()
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, list)
+	}
+}
+
+// GetTeam returns a single team
+func GetTeam(teams services.TeamService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		teamID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid team ID"})
+			return
+		}
+
+		team, err := teams.GetTeam(teamID)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, team)
+	}
+}
+
+// CalibrateTeams fits the attack, defense and home boost of teams to historical results, in the formats
+// ImportResults takes. With ?dry_run=true the fitted values are returned without being saved.
+func CalibrateTeams(teams services.TeamService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		dryRun := false
+		if param := c.Query("dry_run"); param != "" {
+			var err error
+			if dryRun, err = strconv.ParseBool(param); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dry_run"})
+				return
+			}
+		}
+
+		entries, ok := readResults(c)
+		if !ok {
+			return
+		}
+
+		calibration, err := teams.Calibrate(entries, dryRun)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, calibration)
+	}
+}
+
+// CreateTeam adds a new team, which can then be added to leagues
+func CreateTeam(teams services.TeamService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.Team
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+
+		team, err := teams.CreateTeam(req)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusCreated, team)
+	}
+}
+
+// UpdateTeam replaces a team's name, attributes and play style
+func UpdateTeam(teams services.TeamService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		teamID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid team ID"})
+			return
+		}
+
+		var req models.Team
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+		req.ID = teamID
+
+		team, err := teams.UpdateTeam(req)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, team)
+	}
+}
+
+// DeleteTeam removes a team that is not part of any league
+func DeleteTeam(teams services.TeamService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		teamID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid team ID"})
+			return
+		}
+
+		if err := teams.DeleteTeam(teamID); err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Team deleted successfully"})
+	}
+}
+
+// ServeIndex serves the main HTML page
+func ServeIndex() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.HTML(http.StatusOK, "index.html", gin.H{})
+	}
+}
+
+// leagueService resolves the league addressed by the :id route parameter.
+// Routes without the parameter address the default league.
+func leagueService(c *gin.Context, leagues services.LeagueManager) (services.LeagueService, bool) {
+	leagueID := models.DefaultLeagueID
+	if param := c.Param("id"); param != "" {
+		id, err := strconv.Atoi(param)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid league ID"})
+			return nil, false
+		}
+		leagueID = id
+	}
+
+	service, err := leagues.GetLeague(leagueID)
+	if err != nil {
+		writeError(c, err)
+		return nil, false
+	}
+	return service, true
+}
+
+// leagueChange resolves the league like leagueService, for a request that changes it. With ?expected_week
+// the change is refused with 409 Conflict if the league is no longer at that week.
+func leagueChange(c *gin.Context, leagues services.LeagueManager) (services.LeagueService, bool) {
+	service, ok := leagueService(c, leagues)
+	if !ok {
+		return nil, false
+	}
+	return expectedWeek(c, service)
+}
+
+// matchLeague resolves the league of the match addressed by the :id route parameter, for a request that
+// changes the match. It honours ?expected_week like leagueChange.
+func matchLeague(c *gin.Context, leagues services.LeagueManager) (services.LeagueService, int, bool) {
+	matchID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid match ID"})
+		return nil, 0, false
+	}
+
+	service, err := leagues.GetMatchLeague(matchID)
+	if err != nil {
+		writeError(c, err)
+		return nil, 0, false
+	}
+
+	service, ok := expectedWeek(c, service)
+	return service, matchID, ok
+}
+
+// pyramidID reads the :id route parameter of the pyramid routes
+func pyramidID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pyramid ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// expectedWeek applies the optional ?expected_week of a request that changes a league
+func expectedWeek(c *gin.Context, service services.LeagueService) (services.LeagueService, bool) {
+	week, ok := expectedWeekParam(c)
+	if !ok {
+		return nil, false
+	}
+	if week == 0 {
+		return service, true
+	}
+	return service.WithExpectedWeek(week), true
+}
+
+// expectedWeekParam reads the optional ?expected_week, 0 when it is not given
+func expectedWeekParam(c *gin.Context) (int, bool) {
+	param := c.Query("expected_week")
+	if param == "" {
+		return 0, true
+	}
+
+	week, err := strconv.Atoi(param)
+	if err != nil || week < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid expected week"})
+		return 0, false
+	}
+	return week, true
+}
+
+// stateOptions reads the optional ?iterations override of the predictor
+func stateOptions(c *gin.Context) (services.StateOptions, bool) {
+	var options services.StateOptions
+	if param := c.Query("iterations"); param != "" {
+		iterations, err := strconv.Atoi(param)
+		if err != nil || iterations < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid iterations"})
+			return options, false
+		}
+		options.Iterations = iterations
+	}
+	return options, true
+}
+
+// writeError maps service errors to their HTTP status codes
+func writeError(c *gin.Context, err error) {
+	var validationErr *services.ValidationError
+	var batchErr *services.BatchError
+	var policyErr *services.PolicyError
+
+	switch {
+	case errors.Is(err, services.ErrLeagueNotFound), errors.Is(err, services.ErrTeamNotFound),
+		errors.Is(err, services.ErrMatchNotFound), errors.Is(err, services.ErrDeductionNotFound),
+		errors.Is(err, services.ErrSeasonNotFound), errors.Is(err, services.ErrPyramidNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrTeamInUse), errors.Is(err, services.ErrSeasonInProgress),
+		errors.Is(err, services.ErrNothingToUndo), errors.Is(err, services.ErrStaleState),
+		errors.Is(err, services.ErrLeagueInPyramid), errors.Is(err, services.ErrSeasonNotFinished):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.As(err, &validationErr):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.As(err, &batchErr):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "rows": batchErr.Rows})
+	case errors.As(err, &policyErr):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// readResults reads a batch of results from a CSV body, a CSV file uploaded in the file field or a JSON array.
+// On failure the error response has been written and false is returned.
+func readResults(c *gin.Context) ([]models.ResultEntry, bool) {
+	var entries []models.ResultEntry
+	var err error
+	switch c.ContentType() {
+	case "text/csv":
+		entries, err = services.ParseResultsCSV(c.Request.Body)
+	case "multipart/form-data":
+		header, formErr := c.FormFile("file")
+		if formErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A CSV file is required in the file field"})
+			return nil, false
+		}
+		file, openErr := header.Open()
+		if openErr != nil {
+			writeError(c, openErr)
+			return nil, false
+		}
+		defer file.Close()
+		entries, err = services.ParseResultsCSV(file)
+	default:
+		if bindErr := c.ShouldBindJSON(&entries); bindErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return nil, false
+		}
+	}
+	if err != nil {
+		writeError(c, err)
+		return nil, false
 	}
+	return entries, true
 }
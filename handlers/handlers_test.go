@@ -1,9 +1,17 @@
 package handlers_test
 
 import (
+	"bytes"
+	"database/sql"
 	"encoding/json"
+	"fmt"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 
 	"insider/database"
@@ -16,37 +24,95 @@ import (
 )
 
 func setupTestRouter(t *testing.T) *gin.Engine {
-	gin.SetMode(gin.TestMode)
-
 	db := database.NewSQLiteDatabase(":memory:")
 	db.Initialize()
+	return newTestRouter(t, db)
+}
 
-	teams, err := db.GetTeams()
-	if err != nil {
-		t.Fatalf("Failed to retrieve teams from database: %v", err)
-	}
+// newTestRouter starts the server on db as main does
+func newTestRouter(t *testing.T, db database.Database) *gin.Engine {
+	gin.SetMode(gin.TestMode)
 
 	scheduler := services.NewMatchScheduler()
 	simulator := services.NewMatchSimulator()
-	table := services.NewLeagueTable(teams)
-	predictor := services.NewLeaguePredictor(simulator, table)
-	svc := services.NewLeagueService(db, simulator, table, scheduler, predictor)
+	leagues := services.NewLeagueManager(db, simulator, scheduler)
+	teams := services.NewTeamService(db, leagues)
+
+	svc, err := leagues.GetLeague(models.DefaultLeagueID)
+	if err != nil {
+		t.Fatalf("GetLeague: %v", err)
+	}
 
-	if err := svc.ResetSimulation(); err != nil {
-		t.Fatalf("ResetSimulation: %v", err)
+	matches, err := db.GetMatches()
+	if err != nil {
+		t.Fatalf("GetMatches: %v", err)
+	}
+	if len(matches) == 0 {
+		if err := svc.ResetSimulation(services.NewSeed()); err != nil {
+			t.Fatalf("ResetSimulation: %v", err)
+		}
 	}
 
 	r := gin.New()
-	sim := r.Group("/api/simulation")
-	{
-		sim.GET("", handlers.GetSimulationState(svc))
-		sim.POST("/next-week", handlers.SimulateNextWeek(svc))
-		sim.POST("/remaining-weeks", handlers.SimulateRemainingWeeks(svc))
-		sim.POST("/reset", handlers.ResetSimulation(svc))
+	r.GET("/api/leagues", handlers.GetLeagues(leagues))
+	r.POST("/api/leagues", handlers.CreateLeague(leagues))
+	r.DELETE("/api/leagues/:id", handlers.DeleteLeague(leagues))
+	r.PUT("/api/leagues/:id/teams", handlers.SetLeagueTeams(leagues))
+	r.PUT("/api/leagues/:id/rules", handlers.SetLeagueRules(leagues))
+
+	r.GET("/api/pyramids", handlers.GetPyramids(leagues))
+	r.POST("/api/pyramids", handlers.CreatePyramid(leagues))
+	r.GET("/api/pyramids/:id", handlers.GetPyramid(leagues))
+	r.DELETE("/api/pyramids/:id", handlers.DeletePyramid(leagues))
+	r.POST("/api/pyramids/:id/next-season", handlers.StartNextSeason(leagues))
+
+	r.GET("/api/teams", handlers.GetTeams(teams))
+	r.GET("/api/teams/:id", handlers.GetTeam(teams))
+	r.POST("/api/teams", handlers.CreateTeam(teams))
+	r.POST("/api/teams/calibrate", handlers.CalibrateTeams(teams))
+	r.PUT("/api/teams/:id", handlers.UpdateTeam(teams))
+	r.DELETE("/api/teams/:id", handlers.DeleteTeam(teams))
+
+	r.PUT("/api/matches/:id/result", handlers.SetMatchResult(leagues))
+	r.DELETE("/api/matches/:id/result", handlers.ClearMatchResult(leagues))
+
+	for _, prefix := range []string{"/api/simulation", "/api/leagues/:id/simulation"} {
+		sim := r.Group(prefix)
+		sim.GET("", handlers.GetSimulationState(leagues))
+		sim.GET("/weeks/:week", handlers.GetWeekState(leagues))
+		sim.POST("/next-week", handlers.SimulateNextWeek(leagues))
+		sim.POST("/remaining-weeks", handlers.SimulateRemainingWeeks(leagues))
+		sim.POST("/until-week", handlers.SimulateUntilWeek(leagues))
+		sim.POST("/matches/:matchId/simulate", handlers.SimulateMatch(leagues))
+		sim.GET("/matches/:matchId/prediction", handlers.GetMatchPrediction(leagues))
+		sim.GET("/predictions", handlers.GetPredictions(leagues))
+		sim.GET("/markets", handlers.GetMarkets(leagues))
+		sim.POST("/results", handlers.ImportResults(leagues))
+		sim.POST("/reset", handlers.ResetSimulation(leagues))
+		sim.POST("/rewind", handlers.RewindSimulation(leagues))
+		sim.POST("/undo", handlers.UndoSimulation(leagues))
+		sim.POST("/what-if", handlers.PredictScenario(leagues))
+		sim.GET("/deductions", handlers.GetDeductions(leagues))
+		sim.POST("/deductions", handlers.AddDeduction(leagues))
+		sim.DELETE("/deductions/:deductionId", handlers.RemoveDeduction(leagues))
+		sim.GET("/events", handlers.GetEvents(leagues))
+		sim.GET("/seasons", handlers.GetSeasons(leagues))
+		sim.GET("/seasons/:season", handlers.GetSeason(leagues))
+		sim.GET("/records", handlers.GetRecords(leagues))
+		sim.GET("/ratings", handlers.GetRatings(leagues))
+		sim.PUT("/edit-match-result", handlers.EditMatchResult(leagues))
 	}
 	return r
 }
 
+// readState decodes a simulation state response into a fresh value, so no field is left over from an earlier one
+func readState(t *testing.T, w *httptest.ResponseRecorder) models.LeagueSimulation {
+	t.Helper()
+	var sim models.LeagueSimulation
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &sim))
+	return sim
+}
+
 func TestIntegration_GetSimulationState(t *testing.T) {
 	router := setupTestRouter(t)
 
@@ -131,6 +197,7 @@ func TestIntegration_SimulateFourthWeek(t *testing.T) {
 	assert.Equal(t, 5, leagueResp.CurrentWeek)
 
 	assert.NotEmpty(t, leagueResp.ChampionshipOdds, "should have championship odds after week 4")
+	assert.Len(t, leagueResp.Projections, 4, "should have a projection per team after week 4")
 }
 
 func TestIntegration_SimulateRemainingWeeks(t *testing.T) {
@@ -165,3 +232,1816 @@ func TestIntegration_ResetSimulation(t *testing.T) {
 
 	assert.Equal(t, 200, w.Code)
 }
+
+func TestIntegration_SeededSeasonIsReproducible(t *testing.T) {
+	router := setupTestRouter(t)
+
+	// Match IDs are row identities and season numbers count the seasons played, both keep counting across
+	// resets, so they are left out
+	withoutIDs := func(body []byte) models.LeagueSimulation {
+		var sim models.LeagueSimulation
+		assert.NoError(t, json.Unmarshal(body, &sim))
+		sim.Season = 0
+		for i := range sim.Matches {
+			sim.Matches[i].ID = 0
+		}
+		return sim
+	}
+
+	playSeason := func() (models.LeagueSimulation, models.LeagueSimulation) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/simulation/reset", strings.NewReader(`{"seed": 42}`))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		assert.Equal(t, 200, w.Code)
+		assert.JSONEq(t, `{"message": "Simulation reset successfully", "seed": 42}`, w.Body.String())
+
+		// stop with fixtures left so the odds are part of the comparison
+		for range 4 {
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/api/simulation/next-week", nil))
+		}
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/api/simulation", nil))
+		midSeason := withoutIDs(w.Body.Bytes())
+
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/api/simulation/remaining-weeks", nil))
+		return midSeason, withoutIDs(w.Body.Bytes())
+	}
+
+	firstMid, firstEnd := playSeason()
+	secondMid, secondEnd := playSeason()
+
+	assert.NotEmpty(t, firstMid.ChampionshipOdds)
+	assert.Equal(t, firstMid, secondMid, "same seed should produce identical odds")
+	assert.Equal(t, firstEnd, secondEnd, "same seed should produce identical results")
+}
+
+func TestIntegration_MultipleLeagues(t *testing.T) {
+	router := setupTestRouter(t)
+
+	// a second league with three of the four teams, odd count so one team rests each week
+	w := httptest.NewRecorder()
+	body := `{"name": "Mini League", "team_ids": [1, 2, 3], "seed": 7}`
+	req, _ := http.NewRequest("POST", "/api/leagues", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 201, w.Code)
+	var league models.League
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &league))
+	assert.Equal(t, "Mini League", league.Name)
+	assert.Len(t, league.Teams, 3)
+	assert.Equal(t, 6, league.MaxWeeks)
+	assert.Equal(t, int64(7), league.Seed)
+
+	leaguePath := "/api/leagues/" + strconv.Itoa(league.ID) + "/simulation"
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", leaguePath+"/next-week", nil))
+	assert.Equal(t, 200, w.Code)
+
+	// the default league is untouched by the other league's progress
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/simulation", nil))
+	var defaultSim models.LeagueSimulation
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &defaultSim))
+	assert.Equal(t, 1, defaultSim.CurrentWeek)
+	assert.Len(t, defaultSim.Matches, 12)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", leaguePath, nil))
+	var leagueSim models.LeagueSimulation
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &leagueSim))
+	assert.Equal(t, league.ID, leagueSim.LeagueID)
+	assert.Equal(t, 2, leagueSim.CurrentWeek)
+	assert.Len(t, leagueSim.Table, 3)
+	assert.Len(t, leagueSim.Matches, 6)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/leagues", nil))
+	var list []models.League
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Len(t, list, 2)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("DELETE", "/api/leagues/"+strconv.Itoa(league.ID), nil))
+	assert.Equal(t, 200, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", leaguePath, nil))
+	assert.Equal(t, 404, w.Code)
+}
+
+func TestIntegration_InvalidLeagueRequests(t *testing.T) {
+	router := setupTestRouter(t)
+
+	for _, body := range []string{
+		`{"name": "Solo", "team_ids": [1]}`,
+		`{"name": "Ghosts", "team_ids": [1, 99]}`,
+		`{"name": "Twice", "team_ids": [1, 1]}`,
+		`{"name": "premier league", "team_ids": [1, 2]}`,
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/leagues", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		assert.Equal(t, 400, w.Code, body)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("DELETE", "/api/leagues/1", nil))
+	assert.Equal(t, 400, w.Code, "default league cannot be deleted")
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("DELETE", "/api/leagues/99", nil))
+	assert.Equal(t, 404, w.Code)
+}
+
+// A league whose first season cannot be started is not registered
+func TestIntegration_CreateLeagueRollsBack(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "league.db")
+	db := database.NewSQLiteDatabase(path)
+	db.Initialize()
+	defer db.Close()
+	router := newTestRouter(t, db)
+
+	// Fail the schedule of any new league from outside the server
+	raw, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	_, err = raw.Exec(`
+	CREATE TRIGGER reject_schedule BEFORE INSERT ON matches WHEN NEW.league_id <> 1
+	BEGIN SELECT RAISE(ABORT, 'schedule rejected'); END;
+	`)
+	assert.NoError(t, err)
+	assert.NoError(t, raw.Close())
+
+	body := `{"name": "Mini League", "team_ids": [1, 2, 3]}`
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/leagues", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 500, w.Code)
+
+	leagues, err := db.GetLeagues()
+	assert.NoError(t, err)
+	assert.Len(t, leagues, 1, "no league is left without a season")
+}
+
+// Odds come with the last third of the season, whatever its length
+func TestIntegration_OddsFollowSeasonLength(t *testing.T) {
+	router := setupTestRouter(t)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/leagues", strings.NewReader(`{"name": "Derby", "team_ids": [1, 2]}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 201, w.Code)
+	var derby models.League
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &derby))
+	path := "/api/leagues/" + strconv.Itoa(derby.ID) + "/simulation"
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+	var sim models.LeagueSimulation
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &sim))
+	assert.Equal(t, 2, sim.MaxWeeks)
+	assert.Empty(t, sim.ChampionshipOdds)
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", path+"/next-week", nil))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+	sim = models.LeagueSimulation{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &sim))
+	assert.NotEmpty(t, sim.ChampionshipOdds, "the last week of two is shown with odds")
+}
+
+// A database created by the first build, before leagues, is migrated on start and keeps its teams
+func TestIntegration_MigratesEarlierDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "league.db")
+	legacy, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	_, err = legacy.Exec(`
+	CREATE TABLE teams (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		attack REAL NOT NULL DEFAULT 0.5,
+		defense REAL NOT NULL DEFAULT 0.5,
+		midfield REAL NOT NULL DEFAULT 0.5,
+		home_boost REAL NOT NULL DEFAULT 1.0,
+		play_style TEXT NOT NULL DEFAULT 'balanced'
+	);
+	CREATE TABLE matches (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		week INTEGER NOT NULL,
+		home_team_id INTEGER NOT NULL,
+		away_team_id INTEGER NOT NULL,
+		home_score INTEGER,
+		away_score INTEGER,
+		is_played BOOLEAN NOT NULL DEFAULT FALSE
+	);
+	CREATE TABLE simulation_state (
+		id INTEGER PRIMARY KEY DEFAULT 1,
+		current_week INTEGER NOT NULL DEFAULT 1,
+		max_weeks INTEGER NOT NULL DEFAULT 6
+	);
+	INSERT INTO teams (name, attack, defense, midfield, home_boost, play_style) VALUES
+	('Manchester City', 0.95, 0.85, 0.92, 0.42, 'possession'),
+	('Liverpool', 0.90, 0.80, 0.85, 0.45, 'attacking'),
+	('Arsenal', 0.85, 0.75, 0.88, 0.40, 'balanced'),
+	('Chelsea', 0.78, 0.88, 0.80, 0.37, 'defensive');
+	INSERT INTO matches (week, home_team_id, away_team_id, home_score, away_score, is_played) VALUES
+	(1, 1, 2, 2, 1, TRUE), (1, 3, 4, 0, 0, TRUE), (2, 1, 3, NULL, NULL, FALSE);
+	INSERT INTO simulation_state (id, current_week, max_weeks) VALUES (1, 2, 6);
+	`)
+	assert.NoError(t, err)
+	assert.NoError(t, legacy.Close())
+
+	db := database.NewSQLiteDatabase(path)
+	db.Initialize()
+	defer db.Close()
+
+	matches, err := db.GetMatches()
+	assert.NoError(t, err)
+	assert.Len(t, matches, 3, "matches of the single league belong to the default one")
+	router := newTestRouter(t, db)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/leagues", nil))
+	assert.Equal(t, 200, w.Code)
+	var leagues []models.League
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &leagues))
+	if assert.Len(t, leagues, 1) {
+		assert.Len(t, leagues[0].Teams, 4)
+	}
+
+	// The season in progress carries on
+	state := readState(t, sendJSON(router, "GET", "/api/simulation", ""))
+	assert.Equal(t, 2, state.CurrentWeek)
+	if assert.Len(t, state.Matches, 3) {
+		assert.Equal(t, 2, state.Matches[0].Result.HomeScore)
+	}
+	assert.Equal(t, 200, sendJSON(router, "POST", "/api/simulation/next-week", "").Code)
+	state = readState(t, sendJSON(router, "GET", "/api/simulation", ""))
+	assert.Equal(t, 3, state.CurrentWeek)
+	assert.True(t, state.Matches[2].IsPlayed)
+
+	// Starting again finds the schema current
+	db.Close()
+	db = database.NewSQLiteDatabase(path)
+	db.Initialize()
+	defer db.Close()
+	teams, err := db.GetAllTeams()
+	assert.NoError(t, err)
+	assert.Len(t, teams, 4)
+}
+
+func sendJSON(router *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestIntegration_TeamManagement(t *testing.T) {
+	router := setupTestRouter(t)
+
+	w := sendJSON(router, "POST", "/api/teams", `{
+		"name": "Newcastle",
+		"attributes": {"attack": 0.7, "defense": 0.8, "midfield": 0.7, "home_boost": 0.4},
+		"play_style": "defensive"
+	}`)
+	assert.Equal(t, 201, w.Code)
+	var team models.Team
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &team))
+	assert.Equal(t, "Newcastle", team.Name)
+	assert.Equal(t, 0.8, team.Attributes.Defense)
+	assert.Equal(t, models.PlayStyleDefensive, team.PlayStyle)
+
+	teamPath := "/api/teams/" + strconv.Itoa(team.ID)
+
+	w = sendJSON(router, "PUT", teamPath, `{
+		"name": "Newcastle United",
+		"attributes": {"attack": 0.75, "defense": 0.8, "midfield": 0.7, "home_boost": 0.4},
+		"play_style": "balanced"
+	}`)
+	assert.Equal(t, 200, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &team))
+	assert.Equal(t, "Newcastle United", team.Name)
+	assert.Equal(t, models.PlayStyleBalanced, team.PlayStyle)
+
+	for _, body := range []string{
+		`{"name": "", "attributes": {"attack": 0.5}}`,
+		`{"name": "Overrated", "attributes": {"attack": 1.5}}`,
+		`{"name": "Negative", "attributes": {"defense": -0.1}}`,
+		`{"name": "Route One", "play_style": "long-ball"}`,
+		`{"name": "arsenal"}`,
+	} {
+		w = sendJSON(router, "POST", "/api/teams", body)
+		assert.Equal(t, 400, w.Code, body)
+	}
+
+	w = sendJSON(router, "GET", "/api/teams/99", "")
+	assert.Equal(t, 404, w.Code)
+
+	// teams playing in a league cannot be deleted
+	w = sendJSON(router, "DELETE", "/api/teams/1", "")
+	assert.Equal(t, 409, w.Code)
+
+	w = sendJSON(router, "DELETE", teamPath, "")
+	assert.Equal(t, 200, w.Code)
+
+	w = sendJSON(router, "GET", "/api/teams", "")
+	var teams []models.Team
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &teams))
+	assert.Len(t, teams, 4)
+}
+
+func TestIntegration_RosterChangeRules(t *testing.T) {
+	router := setupTestRouter(t)
+
+	w := sendJSON(router, "POST", "/api/teams", `{"name": "Tottenham", "attributes": {"attack": 0.8}}`)
+	assert.Equal(t, 201, w.Code)
+	var spurs models.Team
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &spurs))
+
+	roster := `{"team_ids": [1, 2, 3, 4, ` + strconv.Itoa(spurs.ID) + `]}`
+
+	// before a ball is kicked the schedule is simply regenerated
+	w = sendJSON(router, "PUT", "/api/leagues/1/teams", roster)
+	assert.Equal(t, 200, w.Code)
+	var league models.League
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &league))
+	assert.Len(t, league.Teams, 5)
+	assert.Equal(t, 10, league.MaxWeeks)
+
+	sendJSON(router, "POST", "/api/simulation/next-week", "")
+
+	// once matches are played the season is only thrown away on request
+	w = sendJSON(router, "PUT", "/api/leagues/1/teams", `{"team_ids": [1, 2, 3, 4]}`)
+	assert.Equal(t, 409, w.Code)
+
+	w = sendJSON(router, "PUT", "/api/leagues/1/teams", `{"team_ids": [1, 2, 3, 4], "restart": true}`)
+	assert.Equal(t, 200, w.Code)
+
+	w = sendJSON(router, "GET", "/api/simulation", "")
+	var sim models.LeagueSimulation
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &sim))
+	assert.Equal(t, 1, sim.CurrentWeek)
+	assert.Equal(t, 6, sim.MaxWeeks)
+	assert.Len(t, sim.Table, 4)
+}
+
+// restartTestRouter stops the server on the database file at path, if running, and starts it again
+func restartTestRouter(t *testing.T, path string, running database.Database) (*gin.Engine, database.Database) {
+	if running != nil {
+		assert.NoError(t, running.Close())
+	}
+	db := database.NewSQLiteDatabase(path)
+	db.Initialize()
+	t.Cleanup(func() { db.Close() })
+	return newTestRouter(t, db), db
+}
+
+// The initial teams are only seeded into a new database, changes to them survive a restart
+func TestIntegration_RestartKeepsTeams(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "league.db")
+	router, db := restartTestRouter(t, path, nil)
+
+	w := sendJSON(router, "PUT", "/api/teams/3", `{
+		"name": "Arsenal FC",
+		"attributes": {"attack": 0.85, "defense": 0.75, "midfield": 0.88, "home_boost": 0.4},
+		"play_style": "balanced"
+	}`)
+	assert.Equal(t, 200, w.Code)
+	w = sendJSON(router, "POST", "/api/teams", `{"name": "Tottenham", "attributes": {"attack": 0.8}}`)
+	assert.Equal(t, 201, w.Code)
+	assert.Equal(t, 200, sendJSON(router, "PUT", "/api/leagues/1/teams", `{"team_ids": [1, 2, 3, 5]}`).Code)
+
+	router, _ = restartTestRouter(t, path, db)
+
+	w = sendJSON(router, "GET", "/api/teams", "")
+	var teams []models.Team
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &teams))
+	names := make([]string, len(teams))
+	for i, team := range teams {
+		names[i] = team.Name
+	}
+	assert.ElementsMatch(t, []string{"Manchester City", "Liverpool", "Arsenal FC", "Chelsea", "Tottenham"}, names)
+
+	w = sendJSON(router, "GET", "/api/leagues", "")
+	var leagues []models.League
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &leagues))
+	if assert.Len(t, leagues, 1) {
+		roster := make([]int, len(leagues[0].Teams))
+		for i, team := range leagues[0].Teams {
+			roster[i] = team.ID
+		}
+		assert.ElementsMatch(t, []int{1, 2, 3, 5}, roster, "Chelsea stays out of the default league")
+	}
+}
+
+// A roster change whose new season cannot be started leaves the roster and the season as they were
+func TestIntegration_RosterChangeRollsBack(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "league.db")
+	router, _ := restartTestRouter(t, path, nil)
+
+	var before models.LeagueSimulation
+	assert.NoError(t, json.Unmarshal(sendJSON(router, "GET", "/api/simulation", "").Body.Bytes(), &before))
+
+	// Fail the next schedule of the default league from outside the server
+	raw, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	_, err = raw.Exec(`
+	CREATE TRIGGER reject_schedule BEFORE INSERT ON matches WHEN NEW.league_id = 1
+	BEGIN SELECT RAISE(ABORT, 'schedule rejected'); END;
+	`)
+	assert.NoError(t, err)
+	assert.NoError(t, raw.Close())
+
+	w := sendJSON(router, "PUT", "/api/leagues/1/teams", `{"team_ids": [1, 2, 3]}`)
+	assert.Equal(t, 500, w.Code)
+
+	w = sendJSON(router, "GET", "/api/leagues", "")
+	var leagues []models.League
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &leagues))
+	if assert.Len(t, leagues, 1) {
+		assert.Len(t, leagues[0].Teams, 4, "the roster is unchanged")
+	}
+
+	var after models.LeagueSimulation
+	assert.NoError(t, json.Unmarshal(sendJSON(router, "GET", "/api/simulation", "").Body.Bytes(), &after))
+	assert.Equal(t, before.Matches, after.Matches, "the season is unchanged")
+}
+
+func TestIntegration_PredictorIterations(t *testing.T) {
+	router := setupTestRouter(t)
+
+	for range 4 {
+		sendJSON(router, "POST", "/api/simulation/next-week", "")
+	}
+
+	w := sendJSON(router, "GET", "/api/simulation?iterations=600", "")
+	assert.Equal(t, 200, w.Code)
+	var sim models.LeagueSimulation
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &sim))
+	assert.Equal(t, 600, sim.Projections[0].Iterations)
+
+	for _, param := range []string{"0", "-5", "many", "2000000"} {
+		w = sendJSON(router, "GET", "/api/simulation?iterations="+param, "")
+		assert.Equal(t, 400, w.Code, param)
+	}
+}
+
+func TestIntegration_PredictScenario(t *testing.T) {
+	router := setupTestRouter(t)
+	sendJSON(router, "POST", "/api/simulation/next-week", "")
+
+	w := sendJSON(router, "GET", "/api/simulation", "")
+	var before models.LeagueSimulation
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &before))
+
+	var scored, constrained models.Match
+	for _, m := range before.Matches {
+		if m.Week == 2 && scored.ID == 0 {
+			scored = m
+		} else if m.Week == 3 && constrained.ID == 0 {
+			constrained = m
+		}
+	}
+
+	body := fmt.Sprintf(`{"scenarios": [
+		{"match_id": %d, "home_score": 0, "away_score": 5},
+		{"match_id": %d, "outcome": "draw"}
+	]}`, scored.ID, constrained.ID)
+	w = sendJSON(router, "POST", "/api/simulation/what-if?iterations=500", body)
+	assert.Equal(t, 200, w.Code, w.Body.String())
+
+	var sim models.LeagueSimulation
+	sim = readState(t, w)
+	assert.Len(t, sim.Projections, 4, "what-if always runs the predictor")
+	assert.Equal(t, 500, sim.Projections[0].Iterations)
+
+	for _, e := range sim.Table {
+		if e.Team.ID == scored.AwayTeam.ID {
+			assert.Equal(t, 2, e.Played)
+		}
+	}
+	for _, m := range sim.Matches {
+		if m.ID == scored.ID {
+			assert.True(t, m.IsPlayed)
+		}
+		if m.ID == constrained.ID {
+			assert.Equal(t, models.OutcomeDraw, m.Outcome)
+		}
+	}
+
+	// Nothing is persisted
+	w = sendJSON(router, "GET", "/api/simulation", "")
+	var after models.LeagueSimulation
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &after))
+	assert.Equal(t, before.Matches, after.Matches)
+
+	// Played matches cannot be hypothesized, unknown ones are not found
+	played := before.Matches[0].ID
+	w = sendJSON(router, "POST", "/api/simulation/what-if", fmt.Sprintf(`{"scenarios": [{"match_id": %d, "outcome": "draw"}]}`, played))
+	assert.Equal(t, 400, w.Code)
+	w = sendJSON(router, "POST", "/api/simulation/what-if", `{"scenarios": [{"match_id": 99999, "outcome": "draw"}]}`)
+	assert.Equal(t, 404, w.Code)
+}
+
+func TestIntegration_LeagueRules(t *testing.T) {
+	router := setupTestRouter(t)
+
+	w := sendJSON(router, "GET", "/api/leagues", "")
+	var leagues []models.League
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &leagues))
+	assert.Equal(t, models.DefaultTiebreakers, leagues[0].Rules.Tiebreakers)
+
+	w = sendJSON(router, "POST", "/api/leagues",
+		`{"name": "Cup", "team_ids": [1, 2], "rules": {"tiebreakers": ["head_to_head_points", "drawing_of_lots"]}}`)
+	assert.Equal(t, 201, w.Code, w.Body.String())
+	var league models.League
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &league))
+	assert.Equal(t, []models.Tiebreaker{models.TiebreakerHeadToHeadPoints, models.TiebreakerDrawingOfLots}, league.Rules.Tiebreakers)
+
+	sendJSON(router, "POST", "/api/simulation/next-week", "")
+	w = sendJSON(router, "PUT", "/api/leagues/1/rules", `{"tiebreakers": ["wins", "fair_play"]}`)
+	assert.Equal(t, 200, w.Code, w.Body.String())
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &league))
+	assert.Equal(t, []models.Tiebreaker{models.TiebreakerWins, models.TiebreakerFairPlay}, league.Rules.Tiebreakers)
+
+	// Results are kept when the rules change
+	w = sendJSON(router, "GET", "/api/simulation", "")
+	var sim models.LeagueSimulation
+	sim = readState(t, w)
+	assert.Equal(t, 2, sim.CurrentWeek)
+
+	for _, body := range []string{`{"tiebreakers": ["coin_toss"]}`, `{"tiebreakers": ["wins", "wins"]}`} {
+		w = sendJSON(router, "PUT", "/api/leagues/1/rules", body)
+		assert.Equal(t, 400, w.Code, body)
+	}
+	w = sendJSON(router, "PUT", "/api/leagues/99/rules", `{"tiebreakers": ["wins"]}`)
+	assert.Equal(t, 404, w.Code)
+
+	// A change of rules honours ?expected_week and goes to the audit log
+	w = sendJSON(router, "PUT", "/api/leagues/1/rules?expected_week=1", `{"tiebreakers": ["wins"]}`)
+	assert.Equal(t, 409, w.Code)
+	w = sendJSON(router, "PUT", "/api/leagues/1/rules?expected_week=first", `{"tiebreakers": ["wins"]}`)
+	assert.Equal(t, 400, w.Code)
+	w = sendJSON(router, "PUT", "/api/leagues/1/rules?expected_week=2", `{"tiebreakers": ["wins"]}`)
+	assert.Equal(t, 200, w.Code, w.Body.String())
+	var page models.EventPage
+	assert.NoError(t, json.Unmarshal(sendJSON(router, "GET", "/api/simulation/events", "").Body.Bytes(), &page))
+	latest := page.Events[0]
+	assert.Equal(t, models.EventRulesChanged, latest.Type)
+	assert.Equal(t, "set_rules", latest.Source)
+	assert.Equal(t, 2, latest.Week)
+	if assert.NotNil(t, latest.Rules) {
+		assert.Equal(t, []models.Tiebreaker{models.TiebreakerWins}, latest.Rules.Tiebreakers)
+	}
+
+	// The archive of a completed season is ranked by the new points too
+	sendJSON(router, "POST", "/api/simulation/remaining-weeks", "")
+	w = sendJSON(router, "PUT", "/api/leagues/1/rules", `{"points": {"win": 2, "draw": 1}}`)
+	assert.Equal(t, 200, w.Code, w.Body.String())
+	var season models.Season
+	assert.NoError(t, json.Unmarshal(sendJSON(router, "GET", "/api/simulation/seasons/1", "").Body.Bytes(), &season))
+	for _, e := range season.Table {
+		assert.Equal(t, 2*e.Won+e.Drawn, e.Points, e.Team.Name)
+	}
+}
+
+func TestIntegration_PointsSystemAndDeductions(t *testing.T) {
+	router := setupTestRouter(t)
+
+	w := sendJSON(router, "POST", "/api/leagues",
+		`{"name": "Old School", "team_ids": [1, 2, 3, 4], "seed": 3, "rules": {"points": {"win": 2, "draw": 1}}}`)
+	assert.Equal(t, 201, w.Code, w.Body.String())
+	var league models.League
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &league))
+	assert.Equal(t, models.PointsSystem{Win: 2, Draw: 1}, league.Rules.Points)
+
+	leaguePath := "/api/leagues/" + strconv.Itoa(league.ID) + "/simulation"
+	w = sendJSON(router, "POST", leaguePath+"/remaining-weeks", "")
+	var sim models.LeagueSimulation
+	sim = readState(t, w)
+	for _, e := range sim.Table {
+		assert.Equal(t, 2*e.Won+e.Drawn, e.Points, e.Team.Name)
+	}
+
+	// Deductions on the default league
+	sendJSON(router, "POST", "/api/simulation/next-week", "")
+	w = sendJSON(router, "POST", "/api/simulation/deductions", `{"team_id": 2, "points": 6, "reason": "Breach of financial rules"}`)
+	assert.Equal(t, 201, w.Code, w.Body.String())
+	var deduction models.PointDeduction
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &deduction))
+
+	w = sendJSON(router, "GET", "/api/simulation", "")
+	sim = readState(t, w)
+	for _, e := range sim.Table {
+		if e.Team.ID == 2 {
+			assert.Equal(t, 6, e.PointsDeducted)
+			assert.Equal(t, 3*e.Won+e.Drawn-6, e.Points)
+			assert.Equal(t, "Breach of financial rules", e.Deductions[0].Reason)
+		}
+	}
+
+	w = sendJSON(router, "GET", "/api/simulation/deductions", "")
+	var deductions []models.PointDeduction
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &deductions))
+	assert.Equal(t, []models.PointDeduction{deduction}, deductions)
+
+	for _, body := range []string{
+		`{"team_id": 2, "points": 0, "reason": "Nothing"}`,
+		`{"team_id": 2, "points": 3, "reason": " "}`,
+		`{"team_id": 99, "points": 3, "reason": "Unknown team"}`,
+	} {
+		w = sendJSON(router, "POST", "/api/simulation/deductions", body)
+		assert.Equal(t, 400, w.Code, body)
+	}
+
+	// Deductions change the season, so they honour ?expected_week
+	w = sendJSON(router, "POST", "/api/simulation/deductions?expected_week=1",
+		`{"team_id": 2, "points": 1, "reason": "Pitch invasion"}`)
+	assert.Equal(t, 409, w.Code)
+	w = sendJSON(router, "DELETE", "/api/simulation/deductions/"+strconv.Itoa(deduction.ID)+"?expected_week=1", "")
+	assert.Equal(t, 409, w.Code)
+
+	w = sendJSON(router, "DELETE", "/api/simulation/deductions/"+strconv.Itoa(deduction.ID)+"?expected_week=2", "")
+	assert.Equal(t, 200, w.Code)
+	w = sendJSON(router, "DELETE", "/api/simulation/deductions/"+strconv.Itoa(deduction.ID), "")
+	assert.Equal(t, 404, w.Code)
+
+	// Deductions belong to a season
+	sendJSON(router, "POST", "/api/simulation/deductions", `{"team_id": 2, "points": 1, "reason": "Pitch invasion"}`)
+	sendJSON(router, "POST", "/api/simulation/reset", "")
+	w = sendJSON(router, "GET", "/api/simulation/deductions", "")
+	assert.Equal(t, "[]", w.Body.String())
+
+	w = sendJSON(router, "PUT", "/api/leagues/1/rules", `{"points": {"win": 1, "draw": 1, "loss": 1}}`)
+	assert.Equal(t, 400, w.Code)
+}
+
+func TestIntegration_StateAsOfWeek(t *testing.T) {
+	router := setupTestRouter(t)
+
+	for range 5 {
+		sendJSON(router, "POST", "/api/simulation/next-week", "")
+	}
+	w := sendJSON(router, "GET", "/api/simulation?iterations=500", "")
+	var live models.LeagueSimulation
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &live))
+	assert.NotEmpty(t, live.ChampionshipOdds)
+
+	sendJSON(router, "POST", "/api/simulation/next-week", "")
+
+	// Looking back at week 5 gives exactly what was shown at the time, odds included
+	w = sendJSON(router, "GET", "/api/simulation/weeks/5?iterations=500", "")
+	assert.Equal(t, 200, w.Code, w.Body.String())
+	var past models.LeagueSimulation
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &past))
+	assert.Equal(t, 5, past.AsOfWeek)
+	past.AsOfWeek = 0
+	assert.Equal(t, live, past)
+
+	w = sendJSON(router, "GET", "/api/simulation?week=5&iterations=500", "")
+	var viaQuery models.LeagueSimulation
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &viaQuery))
+	viaQuery.AsOfWeek = 0
+	assert.Equal(t, live, viaQuery)
+
+	// The history runs through every completed week and ends at the current table
+	w = sendJSON(router, "GET", "/api/simulation", "")
+	var now models.LeagueSimulation
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &now))
+	for i, h := range now.History {
+		assert.Len(t, h.Positions, 6)
+		assert.Equal(t, now.Table[i].Team.ID, h.TeamID)
+		assert.Equal(t, i+1, h.Positions[5])
+		assert.Equal(t, now.Table[i].Points, h.Points[5])
+	}
+
+	// A deduction counts from the week it was made in, earlier weeks stand as they were
+	w = sendJSON(router, "POST", "/api/simulation/deductions", `{"team_id": 2, "points": 3, "reason": "Late kickoff"}`)
+	assert.Equal(t, 201, w.Code)
+	var deduction models.PointDeduction
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &deduction))
+	assert.Equal(t, 7, deduction.Week)
+
+	past = readState(t, sendJSON(router, "GET", "/api/simulation/weeks/5?iterations=500", ""))
+	past.AsOfWeek = 0
+	assert.Equal(t, live, past)
+
+	after := readState(t, sendJSON(router, "GET", "/api/simulation", ""))
+	for _, h := range after.History {
+		if h.TeamID == 2 {
+			for _, e := range after.Table {
+				if e.Team.ID == 2 {
+					assert.Equal(t, e.Points+3, h.Points[5])
+				}
+			}
+		}
+	}
+
+	// So does a result edited afterwards, in the week view and the history alike
+	var weekFive models.Match
+	for _, m := range live.Matches {
+		if m.Week == 5 {
+			weekFive = m
+		}
+	}
+	w = sendJSON(router, "PUT", "/api/simulation/edit-match-result",
+		fmt.Sprintf(`{"match_id": %d, "home_score": 9, "away_score": 0}`, weekFive.ID))
+	assert.Equal(t, 200, w.Code, w.Body.String())
+
+	past = readState(t, sendJSON(router, "GET", "/api/simulation/weeks/5?iterations=500", ""))
+	past.AsOfWeek = 0
+	assert.Equal(t, live, past)
+
+	edited := readState(t, sendJSON(router, "GET", "/api/simulation", ""))
+	for _, h := range edited.History {
+		for _, e := range live.Table {
+			if e.Team.ID == h.TeamID {
+				assert.Equal(t, e.Position, h.Positions[4])
+				assert.Equal(t, e.Points, h.Points[4])
+			}
+		}
+	}
+
+	for _, path := range []string{"/api/simulation/weeks/7", "/api/simulation/weeks/0", "/api/simulation/weeks/last", "/api/simulation?week=-1"} {
+		w = sendJSON(router, "GET", path, "")
+		assert.Equal(t, 400, w.Code, path)
+	}
+}
+
+func TestIntegration_RewindAndUndo(t *testing.T) {
+	router := setupTestRouter(t)
+
+	w := sendJSON(router, "POST", "/api/simulation/undo", "")
+	assert.Equal(t, 409, w.Code)
+
+	for range 3 {
+		sendJSON(router, "POST", "/api/simulation/next-week", "")
+	}
+	w = sendJSON(router, "GET", "/api/simulation", "")
+	var weekThree models.LeagueSimulation
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &weekThree))
+
+	// Undo takes back only the last week
+	sendJSON(router, "POST", "/api/simulation/next-week", "")
+	w = sendJSON(router, "POST", "/api/simulation/undo", "")
+	assert.Equal(t, 200, w.Code, w.Body.String())
+	var undone models.LeagueSimulation
+	undone = readState(t, w)
+	assert.Equal(t, weekThree, undone)
+
+	w = sendJSON(router, "POST", "/api/simulation/undo", "")
+	assert.Equal(t, 409, w.Code)
+
+	// Rewinding keeps the schedule and replaying gives the same results, since they are seeded per fixture
+	w = sendJSON(router, "POST", "/api/simulation/rewind", `{"week": 1}`)
+	assert.Equal(t, 200, w.Code, w.Body.String())
+	var rewound models.LeagueSimulation
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &rewound))
+	assert.Equal(t, 2, rewound.CurrentWeek)
+	assert.Len(t, rewound.Matches, len(weekThree.Matches))
+	for i, m := range rewound.Matches {
+		assert.Equal(t, weekThree.Matches[i].ID, m.ID)
+		assert.Equal(t, m.Week == 1, m.IsPlayed)
+	}
+
+	sendJSON(router, "POST", "/api/simulation/next-week", "")
+	sendJSON(router, "POST", "/api/simulation/next-week", "")
+	w = sendJSON(router, "GET", "/api/simulation", "")
+	var replayed models.LeagueSimulation
+	replayed = readState(t, w)
+	assert.Equal(t, weekThree, replayed)
+
+	// An edit can be undone, as can a rewind
+	played := replayed.Matches[0]
+	w = sendJSON(router, "PUT", "/api/simulation/edit-match-result",
+		fmt.Sprintf(`{"match_id": %d, "home_score": 9, "away_score": 8}`, played.ID))
+	sendJSON(router, "POST", "/api/simulation/undo", "")
+	w = sendJSON(router, "GET", "/api/simulation", "")
+	replayed = readState(t, w)
+	assert.Equal(t, weekThree, replayed)
+
+	sendJSON(router, "POST", "/api/simulation/rewind", `{"week": 0}`)
+	w = sendJSON(router, "POST", "/api/simulation/undo", "")
+	undone = readState(t, w)
+	assert.Equal(t, weekThree, undone)
+
+	// Every week the remaining weeks played is undone together
+	sendJSON(router, "POST", "/api/simulation/remaining-weeks", "")
+	w = sendJSON(router, "POST", "/api/simulation/undo", "")
+	assert.Equal(t, 200, w.Code, w.Body.String())
+	assert.Equal(t, weekThree, readState(t, w))
+
+	for _, body := range []string{`{"week": 4}`, `{"week": -1}`, `{}`} {
+		w = sendJSON(router, "POST", "/api/simulation/rewind", body)
+		assert.Equal(t, 400, w.Code, body)
+	}
+}
+
+func TestIntegration_SimulateUntilWeekAndMatch(t *testing.T) {
+	router := setupTestRouter(t)
+
+	w := sendJSON(router, "POST", "/api/simulation/until-week", `{"week": 3}`)
+	assert.Equal(t, 200, w.Code, w.Body.String())
+	var sim models.LeagueSimulation
+	sim = readState(t, w)
+	assert.Equal(t, 4, sim.CurrentWeek)
+	for _, m := range sim.Matches {
+		assert.Equal(t, m.Week <= 3, m.IsPlayed)
+	}
+
+	for _, body := range []string{`{"week": 3}`, `{"week": 7}`, `{}`} {
+		w = sendJSON(router, "POST", "/api/simulation/until-week", body)
+		assert.Equal(t, 400, w.Code, body)
+	}
+
+	// Every week it played is undone together, and playing them again gives the same results
+	undone := readState(t, sendJSON(router, "POST", "/api/simulation/undo", ""))
+	assert.Equal(t, 1, undone.CurrentWeek)
+	for _, m := range undone.Matches {
+		assert.False(t, m.IsPlayed)
+	}
+	replayed := readState(t, sendJSON(router, "POST", "/api/simulation/until-week", `{"week": 3}`))
+	assert.Equal(t, sim.Matches, replayed.Matches)
+
+	var weekFour []models.Match
+	for _, m := range sim.Matches {
+		if m.Week == 4 {
+			weekFour = append(weekFour, m)
+		}
+	}
+
+	// Playing the later kickoff first gives the same result as playing the whole week
+	last := weekFour[len(weekFour)-1]
+	w = sendJSON(router, "POST", "/api/simulation/matches/"+strconv.Itoa(last.ID)+"/simulate", "")
+	assert.Equal(t, 200, w.Code, w.Body.String())
+	var played models.Match
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &played))
+	assert.True(t, played.IsPlayed)
+
+	w = sendJSON(router, "GET", "/api/simulation", "")
+	sim = readState(t, w)
+	assert.Equal(t, 4, sim.CurrentWeek, "the week is not over yet")
+
+	w = sendJSON(router, "POST", "/api/simulation/matches/"+strconv.Itoa(last.ID)+"/simulate", "")
+	assert.Equal(t, 400, w.Code)
+	w = sendJSON(router, "POST", "/api/simulation/matches/"+strconv.Itoa(sim.Matches[len(sim.Matches)-1].ID)+"/simulate", "")
+	assert.Equal(t, 400, w.Code, "a later week")
+	w = sendJSON(router, "POST", "/api/simulation/matches/99999/simulate", "")
+	assert.Equal(t, 404, w.Code)
+
+	w = sendJSON(router, "POST", "/api/simulation/next-week", "")
+	var week models.WeekSimulation
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &week))
+	assert.Equal(t, 4, week.PlayedWeek)
+	for _, m := range week.Matches {
+		if m.ID == last.ID {
+			assert.Equal(t, played.Result, m.Result)
+		}
+	}
+
+	sendJSON(router, "POST", "/api/simulation/rewind", `{"week": 3}`)
+	for _, m := range weekFour {
+		w = sendJSON(router, "POST", "/api/simulation/matches/"+strconv.Itoa(m.ID)+"/simulate", "")
+		assert.Equal(t, 200, w.Code, w.Body.String())
+	}
+	w = sendJSON(router, "GET", "/api/simulation", "")
+	sim = readState(t, w)
+	assert.Equal(t, 5, sim.CurrentWeek, "the last match ends the week")
+	for _, m := range sim.Matches {
+		if m.ID == last.ID {
+			assert.Equal(t, played.Result, m.Result)
+		}
+	}
+}
+
+func TestIntegration_ConcurrentWeekSimulation(t *testing.T) {
+	router := setupTestRouter(t)
+
+	// Requests made against the same week: one plays it, the rest are stale
+	codes := make([]int, 4)
+	var wg sync.WaitGroup
+	for i := range codes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes[i] = sendJSON(router, "POST", "/api/simulation/next-week?expected_week=1", "").Code
+		}()
+	}
+	wg.Wait()
+
+	ok := 0
+	for _, code := range codes {
+		if code == 200 {
+			ok++
+		} else {
+			assert.Equal(t, 409, code)
+		}
+	}
+	assert.Equal(t, 1, ok)
+
+	// Without an expected week every request plays a week of its own, none twice and none skipped
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Equal(t, 200, sendJSON(router, "POST", "/api/simulation/next-week", "").Code)
+		}()
+	}
+	wg.Wait()
+
+	w := sendJSON(router, "GET", "/api/simulation", "")
+	var sim models.LeagueSimulation
+	sim = readState(t, w)
+	assert.Equal(t, 5, sim.CurrentWeek)
+	for _, m := range sim.Matches {
+		assert.Equal(t, m.Week <= 4, m.IsPlayed)
+	}
+
+	for _, path := range []string{
+		"/api/simulation/reset?expected_week=4",
+		"/api/simulation/undo?expected_week=2",
+		"/api/simulation/remaining-weeks?expected_week=1",
+	} {
+		w = sendJSON(router, "POST", path, "")
+		assert.Equal(t, 409, w.Code, path)
+	}
+	w = sendJSON(router, "PUT", "/api/simulation/edit-match-result?expected_week=1",
+		fmt.Sprintf(`{"match_id": %d, "home_score": 2, "away_score": 1}`, sim.Matches[0].ID))
+	assert.Equal(t, 409, w.Code)
+	w = sendJSON(router, "POST", "/api/simulation/next-week?expected_week=first", "")
+	assert.Equal(t, 400, w.Code)
+
+	// A stale request changes nothing
+	w = sendJSON(router, "GET", "/api/simulation", "")
+	var after models.LeagueSimulation
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &after))
+	assert.Equal(t, sim, after)
+
+	w = sendJSON(router, "POST", "/api/simulation/next-week?expected_week=5", "")
+	assert.Equal(t, 200, w.Code)
+}
+
+func TestIntegration_SetMatchResult(t *testing.T) {
+	router := setupTestRouter(t)
+	sendJSON(router, "POST", "/api/simulation/next-week", "")
+
+	w := sendJSON(router, "GET", "/api/simulation", "")
+	var sim models.LeagueSimulation
+	sim = readState(t, w)
+
+	var played, current, future models.Match
+	for _, m := range sim.Matches {
+		switch m.Week {
+		case 1:
+			played = m
+		case 2:
+			current = m
+		case 3:
+			future = m
+		}
+	}
+	path := func(m models.Match) string { return "/api/matches/" + strconv.Itoa(m.ID) + "/result" }
+
+	// A goalless draw is a result like any other
+	w = sendJSON(router, "PUT", path(played), `{"home_score": 0, "away_score": 0}`)
+	assert.Equal(t, 200, w.Code, w.Body.String())
+	sim = readState(t, w)
+	for _, m := range sim.Matches {
+		if m.ID == played.ID {
+			assert.Equal(t, models.MatchResult{}, m.Result)
+			assert.True(t, m.IsPlayed)
+		}
+	}
+
+	w = sendJSON(router, "PUT", path(current), `{"home_score": 2, "away_score": 0}`)
+	assert.Equal(t, 200, w.Code, w.Body.String())
+	sim = readState(t, w)
+	assert.Equal(t, 2, sim.CurrentWeek, "the week still has a match to play")
+
+	cases := []struct {
+		path, body string
+		code       int
+	}{
+		{path(played), `{"home_score": -1, "away_score": 0}`, 400},
+		{path(played), `{"home_score": 1}`, 400},
+		{path(played), `{"home_score": "one", "away_score": 0}`, 400},
+		{"/api/matches/first/result", `{"home_score": 1, "away_score": 0}`, 400},
+		{"/api/matches/99999/result", `{"home_score": 1, "away_score": 0}`, 404},
+		{path(future), `{"home_score": 1, "away_score": 0}`, 422},
+		{path(played) + "?expected_week=1", `{"home_score": 1, "away_score": 0}`, 409},
+	}
+	for _, tc := range cases {
+		w = sendJSON(router, "PUT", tc.path, tc.body)
+		assert.Equal(t, tc.code, w.Code, tc.path+" "+tc.body)
+	}
+
+	// The legacy route takes zero scores too
+	w = sendJSON(router, "PUT", "/api/simulation/edit-match-result",
+		fmt.Sprintf(`{"match_id": %d, "home_score": 0, "away_score": 3}`, played.ID))
+	assert.Equal(t, 200, w.Code, w.Body.String())
+
+	// Entering the last result of the week ends it
+	for _, m := range sim.Matches {
+		if m.Week == 2 && m.ID != current.ID {
+			w = sendJSON(router, "PUT", path(m)+"?expected_week=2", `{"home_score": 1, "away_score": 1}`)
+			assert.Equal(t, 200, w.Code, w.Body.String())
+		}
+	}
+	sim = readState(t, w)
+	assert.Equal(t, 3, sim.CurrentWeek)
+}
+
+func TestIntegration_SetMatchResultPenalties(t *testing.T) {
+	router := setupTestRouter(t)
+	w := sendJSON(router, "PUT", "/api/leagues/1/rules",
+		`{"points": {"win": 3, "loss": 0, "shootout": true, "shootout_win": 2, "shootout_loss": 1}}`)
+	assert.Equal(t, 200, w.Code, w.Body.String())
+
+	sim := readState(t, sendJSON(router, "GET", "/api/simulation", ""))
+	var first, second models.Match
+	for _, m := range sim.Matches {
+		if m.Week == 1 && first.ID == 0 {
+			first = m
+		} else if m.Week == 1 {
+			second = m
+		}
+	}
+	path := "/api/matches/" + strconv.Itoa(first.ID) + "/result"
+
+	cases := []struct {
+		body string
+		code int
+	}{
+		{`{"home_score": 2, "away_score": 1, "home_penalties": 4, "away_penalties": 3}`, 400},
+		{`{"home_score": 1, "away_score": 1, "home_penalties": 3, "away_penalties": 3}`, 400},
+		{`{"home_score": 1, "away_score": 1, "home_penalties": -1, "away_penalties": 3}`, 400},
+		{`{"home_score": 1, "away_score": 1}`, 422},
+	}
+	for _, tc := range cases {
+		w = sendJSON(router, "PUT", path, tc.body)
+		assert.Equal(t, tc.code, w.Code, tc.body)
+	}
+
+	w = sendJSON(router, "PUT", path, `{"home_score": 1, "away_score": 1, "home_penalties": 4, "away_penalties": 5}`)
+	assert.Equal(t, 200, w.Code, w.Body.String())
+	sim = readState(t, w)
+	for _, entry := range sim.Table {
+		switch entry.Team.ID {
+		case first.HomeTeam.ID:
+			assert.Equal(t, 1, entry.Points)
+		case first.AwayTeam.ID:
+			assert.Equal(t, 2, entry.Points)
+		}
+	}
+
+	// The legacy route and the import take them too
+	w = sendJSON(router, "PUT", "/api/simulation/edit-match-result", fmt.Sprintf(
+		`{"match_id": %d, "home_score": 0, "away_score": 0, "home_penalties": 5, "away_penalties": 4}`, first.ID))
+	assert.Equal(t, 200, w.Code, w.Body.String())
+	w = sendJSON(router, "POST", "/api/simulation/results", fmt.Sprintf(
+		`[{"match_id": %d, "home_score": 2, "away_score": 2}]`, second.ID))
+	assert.Equal(t, 400, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "give home_penalties and away_penalties")
+	w = sendJSON(router, "POST", "/api/simulation/results", fmt.Sprintf(
+		`[{"match_id": %d, "home_score": 2, "away_score": 2, "home_penalties": 3, "away_penalties": 1}]`, second.ID))
+	assert.Equal(t, 200, w.Code, w.Body.String())
+	sim = readState(t, w)
+	assert.Equal(t, 2, sim.CurrentWeek)
+	for _, m := range sim.Matches {
+		if m.ID == second.ID {
+			assert.Equal(t, 3, m.Result.HomePenalties)
+			assert.Equal(t, 1, m.Result.AwayPenalties)
+		}
+	}
+}
+
+func TestIntegration_ClearMatchResult(t *testing.T) {
+	router := setupTestRouter(t)
+	for range 2 {
+		sendJSON(router, "POST", "/api/simulation/next-week", "")
+	}
+
+	w := sendJSON(router, "GET", "/api/simulation", "")
+	var before models.LeagueSimulation
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &before))
+	cleared := before.Matches[0]
+	path := "/api/matches/" + strconv.Itoa(cleared.ID) + "/result"
+
+	w = sendJSON(router, "DELETE", path, "")
+	assert.Equal(t, 200, w.Code, w.Body.String())
+	var sim models.LeagueSimulation
+	sim = readState(t, w)
+	assert.Equal(t, 3, sim.CurrentWeek)
+	assert.False(t, sim.Matches[0].IsPlayed)
+	assert.Equal(t, models.MatchResult{}, sim.Matches[0].Result)
+
+	// It counts as a remaining fixture again
+	var playedBefore, playedAfter int
+	for i := range sim.Table {
+		playedBefore += before.Table[i].Played
+		playedAfter += sim.Table[i].Played
+	}
+	assert.Equal(t, playedBefore-2, playedAfter)
+
+	assert.Equal(t, 400, sendJSON(router, "DELETE", path, "").Code)
+	assert.Equal(t, 404, sendJSON(router, "DELETE", "/api/matches/99999/result", "").Code)
+
+	// The next week plays it again, with the result it was seeded with
+	sendJSON(router, "POST", "/api/simulation/next-week", "")
+	w = sendJSON(router, "GET", "/api/simulation", "")
+	sim = readState(t, w)
+	assert.Equal(t, 4, sim.CurrentWeek)
+	assert.True(t, sim.Matches[0].IsPlayed)
+	assert.Equal(t, cleared.Result, sim.Matches[0].Result)
+
+	// Clearing a match of a finished season reopens it at its last week
+	sendJSON(router, "POST", "/api/simulation/remaining-weeks", "")
+	w = sendJSON(router, "DELETE", path, "")
+	sim = readState(t, w)
+	assert.Equal(t, sim.MaxWeeks, sim.CurrentWeek)
+
+	w = sendJSON(router, "POST", "/api/simulation/matches/"+strconv.Itoa(cleared.ID)+"/simulate", "")
+	assert.Equal(t, 200, w.Code, w.Body.String())
+	w = sendJSON(router, "GET", "/api/simulation", "")
+	sim = readState(t, w)
+	assert.Equal(t, sim.MaxWeeks+1, sim.CurrentWeek)
+
+	sendJSON(router, "DELETE", path, "")
+	w = sendJSON(router, "POST", "/api/simulation/undo", "")
+	sim = readState(t, w)
+	assert.Equal(t, sim.MaxWeeks+1, sim.CurrentWeek)
+	assert.True(t, sim.Matches[0].IsPlayed)
+}
+
+func TestIntegration_ImportResults(t *testing.T) {
+	router := setupTestRouter(t)
+
+	w := sendJSON(router, "GET", "/api/simulation", "")
+	var sim models.LeagueSimulation
+	sim = readState(t, w)
+	var weekOne, weekTwo []models.Match
+	for _, m := range sim.Matches {
+		switch m.Week {
+		case 1:
+			weekOne = append(weekOne, m)
+		case 2:
+			weekTwo = append(weekTwo, m)
+		}
+	}
+
+	// A whole matchday by team names ends the week
+	body := fmt.Sprintf(`[
+		{"home_team": %q, "away_team": %q, "home_score": 0, "away_score": 0},
+		{"home_team": %q, "away_team": %q, "home_score": 4, "away_score": 2}
+	]`, weekOne[0].HomeTeam.Name, weekOne[0].AwayTeam.Name, weekOne[1].HomeTeam.Name, weekOne[1].AwayTeam.Name)
+	w = sendJSON(router, "POST", "/api/simulation/results", body)
+	assert.Equal(t, 200, w.Code, w.Body.String())
+	sim = readState(t, w)
+	assert.Equal(t, 2, sim.CurrentWeek)
+	assert.Equal(t, models.MatchResult{HomeScore: 4, AwayScore: 2}, sim.Matches[1].Result)
+
+	// One bad row and nothing is applied
+	body = fmt.Sprintf("match_id,home_score,away_score\n%d,1,0\n%d,-1,0\n99999,1,1\n",
+		weekTwo[0].ID, weekTwo[1].ID)
+	req := httptest.NewRequest("POST", "/api/simulation/results", strings.NewReader(body))
+	req.Header.Set("Content-Type", "text/csv")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+	assert.JSONEq(t, `{"error": "2 rows are invalid, nothing was applied", "rows": [
+		{"row": 2, "error": "scores cannot be negative"},
+		{"row": 3, "error": "match 99999 is not part of this league"}
+	]}`, w.Body.String())
+
+	w = sendJSON(router, "GET", "/api/simulation", "")
+	var unchanged models.LeagueSimulation
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &unchanged))
+	assert.Equal(t, sim, unchanged)
+
+	// The same through a file upload
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	part, _ := writer.CreateFormFile("file", "results.csv")
+	fmt.Fprintf(part, "match_id,home_score,away_score\n%d,1,0\n", weekTwo[0].ID)
+	writer.Close()
+	req = httptest.NewRequest("POST", "/api/simulation/results", &form)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code, w.Body.String())
+	sim = readState(t, w)
+	assert.Equal(t, 2, sim.CurrentWeek, "week 2 has a match left")
+
+	// An import is undone as a whole
+	w = sendJSON(router, "POST", "/api/simulation/undo", "")
+	sim = readState(t, w)
+	assert.Equal(t, unchanged, sim)
+
+	for _, body := range []string{`[]`, `{"match_id": 1}`} {
+		w = sendJSON(router, "POST", "/api/simulation/results", body)
+		assert.Equal(t, 400, w.Code, body)
+	}
+}
+
+func TestIntegration_AuditLog(t *testing.T) {
+	router := setupTestRouter(t)
+	events := func(query string) models.EventPage {
+		t.Helper()
+		w := sendJSON(router, "GET", "/api/simulation/events"+query, "")
+		assert.Equal(t, 200, w.Code, w.Body.String())
+		var page models.EventPage
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		return page
+	}
+
+	w := sendJSON(router, "POST", "/api/simulation/next-week", "")
+	assert.Equal(t, 200, w.Code)
+
+	// Newest first, after the reset that started the season
+	page := events("")
+	assert.Equal(t, 4, page.Total)
+	assert.Equal(t, 50, page.Limit)
+	types := make([]models.EventType, len(page.Events))
+	for i, e := range page.Events {
+		types[i] = e.Type
+		assert.False(t, e.CreatedAt.IsZero())
+	}
+	assert.Equal(t, []models.EventType{models.EventWeekAdvanced, models.EventResultSimulated,
+		models.EventResultSimulated, models.EventSeasonReset}, types)
+	assert.Equal(t, "simulate_week", page.Events[0].Source)
+	assert.Equal(t, 1, page.Events[0].Week)
+	assert.Equal(t, 2, page.Events[0].NewWeek)
+
+	sim := readState(t, sendJSON(router, "GET", "/api/simulation", ""))
+	played := sim.Matches[0]
+	assert.Equal(t, models.OriginSimulated, played.Origin)
+	assert.Equal(t, models.Origin(""), sim.Matches[2].Origin)
+	path := "/api/matches/" + strconv.Itoa(played.ID) + "/result"
+
+	// An edit records the result it replaced
+	w = sendJSON(router, "PUT", path, `{"home_score": 5, "away_score": 0}`)
+	assert.Equal(t, 200, w.Code, w.Body.String())
+	assert.Equal(t, models.OriginManual, readState(t, w).Matches[0].Origin)
+	edit := events("?limit=1").Events[0]
+	assert.Equal(t, models.EventResultEdited, edit.Type)
+	assert.Equal(t, "edit_result", edit.Source)
+	assert.Equal(t, played.ID, edit.MatchID)
+	assert.Equal(t, &played.Result, edit.OldResult)
+	assert.Equal(t, &models.MatchResult{HomeScore: 5, AwayScore: 0}, edit.NewResult)
+
+	// Undo restores the origin along with the result, and is logged too
+	w = sendJSON(router, "POST", "/api/simulation/undo", "")
+	assert.Equal(t, 200, w.Code, w.Body.String())
+	sim = readState(t, w)
+	assert.Equal(t, models.OriginSimulated, sim.Matches[0].Origin)
+	assert.Equal(t, played.Result, sim.Matches[0].Result)
+	undo := events("?limit=1").Events[0]
+	assert.Equal(t, "undo", undo.Source)
+	assert.Equal(t, &played.Result, undo.NewResult)
+
+	imported := sim.Matches[2]
+	w = sendJSON(router, "POST", "/api/simulation/results",
+		fmt.Sprintf(`[{"match_id": %d, "home_score": 2, "away_score": 2}]`, imported.ID))
+	assert.Equal(t, 200, w.Code, w.Body.String())
+	assert.Equal(t, models.OriginImported, readState(t, w).Matches[2].Origin)
+	assert.Equal(t, "import_results", events("?limit=1").Events[0].Source)
+
+	// The history of a single match, and paging
+	history := events("?match_id=" + strconv.Itoa(played.ID))
+	assert.Equal(t, 3, history.Total)
+	for _, e := range history.Events {
+		assert.Equal(t, played.ID, e.MatchID)
+	}
+	all := events("")
+	second := events("?limit=2&offset=1")
+	assert.Equal(t, all.Total, second.Total)
+	assert.Equal(t, all.Events[1:3], second.Events)
+
+	w = sendJSON(router, "POST", "/api/simulation/reset", `{"seed": 42}`)
+	assert.Equal(t, 200, w.Code)
+	reset := events("?limit=1").Events[0]
+	assert.Equal(t, models.EventSeasonReset, reset.Type)
+	assert.Equal(t, int64(42), *reset.Seed)
+	assert.Equal(t, models.Origin(""), readState(t, sendJSON(router, "GET", "/api/simulation", "")).Matches[0].Origin)
+
+	for _, query := range []string{"?limit=501", "?limit=-1", "?offset=-1", "?limit=ten", "?match_id=first"} {
+		w = sendJSON(router, "GET", "/api/simulation/events"+query, "")
+		assert.Equal(t, 400, w.Code, query)
+	}
+}
+
+func TestIntegration_SeasonArchive(t *testing.T) {
+	router := setupTestRouter(t)
+	seasons := func() []models.Season {
+		t.Helper()
+		w := sendJSON(router, "GET", "/api/simulation/seasons", "")
+		assert.Equal(t, 200, w.Code, w.Body.String())
+		var out []models.Season
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &out))
+		return out
+	}
+	assert.Empty(t, seasons())
+
+	w := sendJSON(router, "POST", "/api/simulation/remaining-weeks", "")
+	final := readState(t, w)
+	assert.Equal(t, 1, final.Season)
+
+	// Completing the season archives it
+	list := seasons()
+	if assert.Len(t, list, 1) {
+		assert.Equal(t, 1, list[0].Number)
+		assert.True(t, list[0].Completed)
+		assert.Equal(t, final.Seed, list[0].Seed)
+		assert.Equal(t, final.Table[0].Team.ID, list[0].Champion.ID)
+		assert.Nil(t, list[0].Table)
+	}
+
+	w = sendJSON(router, "GET", "/api/simulation/seasons/1", "")
+	assert.Equal(t, 200, w.Code)
+	var archived models.Season
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &archived))
+	assert.Equal(t, final.Table, archived.Table)
+	assert.Len(t, archived.Matches, 12)
+
+	// Reopening the season takes it out of the history until it ends again
+	match := final.Matches[0]
+	sendJSON(router, "DELETE", "/api/matches/"+strconv.Itoa(match.ID)+"/result", "")
+	assert.Empty(t, seasons())
+	sendJSON(router, "POST", "/api/simulation/matches/"+strconv.Itoa(match.ID)+"/simulate", "")
+	assert.Len(t, seasons(), 1)
+
+	// Later changes to a completed season are archived too
+	champion := final.Table[0]
+	body := fmt.Sprintf(`{"team_id": %d, "points": 1, "reason": "Fielding an ineligible player"}`, champion.Team.ID)
+	assert.Equal(t, 201, sendJSON(router, "POST", "/api/simulation/deductions", body).Code)
+	w = sendJSON(router, "GET", "/api/simulation/seasons/1", "")
+	archived = models.Season{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &archived))
+	for _, e := range archived.Table {
+		if e.Team.ID == champion.Team.ID {
+			assert.Equal(t, champion.Points-1, e.Points)
+		}
+	}
+
+	// A reset starts the next season, and archives an unfinished one without a champion
+	sendJSON(router, "POST", "/api/simulation/reset", `{"seed": 7}`)
+	for range 2 {
+		sendJSON(router, "POST", "/api/simulation/next-week", "")
+	}
+	sendJSON(router, "POST", "/api/simulation/reset", "")
+	sendJSON(router, "POST", "/api/simulation/reset", "")
+	assert.Equal(t, 3, readState(t, sendJSON(router, "GET", "/api/simulation", "")).Season,
+		"a season nobody played keeps its number")
+
+	list = seasons()
+	if assert.Len(t, list, 2) {
+		assert.Equal(t, 2, list[1].Number)
+		assert.Equal(t, int64(7), list[1].Seed)
+		assert.False(t, list[1].Completed)
+		assert.Nil(t, list[1].Champion)
+	}
+
+	w = sendJSON(router, "GET", "/api/simulation/records", "")
+	assert.Equal(t, 200, w.Code)
+	var records models.LeagueRecords
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &records))
+	assert.Equal(t, 1, records.Seasons, "only completed seasons count")
+	if assert.Len(t, records.Titles, 1) {
+		assert.Equal(t, list[0].Champion.ID, records.Titles[0].TeamID)
+		assert.Equal(t, []int{1}, records.Titles[0].Seasons)
+	}
+	assert.Equal(t, archived.Table[0].Points, records.MostPoints[0].Points)
+	assert.Equal(t, archived.Table[3].Points, records.FewestPoints[0].Points)
+	assert.NotEmpty(t, records.BiggestWins)
+
+	assert.Equal(t, 404, sendJSON(router, "GET", "/api/simulation/seasons/99", "").Code)
+	assert.Equal(t, 400, sendJSON(router, "GET", "/api/simulation/seasons/first", "").Code)
+}
+
+// A restart carries on with the season in progress rather than archiving it and starting the next one
+func TestIntegration_RestartKeepsSeason(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "league.db")
+	router, db := restartTestRouter(t, path, nil)
+	sendJSON(router, "POST", "/api/simulation/next-week", "")
+	before := readState(t, sendJSON(router, "GET", "/api/simulation", ""))
+
+	router, _ = restartTestRouter(t, path, db)
+	after := readState(t, sendJSON(router, "GET", "/api/simulation", ""))
+	assert.Equal(t, 2, after.CurrentWeek)
+	assert.Equal(t, 1, after.Season)
+	assert.Equal(t, before.Seed, after.Seed)
+	assert.Equal(t, before.Matches, after.Matches)
+	assert.Equal(t, "[]", sendJSON(router, "GET", "/api/simulation/seasons", "").Body.String())
+}
+
+func TestIntegration_Pyramid(t *testing.T) {
+	router := setupTestRouter(t)
+
+	var lower []string
+	for _, name := range []string{"Leeds", "Burnley", "Sunderland", "Norwich"} {
+		w := sendJSON(router, "POST", "/api/teams", fmt.Sprintf(`{"name": %q, "attributes": {"attack": 0.5}}`, name))
+		assert.Equal(t, 201, w.Code)
+		var team models.Team
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &team))
+		lower = append(lower, strconv.Itoa(team.ID))
+	}
+	w := sendJSON(router, "POST", "/api/leagues", `{"name": "Championship", "team_ids": [`+strings.Join(lower, ", ")+`]}`)
+	assert.Equal(t, 201, w.Code)
+	var championship models.League
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &championship))
+	lowerPath := "/api/leagues/" + strconv.Itoa(championship.ID) + "/simulation"
+
+	for _, body := range []string{
+		`{"name": "Too Many Places", "league_ids": [1, ` + strconv.Itoa(championship.ID) + `], "places": 2, "playoffs": true}`,
+		`{"name": "Alone", "league_ids": [1], "places": 1}`,
+		`{"name": "Twice", "league_ids": [1, 1], "places": 1}`,
+	} {
+		assert.Equal(t, 400, sendJSON(router, "POST", "/api/pyramids", body).Code, body)
+	}
+
+	w = sendJSON(router, "POST", "/api/pyramids",
+		`{"name": "English Football", "league_ids": [1, `+strconv.Itoa(championship.ID)+`], "places": 1, "playoffs": true}`)
+	assert.Equal(t, 201, w.Code, w.Body.String())
+	var pyramid models.Pyramid
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &pyramid))
+	assert.Equal(t, []models.Division{
+		{Level: 1, LeagueID: 1, Name: "Premier League"},
+		{Level: 2, LeagueID: championship.ID, Name: "Championship"},
+	}, pyramid.Divisions)
+	pyramidPath := "/api/pyramids/" + strconv.Itoa(pyramid.ID)
+
+	// The tables mark the places leading out of each division
+	top := readState(t, sendJSON(router, "GET", "/api/simulation", ""))
+	assert.Equal(t, models.ZoneRelegation, top.Table[3].Zone)
+	assert.Empty(t, top.Table[0].Zone)
+	for _, e := range readState(t, sendJSON(router, "GET", lowerPath, "")).Table {
+		assert.Equal(t, models.ZonePlayoff, e.Zone)
+	}
+
+	assert.Equal(t, 409, sendJSON(router, "POST", pyramidPath+"/next-season", "").Code)
+	assert.Equal(t, 409, sendJSON(router, "DELETE", "/api/leagues/"+strconv.Itoa(championship.ID), "").Code)
+
+	top = readState(t, sendJSON(router, "POST", "/api/simulation/remaining-weeks", ""))
+	sendJSON(router, "POST", lowerPath+"/remaining-weeks", "")
+
+	w = sendJSON(router, "POST", pyramidPath+"/next-season", "")
+	assert.Equal(t, 200, w.Code, w.Body.String())
+	var season models.PyramidSeason
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &season))
+	assert.Len(t, season.Playoffs, 3)
+	if assert.Len(t, season.Movements, 2) {
+		assert.Equal(t, models.Movement{TeamID: top.Table[3].Team.ID, TeamName: top.Table[3].Team.Name,
+			FromLeagueID: 1, ToLeagueID: championship.ID, Zone: models.ZoneRelegation}, season.Movements[0])
+		assert.Equal(t, models.ZonePlayoff, season.Movements[1].Zone)
+
+		// The winner of the final goes up
+		final := season.Playoffs[2]
+		assert.Equal(t, models.PlayoffFinal, final.Round)
+		winner := final.Match.AwayTeam.ID
+		if result := final.Match.Result; result.HomeScore > result.AwayScore ||
+			(result.IsDraw() && result.HomePenalties > result.AwayPenalties) {
+			winner = final.Match.HomeTeam.ID
+		}
+		assert.Equal(t, winner, season.Movements[1].TeamID)
+	}
+
+	// Both divisions start again with their new rosters, their last season archived
+	next := readState(t, sendJSON(router, "GET", "/api/simulation", ""))
+	assert.Equal(t, 1, next.CurrentWeek)
+	assert.Equal(t, 2, next.Season)
+	var teams []int
+	for _, e := range next.Table {
+		teams = append(teams, e.Team.ID)
+	}
+	assert.Contains(t, teams, season.Movements[1].TeamID)
+	assert.NotContains(t, teams, top.Table[3].Team.ID)
+	assert.Len(t, readState(t, sendJSON(router, "GET", lowerPath, "")).Matches, 12)
+
+	w = sendJSON(router, "GET", "/api/simulation/seasons", "")
+	assert.Contains(t, w.Body.String(), `"completed":true`)
+
+	// Unlinked, the leagues carry on without zones
+	assert.Equal(t, 200, sendJSON(router, "DELETE", pyramidPath, "").Code)
+	assert.Equal(t, 404, sendJSON(router, "GET", pyramidPath, "").Code)
+	for _, e := range readState(t, sendJSON(router, "GET", "/api/simulation", "")).Table {
+		assert.Empty(t, e.Zone)
+	}
+}
+
+// newTestPyramid links the default league above a new four-team Championship, returning the IDs of the
+// Championship and the pyramid
+func newTestPyramid(t *testing.T, router *gin.Engine) (int, int) {
+	t.Helper()
+	var lower []string
+	for _, name := range []string{"Leeds", "Burnley", "Sunderland", "Norwich"} {
+		w := sendJSON(router, "POST", "/api/teams", fmt.Sprintf(`{"name": %q, "attributes": {"attack": 0.5}}`, name))
+		var team models.Team
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &team))
+		lower = append(lower, strconv.Itoa(team.ID))
+	}
+	w := sendJSON(router, "POST", "/api/leagues", `{"name": "Championship", "team_ids": [`+strings.Join(lower, ", ")+`]}`)
+	var championship models.League
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &championship))
+	w = sendJSON(router, "POST", "/api/pyramids",
+		`{"name": "English Football", "league_ids": [1, `+strconv.Itoa(championship.ID)+`], "places": 1}`)
+	var pyramid models.Pyramid
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &pyramid))
+	return championship.ID, pyramid.ID
+}
+
+// leagueRosters returns the team IDs of every league by league ID
+func leagueRosters(t *testing.T, router *gin.Engine) map[int][]int {
+	t.Helper()
+	w := sendJSON(router, "GET", "/api/leagues", "")
+	var leagues []models.League
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &leagues))
+	rosters := make(map[int][]int)
+	for _, league := range leagues {
+		for _, team := range league.Teams {
+			rosters[league.ID] = append(rosters[league.ID], team.ID)
+		}
+	}
+	return rosters
+}
+
+// A relegated team stays in the division it went down to when the server starts again
+func TestIntegration_RestartKeepsPyramidRosters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "league.db")
+	router, db := restartTestRouter(t, path, nil)
+	championshipID, pyramidID := newTestPyramid(t, router)
+
+	sendJSON(router, "POST", "/api/simulation/remaining-weeks", "")
+	sendJSON(router, "POST", "/api/leagues/"+strconv.Itoa(championshipID)+"/simulation/remaining-weeks", "")
+	w := sendJSON(router, "POST", "/api/pyramids/"+strconv.Itoa(pyramidID)+"/next-season", "")
+	assert.Equal(t, 200, w.Code, w.Body.String())
+	before := leagueRosters(t, router)
+
+	router, _ = restartTestRouter(t, path, db)
+	after := leagueRosters(t, router)
+	assert.Equal(t, before, after)
+
+	divisionOf := make(map[int]int)
+	for leagueID, teamIDs := range after {
+		for _, teamID := range teamIDs {
+			_, twice := divisionOf[teamID]
+			assert.False(t, twice, "team %d plays in two divisions", teamID)
+			divisionOf[teamID] = leagueID
+		}
+	}
+}
+
+// A division failing to start its next season leaves every division of the pyramid where it was
+func TestIntegration_NextSeasonRollsBack(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "league.db")
+	router, _ := restartTestRouter(t, path, nil)
+	championshipID, pyramidID := newTestPyramid(t, router)
+	lowerPath := "/api/leagues/" + strconv.Itoa(championshipID) + "/simulation"
+
+	sendJSON(router, "POST", "/api/simulation/remaining-weeks", "")
+	sendJSON(router, "POST", lowerPath+"/remaining-weeks", "")
+	rosters := leagueRosters(t, router)
+	top := readState(t, sendJSON(router, "GET", "/api/simulation", ""))
+	lower := readState(t, sendJSON(router, "GET", lowerPath, ""))
+	archive := sendJSON(router, "GET", "/api/simulation/seasons", "").Body.String()
+
+	// Fail the next schedule of the Championship, which moves on after the top division
+	raw, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	_, err = raw.Exec(fmt.Sprintf(`
+	CREATE TRIGGER reject_schedule BEFORE INSERT ON matches WHEN NEW.league_id = %d
+	BEGIN SELECT RAISE(ABORT, 'schedule rejected'); END;
+	`, championshipID))
+	assert.NoError(t, err)
+	assert.NoError(t, raw.Close())
+
+	w := sendJSON(router, "POST", "/api/pyramids/"+strconv.Itoa(pyramidID)+"/next-season", "")
+	assert.Equal(t, 500, w.Code)
+
+	assert.Equal(t, rosters, leagueRosters(t, router), "no team has moved")
+	assert.Equal(t, top, readState(t, sendJSON(router, "GET", "/api/simulation", "")), "the top division is unchanged")
+	assert.Equal(t, lower, readState(t, sendJSON(router, "GET", lowerPath, "")))
+	assert.Equal(t, archive, sendJSON(router, "GET", "/api/simulation/seasons", "").Body.String())
+}
+
+func TestIntegration_Ratings(t *testing.T) {
+	router := setupTestRouter(t)
+
+	ratings := func() []models.TeamRating {
+		w := sendJSON(router, "GET", "/api/simulation/ratings", "")
+		assert.Equal(t, 200, w.Code)
+		var ratings []models.TeamRating
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &ratings))
+		return ratings
+	}
+
+	start := ratings()
+	assert.Len(t, start, 4)
+	for _, rating := range start {
+		assert.Equal(t, []float64{rating.Rating}, rating.History, "a new season only has its starting ratings")
+		assert.Zero(t, rating.Change)
+	}
+
+	sendJSON(router, "POST", "/api/simulation/next-week", "")
+	sendJSON(router, "POST", "/api/simulation/next-week", "")
+	played := ratings()
+	total, startTotal := 0.0, 0.0
+	for i, rating := range played {
+		assert.Len(t, rating.History, 3, "a rating per week")
+		assert.InDelta(t, rating.History[2]-rating.History[0], rating.Change, 0.11)
+		total += rating.Rating
+		startTotal += start[i].Rating
+	}
+	assert.InDelta(t, startTotal, total, 0.5, "what one team gains another loses")
+
+	// Editing a result rates it again
+	state := readState(t, sendJSON(router, "GET", "/api/simulation", ""))
+	match := state.Matches[0]
+	for _, m := range state.Matches {
+		if m.Week == 2 {
+			match = m
+		}
+	}
+	body := fmt.Sprintf(`{"match_id": %d, "home_score": %d, "away_score": 0}`, match.ID, match.Result.HomeScore+5)
+	assert.Equal(t, 200, sendJSON(router, "PUT", "/api/simulation/edit-match-result", body).Code)
+	for _, rating := range ratings() {
+		if rating.TeamID == match.HomeTeam.ID {
+			for _, before := range played {
+				if before.TeamID == rating.TeamID {
+					assert.Greater(t, rating.Rating, before.Rating)
+				}
+			}
+		}
+	}
+
+	// A rewind takes the weeks it clears out of the history
+	sendJSON(router, "POST", "/api/simulation/rewind", `{"week": 1}`)
+	for _, rating := range ratings() {
+		assert.Len(t, rating.History, 2)
+	}
+}
+
+func TestIntegration_CalibrateTeams(t *testing.T) {
+	router := setupTestRouter(t)
+
+	// The football-data.co.uk layout, with its extra columns
+	var csv strings.Builder
+	csv.WriteString("Div,Date,HomeTeam,AwayTeam,FTHG,FTAG,FTR\n")
+	for range 5 {
+		csv.WriteString("E0,01/01/2024,Arsenal,Chelsea,3,0,H\n")
+		csv.WriteString("E0,01/01/2024,Chelsea,Luton,1,1,D\n")
+		csv.WriteString("E0,01/01/2024,Luton,Arsenal,0,2,A\n")
+		csv.WriteString("E0,01/01/2024,Chelsea,Arsenal,1,2,A\n")
+		csv.WriteString("E0,01/01/2024,Luton,Chelsea,0,1,A\n")
+		csv.WriteString("E0,01/01/2024,Arsenal,Luton,4,1,H\n")
+	}
+	calibrate := func(query string) (*httptest.ResponseRecorder, models.Calibration) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/teams/calibrate"+query, strings.NewReader(csv.String()))
+		req.Header.Set("Content-Type", "text/csv")
+		router.ServeHTTP(w, req)
+
+		var calibration models.Calibration
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &calibration))
+		return w, calibration
+	}
+	teamCount := func() int {
+		var teams []models.Team
+		assert.NoError(t, json.Unmarshal(sendJSON(router, "GET", "/api/teams", "").Body.Bytes(), &teams))
+		return len(teams)
+	}
+	before := teamCount()
+
+	w, dryRun := calibrate("?dry_run=true")
+	assert.Equal(t, 200, w.Code)
+	assert.False(t, dryRun.Applied)
+	assert.Equal(t, 30, dryRun.Matches)
+	assert.Equal(t, before, teamCount(), "a dry run saves nothing")
+	if assert.Len(t, dryRun.Teams, 3) {
+		arsenal, chelsea, luton := dryRun.Teams[0], dryRun.Teams[1], dryRun.Teams[2]
+		assert.False(t, arsenal.Created)
+		assert.True(t, luton.Created)
+		assert.Equal(t, 20, arsenal.Matches)
+		assert.Greater(t, arsenal.Team.Attributes.Attack, chelsea.Team.Attributes.Attack)
+		assert.Greater(t, chelsea.Team.Attributes.Defense, luton.Team.Attributes.Defense)
+	}
+
+	w, applied := calibrate("")
+	assert.Equal(t, 200, w.Code)
+	assert.True(t, applied.Applied)
+	assert.Equal(t, before+1, teamCount())
+	assert.NotZero(t, applied.Teams[2].Team.ID, "the created team has its ID")
+
+	w = sendJSON(router, "GET", "/api/teams/"+strconv.Itoa(applied.Teams[0].Team.ID), "")
+	var arsenal models.Team
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &arsenal))
+	assert.Equal(t, applied.Teams[0].Team.Attributes, arsenal.Attributes)
+
+	// Rows that cannot be fitted are reported
+	w = sendJSON(router, "POST", "/api/teams/calibrate",
+		`[{"home_team": "Arsenal", "away_team": "Arsenal", "home_score": 1, "away_score": 0}]`)
+	assert.Equal(t, 400, w.Code)
+	assert.Equal(t, 400, sendJSON(router, "POST", "/api/teams/calibrate?dry_run=maybe", `[]`).Code)
+}
+
+func TestIntegration_MatchPredictions(t *testing.T) {
+	router := setupTestRouter(t)
+	sendJSON(router, "POST", "/api/simulation/next-week", "")
+
+	w := sendJSON(router, "GET", "/api/simulation/predictions", "")
+	assert.Equal(t, 200, w.Code)
+	var predictions []models.FixturePrediction
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &predictions))
+
+	state := readState(t, sendJSON(router, "GET", "/api/simulation", ""))
+	unplayed := 0
+	for _, match := range state.Matches {
+		if !match.IsPlayed {
+			unplayed++
+		}
+	}
+	assert.Len(t, predictions, unplayed, "one per unplayed match")
+
+	first := predictions[0]
+	assert.False(t, first.Match.IsPlayed)
+	assert.Nil(t, first.Prediction.Scorelines, "listings leave the grid out")
+	assert.InDelta(t, 1.0, first.Prediction.HomeWin+first.Prediction.Draw+first.Prediction.AwayWin, 1e-9)
+	assert.Len(t, first.Prediction.MostLikelyScores, 5)
+	assert.Len(t, first.Prediction.Goals, 5)
+
+	w = sendJSON(router, "GET", "/api/simulation/matches/"+strconv.Itoa(first.Match.ID)+"/prediction", "")
+	assert.Equal(t, 200, w.Code)
+	var single models.FixturePrediction
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &single))
+	assert.NotEmpty(t, single.Prediction.Scorelines)
+	assert.Equal(t, first.Prediction.HomeWin, single.Prediction.HomeWin)
+
+	// Played and unknown matches have nothing to predict
+	played := state.Matches[0]
+	assert.True(t, played.IsPlayed)
+	assert.Equal(t, 400, sendJSON(router, "GET", "/api/simulation/matches/"+strconv.Itoa(played.ID)+"/prediction", "").Code)
+	assert.Equal(t, 404, sendJSON(router, "GET", "/api/simulation/matches/99999/prediction", "").Code)
+	assert.Equal(t, 400, sendJSON(router, "GET", "/api/simulation/matches/first/prediction", "").Code)
+}
+
+func TestIntegration_Markets(t *testing.T) {
+	router := setupTestRouter(t)
+	sendJSON(router, "POST", "/api/simulation/next-week", "")
+
+	w := sendJSON(router, "GET", "/api/simulation/markets?margin=0.1&iterations=500", "")
+	assert.Equal(t, 200, w.Code)
+	var board models.OddsBoard
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &board))
+	assert.Equal(t, 0.1, board.Margin)
+
+	w = sendJSON(router, "GET", "/api/simulation/predictions", "")
+	var predictions []models.FixturePrediction
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &predictions))
+	if assert.Len(t, board.Matches, len(predictions), "one per unplayed match") {
+		first := board.Matches[0]
+		assert.Equal(t, predictions[0].Match.ID, first.Match.ID)
+		result := first.Markets[0]
+		assert.Equal(t, models.MarketMatchResult, result.Type)
+		assert.Equal(t, predictions[0].Prediction.HomeWin, result.Selections[0].Probability)
+		assert.GreaterOrEqual(t, result.Overround, 1.1)
+		assert.NotEmpty(t, result.Selections[0].Price.Fractional)
+		assert.NotEmpty(t, result.Selections[0].Price.American)
+	}
+	if assert.NotNil(t, board.Outright) {
+		assert.Equal(t, models.MarketOutright, board.Outright.Type)
+		assert.Len(t, board.Outright.Selections, 4)
+	}
+
+	// The default margin comes from the environment
+	w = sendJSON(router, "GET", "/api/simulation/markets?iterations=500", "")
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &board))
+	assert.Equal(t, services.DefaultOddsMargin, board.Margin)
+
+	assert.Equal(t, 400, sendJSON(router, "GET", "/api/simulation/markets?margin=high", "").Code)
+	assert.Equal(t, 400, sendJSON(router, "GET", "/api/simulation/markets?margin=0.9", "").Code)
+	assert.Equal(t, 400, sendJSON(router, "GET", "/api/simulation/markets?iterations=0", "").Code)
+
+	// Nothing is left to price once the season is over
+	sendJSON(router, "POST", "/api/simulation/remaining-weeks", "")
+	w = sendJSON(router, "GET", "/api/simulation/markets", "")
+	assert.Equal(t, 200, w.Code)
+	board = models.OddsBoard{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &board))
+	assert.Empty(t, board.Matches)
+	assert.Nil(t, board.Outright)
+}
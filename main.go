@@ -6,6 +6,7 @@ import (
 
 	"insider/database"
 	"insider/handlers"
+	"insider/models"
 	"insider/services"
 
 	"github.com/gin-gonic/gin"
@@ -36,30 +37,80 @@ func main() {
 
 	log.SetFlags(log.Llongfile)
 
-	teams, err := db.GetTeams()
+	simulator := services.NewMatchSimulatorFromEnv()
+	scheduler := services.NewMatchScheduler()
+	leagueManager := services.NewLeagueManager(db, simulator, scheduler)
+	teamService := services.NewTeamService(db, leagueManager)
+
+	defaultLeague, err := leagueManager.GetLeague(models.DefaultLeagueID)
 	if err != nil {
-		log.Fatal("Failed to retrieve teams from database: ", err)
+		log.Fatal("Failed to load default league: ", err)
 	}
 
-	simulator := services.NewMatchSimulator()
-	table := services.NewLeagueTable(teams)
-	scheduler := services.NewMatchScheduler()
-	predictor := services.NewLeaguePredictor(simulator, table)
-	leagueService := services.NewLeagueService(db, simulator, table, scheduler, predictor)
-
-	if err := leagueService.ResetSimulation(); err != nil {
-		log.Fatal("Failed to initialize league simulation: ", err)
+	// Seasons carry on across restarts, only a league without a schedule yet gets one
+	matches, err := db.GetMatches()
+	if err != nil {
+		log.Fatal("Failed to load default league schedule: ", err)
+	}
+	if len(matches) == 0 {
+		if err := defaultLeague.ResetSimulation(services.NewSeed()); err != nil {
+			log.Fatal("Failed to initialize league simulation: ", err)
+		}
 	}
 
 	router := gin.Default()
 	router.SetTrustedProxies(nil)
 	router.LoadHTMLGlob("templates/*")
 
-	router.GET("/api/simulation", handlers.GetSimulationState(leagueService))
-	router.POST("/api/simulation/next-week", handlers.SimulateNextWeek(leagueService))
-	router.POST("/api/simulation/remaining-weeks", handlers.SimulateRemainingWeeks(leagueService))
-	router.POST("/api/simulation/reset", handlers.ResetSimulation(leagueService))
-	router.PUT("/api/simulation/edit-match-result", handlers.EditMatchResult(leagueService))
+	router.GET("/api/leagues", handlers.GetLeagues(leagueManager))
+	router.POST("/api/leagues", handlers.CreateLeague(leagueManager))
+	router.DELETE("/api/leagues/:id", handlers.DeleteLeague(leagueManager))
+	router.PUT("/api/leagues/:id/teams", handlers.SetLeagueTeams(leagueManager))
+	router.PUT("/api/leagues/:id/rules", handlers.SetLeagueRules(leagueManager))
+
+	router.GET("/api/pyramids", handlers.GetPyramids(leagueManager))
+	router.POST("/api/pyramids", handlers.CreatePyramid(leagueManager))
+	router.GET("/api/pyramids/:id", handlers.GetPyramid(leagueManager))
+	router.DELETE("/api/pyramids/:id", handlers.DeletePyramid(leagueManager))
+	router.POST("/api/pyramids/:id/next-season", handlers.StartNextSeason(leagueManager))
+
+	router.GET("/api/teams", handlers.GetTeams(teamService))
+	router.GET("/api/teams/:id", handlers.GetTeam(teamService))
+	router.POST("/api/teams", handlers.CreateTeam(teamService))
+	router.POST("/api/teams/calibrate", handlers.CalibrateTeams(teamService))
+	router.PUT("/api/teams/:id", handlers.UpdateTeam(teamService))
+	router.DELETE("/api/teams/:id", handlers.DeleteTeam(teamService))
+
+	router.PUT("/api/matches/:id/result", handlers.SetMatchResult(leagueManager))
+	router.DELETE("/api/matches/:id/result", handlers.ClearMatchResult(leagueManager))
+
+	// /api/simulation addresses the default league, /api/leagues/:id/simulation any league
+	for _, prefix := range []string{"/api/simulation", "/api/leagues/:id/simulation"} {
+		sim := router.Group(prefix)
+		sim.GET("", handlers.GetSimulationState(leagueManager))
+		sim.GET("/weeks/:week", handlers.GetWeekState(leagueManager))
+		sim.POST("/next-week", handlers.SimulateNextWeek(leagueManager))
+		sim.POST("/remaining-weeks", handlers.SimulateRemainingWeeks(leagueManager))
+		sim.POST("/until-week", handlers.SimulateUntilWeek(leagueManager))
+		sim.POST("/matches/:matchId/simulate", handlers.SimulateMatch(leagueManager))
+		sim.GET("/matches/:matchId/prediction", handlers.GetMatchPrediction(leagueManager))
+		sim.GET("/predictions", handlers.GetPredictions(leagueManager))
+		sim.GET("/markets", handlers.GetMarkets(leagueManager))
+		sim.POST("/results", handlers.ImportResults(leagueManager))
+		sim.POST("/reset", handlers.ResetSimulation(leagueManager))
+		sim.POST("/rewind", handlers.RewindSimulation(leagueManager))
+		sim.POST("/undo", handlers.UndoSimulation(leagueManager))
+		sim.POST("/what-if", handlers.PredictScenario(leagueManager))
+		sim.GET("/deductions", handlers.GetDeductions(leagueManager))
+		sim.POST("/deductions", handlers.AddDeduction(leagueManager))
+		sim.DELETE("/deductions/:deductionId", handlers.RemoveDeduction(leagueManager))
+		sim.GET("/events", handlers.GetEvents(leagueManager))
+		sim.GET("/seasons", handlers.GetSeasons(leagueManager))
+		sim.GET("/seasons/:season", handlers.GetSeason(leagueManager))
+		sim.GET("/records", handlers.GetRecords(leagueManager))
+		sim.GET("/ratings", handlers.GetRatings(leagueManager))
+		sim.PUT("/edit-match-result", handlers.EditMatchResult(leagueManager))
+	}
 
 	router.GET("/", handlers.ServeIndex())
 
@@ -0,0 +1,20 @@
+package models
+
+// Calibration is the outcome of fitting team strengths to historical results
+type Calibration struct {
+	// Matches is the number of results fitted
+	Matches int `json:"matches"`
+	// LogLikelihood of the results under the fitted strengths
+	LogLikelihood float64 `json:"log_likelihood"`
+	// Applied tells whether the fitted values were written to the teams, a dry run leaves them as they are
+	Applied bool             `json:"applied"`
+	Teams   []CalibratedTeam `json:"teams"`
+}
+
+// CalibratedTeam is a team with its fitted attack, defense and home boost
+type CalibratedTeam struct {
+	Team Team `json:"team"`
+	// Created is set for a team found in the results but not among the teams, which is added
+	Created bool `json:"created"`
+	Matches int  `json:"matches"`
+}
@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+type EventType string
+
+const (
+	EventResultSimulated EventType = "result_simulated"
+	EventResultEdited    EventType = "result_edited"
+	EventResultCleared   EventType = "result_cleared"
+	EventWeekAdvanced    EventType = "week_advanced"
+	EventWeekRewound     EventType = "week_rewound"
+	EventSeasonReset     EventType = "season_reset"
+	EventRulesChanged    EventType = "rules_changed"
+)
+
+// Event is an entry of the audit log of a league. Events are only ever appended.
+type Event struct {
+	ID   int       `json:"id"`
+	Type EventType `json:"type"`
+	// Source is the request that caused the event, e.g. simulate_week, edit_result or undo
+	Source string `json:"source"`
+	// Week is the current week of the league when the event happened
+	Week      int          `json:"week"`
+	MatchID   int          `json:"match_id,omitempty"`
+	OldResult *MatchResult `json:"old_result,omitempty"`
+	NewResult *MatchResult `json:"new_result,omitempty"`
+	// Origin of the new result
+	Origin  Origin `json:"origin,omitempty"`
+	NewWeek int    `json:"new_week,omitempty"`
+	// Seed of the new season, for season_reset
+	Seed *int64 `json:"seed,omitempty"`
+	// Rules of the league from then on, for rules_changed
+	Rules     *LeagueRules `json:"rules,omitempty"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// EventPage is a page of the audit log, newest first
+type EventPage struct {
+	Events []Event `json:"events"`
+	Total  int     `json:"total"`
+	Limit  int     `json:"limit"`
+	Offset int     `json:"offset"`
+}
@@ -1,5 +1,92 @@
 package models
 
+// DefaultLeagueID is the league created on first start, served by the /api/simulation routes
+const DefaultLeagueID = 1
+
+type League struct {
+	ID          int         `json:"id"`
+	Name        string      `json:"name"`
+	CurrentWeek int         `json:"current_week"`
+	MaxWeeks    int         `json:"max_weeks"`
+	Seed        int64       `json:"seed"`
+	Rules       LeagueRules `json:"rules"`
+	Teams       []Team      `json:"teams"`
+}
+
+// LeagueRules are the competition rules a league is played under
+type LeagueRules struct {
+	Points PointsSystem `json:"points"`
+	// Tiebreakers separate teams level on points, in order. Teams level on all of them stay in alphabetical order.
+	Tiebreakers []Tiebreaker `json:"tiebreakers"`
+}
+
+// PointsSystem sets what each result is worth. Bonuses with a zero threshold are disabled.
+type PointsSystem struct {
+	Win  int `json:"win"`
+	Draw int `json:"draw"`
+	Loss int `json:"loss"`
+
+	// GoalsBonus is earned by a side scoring at least GoalsBonusThreshold goals, whatever the result
+	GoalsBonusThreshold int `json:"goals_bonus_threshold"`
+	GoalsBonus          int `json:"goals_bonus"`
+	// MarginBonus is earned by a side winning by at least MarginBonusThreshold goals
+	MarginBonusThreshold int `json:"margin_bonus_threshold"`
+	MarginBonus          int `json:"margin_bonus"`
+
+	// With Shootout, drawn matches are decided on penalties, worth ShootoutWin and ShootoutLoss instead of Draw
+	Shootout     bool `json:"shootout"`
+	ShootoutWin  int  `json:"shootout_win"`
+	ShootoutLoss int  `json:"shootout_loss"`
+}
+
+// DefaultPointsSystem is the usual three points for a win and one for a draw
+var DefaultPointsSystem = PointsSystem{Win: 3, Draw: 1, Loss: 0}
+
+type PointDeduction struct {
+	ID     int    `json:"id"`
+	TeamID int    `json:"team_id"`
+	Points int    `json:"points"`
+	Reason string `json:"reason"`
+	// Week is the week in progress when the deduction was made, it counts in the tables of that week on
+	Week int `json:"week"`
+}
+
+type Tiebreaker string
+
+const (
+	TiebreakerGoalDifference     Tiebreaker = "goal_difference"
+	TiebreakerGoalsFor           Tiebreaker = "goals_for"
+	TiebreakerHeadToHeadPoints   Tiebreaker = "head_to_head_points"
+	TiebreakerHeadToHeadGoalDiff Tiebreaker = "head_to_head_goal_difference"
+	TiebreakerAwayGoals          Tiebreaker = "away_goals"
+	TiebreakerWins               Tiebreaker = "wins"
+	TiebreakerFairPlay           Tiebreaker = "fair_play"
+	TiebreakerDrawingOfLots      Tiebreaker = "drawing_of_lots"
+)
+
+// DefaultTiebreakers are used by leagues that do not choose their own
+var DefaultTiebreakers = []Tiebreaker{TiebreakerGoalDifference, TiebreakerGoalsFor}
+
+// WithDefaults fills in the rules a league left unset
+func (r LeagueRules) WithDefaults() LeagueRules {
+	if r.Points == (PointsSystem{}) {
+		r.Points = DefaultPointsSystem
+	}
+	if len(r.Tiebreakers) == 0 {
+		r.Tiebreakers = DefaultTiebreakers
+	}
+	return r
+}
+
+func (t Tiebreaker) IsValid() bool {
+	switch t {
+	case TiebreakerGoalDifference, TiebreakerGoalsFor, TiebreakerHeadToHeadPoints, TiebreakerHeadToHeadGoalDiff,
+		TiebreakerAwayGoals, TiebreakerWins, TiebreakerFairPlay, TiebreakerDrawingOfLots:
+		return true
+	}
+	return false
+}
+
 type LeagueTableEntry struct {
 	Position     int  `json:"position"`
 	Team         Team `json:"team"`
@@ -10,13 +97,61 @@ type LeagueTableEntry struct {
 	GoalsFor     int  `json:"goals_for"`
 	GoalsAgainst int  `json:"goals_against"`
 	GoalDiff     int  `json:"goal_diff"`
-	Points       int  `json:"points"`
+	Points       int  `json:"points"` // after deductions
+	// PointsDeducted is the total of Deductions, already taken off Points
+	PointsDeducted int              `json:"points_deducted"`
+	Deductions     []PointDeduction `json:"deductions,omitempty"`
+	// Only used to break ties
+	AwayGoalsFor       int `json:"away_goals_for"`
+	DisciplinaryPoints int `json:"disciplinary_points"`
+	// Zone marks the places leading up or down in a division of a pyramid
+	Zone Zone `json:"zone,omitempty"`
 }
 
 type ChampionshipOdds struct {
 	TeamID      int     `json:"team_id"`
 	TeamName    string  `json:"team_name"`
 	Probability float64 `json:"probability"`
+	StdError    float64 `json:"std_error"`
+	CILow       float64 `json:"ci_low"`  // 95% interval
+	CIHigh      float64 `json:"ci_high"` // 95% interval
+}
+
+// TeamProjection describes how a team's season is predicted to end
+type TeamProjection struct {
+	TeamID     int    `json:"team_id"`
+	TeamName   string `json:"team_name"`
+	Iterations int    `json:"iterations"`
+	// PositionProbabilities[i] is the probability of finishing in position i+1
+	PositionProbabilities []float64          `json:"position_probabilities"`
+	PositionStdErrors     []float64          `json:"position_std_errors"`
+	ExpectedPoints        float64            `json:"expected_points"`
+	ExpectedGoalDiff      float64            `json:"expected_goal_diff"`
+	PointsDistribution    PointsDistribution `json:"points_distribution"`
+}
+
+// PointsDistribution summarizes the simulated final points of a team by percentile
+type PointsDistribution struct {
+	Min    int `json:"min"`
+	P10    int `json:"p10"`
+	P25    int `json:"p25"`
+	Median int `json:"median"`
+	P75    int `json:"p75"`
+	P90    int `json:"p90"`
+	Max    int `json:"max"`
+}
+
+// TitleStatus is the exact, points-based state of a team in the title race
+type TitleStatus struct {
+	TeamID     int    `json:"team_id"`
+	TeamName   string `json:"team_name"`
+	Points     int    `json:"points"`
+	MaxPoints  int    `json:"max_points"`
+	Clinched   bool   `json:"clinched"`
+	Eliminated bool   `json:"eliminated"`
+	// MagicNumber is how many more points guarantee the title whatever else happens,
+	// nil when even winning every remaining match is not enough on its own
+	MagicNumber *int `json:"magic_number"`
 }
 
 type WeekSimulation struct {
@@ -25,15 +160,49 @@ type WeekSimulation struct {
 }
 
 type LeagueSimulation struct {
+	LeagueID         int                `json:"league_id"`
 	CurrentWeek      int                `json:"current_week"`
 	MaxWeeks         int                `json:"max_weeks"`
+	Seed             int64              `json:"seed"`
+	Season           int                `json:"season"`
 	Table            []LeagueTableEntry `json:"table"`
 	Matches          []Match            `json:"matches"`
 	ChampionshipOdds []ChampionshipOdds `json:"championship_odds,omitempty"`
+	Projections      []TeamProjection   `json:"projections,omitempty"`
+	TitleRace        []TitleStatus      `json:"title_race"`
+	History          []TeamHistory      `json:"history"`
+	// AsOfWeek is set when the state is a look back at the end of an earlier week
+	AsOfWeek int `json:"as_of_week,omitempty"`
+}
+
+// TeamHistory traces a team through the completed weeks, index 0 being the end of week 1
+type TeamHistory struct {
+	TeamID    int    `json:"team_id"`
+	TeamName  string `json:"team_name"`
+	Positions []int  `json:"positions"`
+	Points    []int  `json:"points"`
+}
+
+// UndoStep holds what the last change to a league overwrote, so it can be put back
+type UndoStep struct {
+	Action      string        `json:"action"`
+	CurrentWeek int           `json:"current_week"`
+	Matches     []MatchRecord `json:"matches"`
+}
+
+// MatchRecord is the stored result of a match, without its teams
+type MatchRecord struct {
+	ID       int         `json:"id"`
+	Result   MatchResult `json:"result"`
+	IsPlayed bool        `json:"is_played"`
+	Origin   Origin      `json:"origin,omitempty"`
 }
 
 type SimulationState struct {
-	ID          int `json:"id"`
-	CurrentWeek int `json:"current_week"`
-	MaxWeeks    int `json:"max_weeks"`
+	LeagueID    int   `json:"league_id"`
+	CurrentWeek int   `json:"current_week"`
+	MaxWeeks    int   `json:"max_weeks"`
+	Seed        int64 `json:"seed"`
+	// Season numbers the seasons of the league from 1, a reset only starts a new one if the last was played
+	Season int `json:"season"`
 }
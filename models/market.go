@@ -0,0 +1,54 @@
+package models
+
+type MarketType string
+
+const (
+	MarketMatchResult  MarketType = "match_result"
+	MarketCorrectScore MarketType = "correct_score"
+	MarketOverUnder    MarketType = "over_under"
+	MarketOutright     MarketType = "outright"
+)
+
+// Market is a set of prices on the outcomes of a match or of the season, one of which wins
+type Market struct {
+	Type MarketType `json:"type"`
+	// Line is the total goals an over/under market is about
+	Line float64 `json:"line,omitempty"`
+	// Overround is the sum of the probabilities implied by the prices, 1 plus the margin of the book
+	Overround  float64     `json:"overround"`
+	Selections []Selection `json:"selections"`
+}
+
+type Selection struct {
+	Name string `json:"name"`
+	// TeamID is the team of an outright selection
+	TeamID int `json:"team_id,omitempty"`
+	// Probability is the fair chance of the selection, before the margin
+	Probability float64 `json:"probability"`
+	// Price is left out for a selection that cannot win, which is not offered
+	Price *Price `json:"price,omitempty"`
+}
+
+// Price is what a selection pays, in the three usual formats
+type Price struct {
+	// Decimal is the return per unit staked, stake included
+	Decimal float64 `json:"decimal"`
+	// Fractional is the profit per stake, e.g. "5/2"
+	Fractional string `json:"fractional"`
+	// American is the profit on a 100 stake when positive, the stake needed to win 100 when negative
+	American string `json:"american"`
+}
+
+// MatchMarkets are the markets on an unplayed match
+type MatchMarkets struct {
+	Match   Match    `json:"match"`
+	Markets []Market `json:"markets"`
+}
+
+// OddsBoard prices the unplayed matches of a league and its title
+type OddsBoard struct {
+	Margin  float64        `json:"margin"`
+	Matches []MatchMarkets `json:"matches"`
+	// Outright prices the title, left out once the season is over
+	Outright *Market `json:"outright,omitempty"`
+}
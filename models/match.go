@@ -3,6 +3,12 @@ package models
 type MatchResult struct {
 	HomeScore int `json:"home_score"`
 	AwayScore int `json:"away_score"`
+	// Disciplinary points for the fair play ranking, one per yellow card and three per red
+	HomeDiscipline int `json:"home_discipline"`
+	AwayDiscipline int `json:"away_discipline"`
+	// Penalty shootout of a drawn match, only counted in leagues that award shootout points
+	HomePenalties int `json:"home_penalties,omitempty"`
+	AwayPenalties int `json:"away_penalties,omitempty"`
 }
 
 type Match struct {
@@ -12,6 +18,54 @@ type Match struct {
 	AwayTeam *Team       `json:"away_team"`
 	Result   MatchResult `json:"result"`
 	IsPlayed bool        `json:"is_played"`
+	// Origin tells where the result of a played match came from
+	Origin Origin `json:"origin,omitempty"`
+	// Outcome constrains an unplayed match in a what-if scenario, simulations only produce results with it
+	Outcome Outcome `json:"outcome,omitempty"`
+}
+
+type Origin string
+
+const (
+	OriginSimulated Origin = "simulated"
+	OriginManual    Origin = "manual"
+	OriginImported  Origin = "imported"
+)
+
+type Outcome string
+
+const (
+	OutcomeHomeWin Outcome = "home_win"
+	OutcomeDraw    Outcome = "draw"
+	OutcomeAwayWin Outcome = "away_win"
+)
+
+func (o Outcome) IsValid() bool {
+	switch o {
+	case OutcomeHomeWin, OutcomeDraw, OutcomeAwayWin:
+		return true
+	}
+	return false
+}
+
+// Scenario fixes a hypothetical result for an unplayed match, either an exact score or only its outcome
+type Scenario struct {
+	MatchID   int     `json:"match_id"`
+	HomeScore *int    `json:"home_score,omitempty"`
+	AwayScore *int    `json:"away_score,omitempty"`
+	Outcome   Outcome `json:"outcome,omitempty"`
+}
+
+// ResultEntry is one row of a batch of results, naming its match either by ID or by its two teams
+type ResultEntry struct {
+	MatchID   int    `json:"match_id,omitempty"`
+	HomeTeam  string `json:"home_team,omitempty"`
+	AwayTeam  string `json:"away_team,omitempty"`
+	HomeScore *int   `json:"home_score"`
+	AwayScore *int   `json:"away_score"`
+	// Penalty shootout of a draw, see MatchResult
+	HomePenalties int `json:"home_penalties,omitempty"`
+	AwayPenalties int `json:"away_penalties,omitempty"`
 }
 
 func (mr MatchResult) IsWin() bool {
@@ -25,3 +79,14 @@ func (mr MatchResult) IsDraw() bool {
 func (mr MatchResult) IsLoss() bool {
 	return mr.HomeScore < mr.AwayScore
 }
+
+func (mr MatchResult) Outcome() Outcome {
+	switch {
+	case mr.IsWin():
+		return OutcomeHomeWin
+	case mr.IsDraw():
+		return OutcomeDraw
+	default:
+		return OutcomeAwayWin
+	}
+}
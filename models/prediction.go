@@ -0,0 +1,36 @@
+package models
+
+// MatchPrediction is the exact chance of each outcome of a match under the match model, worked out from the
+// expected goals of both sides rather than by simulation
+type MatchPrediction struct {
+	HomeExpectedGoals float64 `json:"home_expected_goals"`
+	AwayExpectedGoals float64 `json:"away_expected_goals"`
+	HomeWin           float64 `json:"home_win"`
+	Draw              float64 `json:"draw"`
+	AwayWin           float64 `json:"away_win"`
+	// MostLikelyScores lists the likeliest scorelines, most likely first
+	MostLikelyScores []ScoreProbability `json:"most_likely_scores"`
+	// Goals gives the chance of more and fewer goals in total than each line
+	Goals            []GoalLine `json:"goals"`
+	BothTeamsToScore float64    `json:"both_teams_to_score"`
+	// Scorelines holds the probability of every scoreline by home then away goals, left out of listings
+	Scorelines [][]float64 `json:"scorelines,omitempty"`
+}
+
+type ScoreProbability struct {
+	HomeScore   int     `json:"home_score"`
+	AwayScore   int     `json:"away_score"`
+	Probability float64 `json:"probability"`
+}
+
+type GoalLine struct {
+	Line  float64 `json:"line"`
+	Over  float64 `json:"over"`
+	Under float64 `json:"under"`
+}
+
+// FixturePrediction is the prediction of an unplayed match
+type FixturePrediction struct {
+	Match      Match           `json:"match"`
+	Prediction MatchPrediction `json:"prediction"`
+}
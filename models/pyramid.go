@@ -0,0 +1,67 @@
+package models
+
+// Pyramid links leagues as divisions one above the other. At the end of a season the bottom teams of each
+// division swap places with the top teams of the division below.
+type Pyramid struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	// Places is how many teams go up and how many go down between two neighbouring divisions
+	Places int `json:"places"`
+	// With Playoffs the last promotion place goes to the winner of a knockout between the four teams
+	// after the automatic ones
+	Playoffs bool `json:"playoffs"`
+	// Divisions from the top down
+	Divisions []Division `json:"divisions"`
+}
+
+type Division struct {
+	// Level 1 is the top division
+	Level    int    `json:"level"`
+	LeagueID int    `json:"league_id"`
+	Name     string `json:"name"`
+}
+
+// TableZones sets how many places at the top and bottom of a table lead out of the division
+type TableZones struct {
+	Promotion  int `json:"promotion"`
+	Playoff    int `json:"playoff"`
+	Relegation int `json:"relegation"`
+}
+
+type Zone string
+
+const (
+	ZonePromotion  Zone = "promotion"
+	ZonePlayoff    Zone = "playoff"
+	ZoneRelegation Zone = "relegation"
+)
+
+// PyramidSeason is how a season of a pyramid ended and the next one was made up
+type PyramidSeason struct {
+	Pyramid   Pyramid        `json:"pyramid"`
+	Movements []Movement     `json:"movements"`
+	Playoffs  []PlayoffMatch `json:"playoffs,omitempty"`
+}
+
+// Movement is a team changing division between two seasons
+type Movement struct {
+	TeamID       int    `json:"team_id"`
+	TeamName     string `json:"team_name"`
+	FromLeagueID int    `json:"from_league_id"`
+	ToLeagueID   int    `json:"to_league_id"`
+	Zone         Zone   `json:"zone"`
+}
+
+type PlayoffRound string
+
+const (
+	PlayoffSemiFinal PlayoffRound = "semi_final"
+	PlayoffFinal     PlayoffRound = "final"
+)
+
+// PlayoffMatch is a one-off promotion playoff, hosted by the higher placed team. A draw goes to penalties.
+type PlayoffMatch struct {
+	LeagueID int          `json:"league_id"`
+	Round    PlayoffRound `json:"round"`
+	Match    Match        `json:"match"`
+}
@@ -0,0 +1,19 @@
+package models
+
+// Rating is the Elo rating of a team at the end of a week, week 0 holding the rating it started the season with
+type Rating struct {
+	Week   int     `json:"week"`
+	TeamID int     `json:"team_id"`
+	Rating float64 `json:"rating"`
+}
+
+// TeamRating is the current Elo rating of a team with how it moved over the season
+type TeamRating struct {
+	TeamID   int     `json:"team_id"`
+	TeamName string  `json:"team_name"`
+	Rating   float64 `json:"rating"`
+	// Change since the start of the season
+	Change float64 `json:"change"`
+	// History holds the rating at the end of each week, from the start of the season at index 0
+	History []float64 `json:"history"`
+}
@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// Season is a season of a league kept in its history. A season is archived once its last week is played and
+// again with any later change, and when it is reset, so a reset one may be unfinished.
+type Season struct {
+	Number    int   `json:"season"`
+	Seed      int64 `json:"seed"`
+	MaxWeeks  int   `json:"max_weeks"`
+	Completed bool  `json:"completed"`
+	// Champion tops the final table of a completed season
+	Champion   *Team     `json:"champion,omitempty"`
+	ArchivedAt time.Time `json:"archived_at"`
+	// The final table and results, left out of listings
+	Table   []LeagueTableEntry `json:"table,omitempty"`
+	Matches []Match            `json:"matches,omitempty"`
+}
+
+// LeagueRecords are the all-time records of a league over its completed seasons
+type LeagueRecords struct {
+	Seasons      int            `json:"seasons"`
+	Titles       []TitleCount   `json:"titles"`
+	BiggestWins  []RecordWin    `json:"biggest_wins"`
+	MostPoints   []PointsRecord `json:"most_points"`
+	FewestPoints []PointsRecord `json:"fewest_points"`
+}
+
+type TitleCount struct {
+	TeamID   int    `json:"team_id"`
+	TeamName string `json:"team_name"`
+	Titles   int    `json:"titles"`
+	// Seasons lists the seasons won, oldest first
+	Seasons []int `json:"seasons"`
+}
+
+type RecordWin struct {
+	Season int   `json:"season"`
+	Match  Match `json:"match"`
+}
+
+type PointsRecord struct {
+	Season   int    `json:"season"`
+	TeamID   int    `json:"team_id"`
+	TeamName string `json:"team_name"`
+	Points   int    `json:"points"`
+	Played   int    `json:"played"`
+}
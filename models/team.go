@@ -16,11 +16,20 @@ const (
 	PlayStyleBalanced   PlayStyle = "balanced"
 )
 
+// IsValid reports whether the play style is one of the known constants
+func (ps PlayStyle) IsValid() bool {
+	switch ps {
+	case PlayStyleAttacking, PlayStyleDefensive, PlayStylePossession, PlayStyleBalanced:
+		return true
+	}
+	return false
+}
+
 type Team struct {
 	ID         int            `json:"id"`
 	Name       string         `json:"name"`
-	Attributes TeamAttributes `json:"-"`
-	PlayStyle  PlayStyle      `json:"-"`
+	Attributes TeamAttributes `json:"attributes"`
+	PlayStyle  PlayStyle      `json:"play_style"`
 }
 
 func (t *Team) GetOverallRating() float64 {
@@ -0,0 +1,319 @@
+package services
+
+import (
+	"math"
+	"strings"
+
+	"insider/database"
+	"insider/models"
+)
+
+const (
+	// The fit stops once no strength moves by more than this in a round, or after calibrationRounds rounds
+	calibrationTolerance float64 = 1e-7
+	calibrationRounds    int     = 1000
+)
+
+// fixture is a historical result between two teams, by their index in the fit
+type fixture struct {
+	home, away           int
+	homeGoals, awayGoals int
+	matchup              float64 // head-to-head advantage of the home side's play style
+}
+
+// strengths are the parameters of the Poisson model of RandomizedMatchSimulator: a side is expected to score
+// 1 + its attack - the opposing defence, plus its home advantage at home and the matchup of the play styles.
+// attack here is the attack attribute plus half the midfield one.
+type strengths struct {
+	attack, defense, home []float64
+}
+
+// teamStrengths are the strengths the simulator plays teams with
+func teamStrengths(teams []models.Team) strengths {
+	s := strengths{
+		attack:  make([]float64, len(teams)),
+		defense: make([]float64, len(teams)),
+		home:    make([]float64, len(teams)),
+	}
+	for i, team := range teams {
+		s.attack[i] = team.Attributes.Attack + team.Attributes.Midfield*0.5
+		s.defense[i] = team.Attributes.Defense
+		s.home[i] = team.Attributes.HomeBoost
+	}
+	return s
+}
+
+// expectedGoals returns the expected goals of both sides before the simulator holds them between
+// minExpectedGoals and maxExpectedGoals
+func (s strengths) expectedGoals(f fixture) (float64, float64) {
+	home := 1 + s.attack[f.home] - s.defense[f.away] + s.home[f.home] + f.matchup
+	away := 1 + s.attack[f.away] - s.defense[f.home] - f.matchup
+	return home, away
+}
+
+// logLikelihood of the fixtures as the simulator plays them, with expected goals held within their bounds and
+// every score from maxSimulatedGoals up counted as maxSimulatedGoals
+func (s strengths) logLikelihood(fixtures []fixture) float64 {
+	total := 0.0
+	for _, f := range fixtures {
+		home, away := s.expectedGoals(f)
+		total += cappedPoissonLogProbability(f.homeGoals, clampExpectedGoals(home)) +
+			cappedPoissonLogProbability(f.awayGoals, clampExpectedGoals(away))
+	}
+	return total
+}
+
+// Calibrate fits the attack, defense and home boost of every team in the results by maximum likelihood, under
+// the Poisson model of the simulator, and unless dryRun is set writes them to the teams. Teams are found by
+// name, those not found are created with a balanced play style. Midfield and play styles are kept as they are.
+func (ts *BasicTeamService) Calibrate(entries []models.ResultEntry, dryRun bool) (*models.Calibration, error) {
+	if len(entries) == 0 {
+		return nil, &ValidationError{Message: "there are no results to fit"}
+	}
+
+	existing, err := ts.db.GetAllTeams()
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]models.Team, len(existing))
+	for _, team := range existing {
+		byName[strings.ToLower(team.Name)] = team
+	}
+
+	var calibrated []models.CalibratedTeam
+	index := make(map[string]int) // lower case name -> index in calibrated
+	teamIndex := func(name string) int {
+		key := strings.ToLower(name)
+		if i, ok := index[key]; ok {
+			return i
+		}
+		team, ok := byName[key]
+		if !ok {
+			team = models.Team{
+				Name:       name,
+				Attributes: models.TeamAttributes{Midfield: 0.5},
+				PlayStyle:  models.PlayStyleBalanced,
+			}
+		}
+		index[key] = len(calibrated)
+		calibrated = append(calibrated, models.CalibratedTeam{Team: team, Created: !ok})
+		return index[key]
+	}
+
+	matchups := NewMatchSimulator().(*RandomizedMatchSimulator)
+	var fixtures []fixture
+	var rowErrors []RowError
+	for i, entry := range entries {
+		homeName, awayName := strings.TrimSpace(entry.HomeTeam), strings.TrimSpace(entry.AwayTeam)
+		switch {
+		case homeName == "" || awayName == "":
+			rowErrors = append(rowErrors, RowError{Row: i + 1, Message: "home_team and away_team are required"})
+			continue
+		case strings.EqualFold(homeName, awayName):
+			rowErrors = append(rowErrors, RowError{Row: i + 1, Message: "a team cannot play itself"})
+			continue
+		case entry.HomeScore == nil || entry.AwayScore == nil:
+			rowErrors = append(rowErrors, RowError{Row: i + 1, Message: "home_score and away_score are required"})
+			continue
+		case *entry.HomeScore < 0 || *entry.AwayScore < 0:
+			rowErrors = append(rowErrors, RowError{Row: i + 1, Message: "scores cannot be negative"})
+			continue
+		}
+
+		home, away := teamIndex(homeName), teamIndex(awayName)
+		calibrated[home].Matches++
+		calibrated[away].Matches++
+		fixtures = append(fixtures, fixture{
+			home:      home,
+			away:      away,
+			homeGoals: *entry.HomeScore,
+			awayGoals: *entry.AwayScore,
+			matchup:   matchups.calculateHeadToHeadMatchup(calibrated[home].Team, calibrated[away].Team),
+		})
+	}
+	if len(rowErrors) > 0 {
+		return nil, &BatchError{Rows: rowErrors}
+	}
+
+	teams := make([]models.Team, len(calibrated))
+	for i, c := range calibrated {
+		teams[i] = c.Team
+	}
+	fitted := fitStrengths(teams, fixtures)
+	for i := range calibrated {
+		attrs := &calibrated[i].Team.Attributes
+		attrs.Attack = clampAttribute(fitted.attack[i] - attrs.Midfield*0.5)
+		attrs.Defense = clampAttribute(fitted.defense[i])
+		attrs.HomeBoost = clampAttribute(fitted.home[i])
+		teams[i] = calibrated[i].Team
+	}
+
+	calibration := &models.Calibration{
+		Matches: len(fixtures),
+		// Of the values written, after rounding, rather than of the fit itself
+		LogLikelihood: teamStrengths(teams).logLikelihood(fixtures),
+		Applied:       !dryRun,
+		Teams:         calibrated,
+	}
+	if dryRun {
+		return calibration, nil
+	}
+
+	err = ts.db.Transaction(func(tx database.Database) error {
+		for i := range calibration.Teams {
+			team := &calibration.Teams[i].Team
+			if !calibration.Teams[i].Created {
+				if err := tx.UpdateTeam(*team); err != nil {
+					return err
+				}
+				continue
+			}
+			teamID, err := tx.InsertTeam(*team)
+			if err != nil {
+				return err
+			}
+			team.ID = teamID
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ts.leagues.ReloadLeagues()
+	return calibration, nil
+}
+
+// fitStrengths maximizes the likelihood of the fixtures, as the simulator plays them, by Fisher scoring one
+// strength at a time, starting from the attributes of the teams. Every strength stays within the range its
+// attribute allows, and one that would push expected goals past the bounds the simulator holds them to stops
+// there. Raising every attack and defence alike changes nothing, so the result is shifted to keep the average
+// defence of the teams where it was, which keeps them comparable with the teams left out of the fit, and the
+// strengths the shift takes out of range are held at its end while the others are fitted around them.
+func fitStrengths(teams []models.Team, fixtures []fixture) strengths {
+	s := teamStrengths(teams)
+	meanDefense := 0.0
+	for _, team := range teams {
+		meanDefense += team.Attributes.Defense / float64(len(teams))
+	}
+	// Attack strengths carry half the midfield, which the fit keeps
+	attackRange := func(t int) (float64, float64) {
+		floor := teams[t].Attributes.Midfield * 0.5
+		return floor, floor + 1
+	}
+
+	// goals are expected goals depending on a strength, with the goals actually scored
+	type goals struct {
+		expected float64
+		scored   int
+	}
+	// score returns the score and information of the expected goals, and how far they can drop and rise before
+	// the first of them reaches a bound
+	score := func(affected []goals) (float64, float64, float64, float64) {
+		gradient, information, down, up := 0.0, 0.0, math.Inf(1), math.Inf(1)
+		for _, g := range affected {
+			expected := clampExpectedGoals(g.expected)
+			gradient += cappedPoissonScore(g.scored, expected)
+			information += 1 / expected
+			down = math.Min(down, g.expected-minExpectedGoals)
+			up = math.Min(up, maxExpectedGoals-g.expected)
+		}
+		return gradient, information, math.Max(down, 0), math.Max(up, 0)
+	}
+
+	// step moves a strength within [low, high] by a Fisher scoring step, raising or, with sign -1, lowering the
+	// goals it affects
+	step := func(value *float64, sign, low, high float64, affected []goals) float64 {
+		if len(affected) == 0 {
+			return 0
+		}
+		gradient, information, down, up := score(affected)
+		change := math.Min(math.Max(gradient/information, -down), up)
+		next := math.Min(math.Max(*value+sign*change, low), high)
+		moved := math.Abs(next - *value)
+		*value = next
+		return moved
+	}
+
+	fit := func() {
+		for range calibrationRounds {
+			moved := 0.0
+			for t := range teams {
+				var scoring, conceding, atHome []goals
+				for _, f := range fixtures {
+					home, away := s.expectedGoals(f)
+					switch t {
+					case f.home:
+						scoring = append(scoring, goals{home, f.homeGoals})
+						conceding = append(conceding, goals{away, f.awayGoals})
+					case f.away:
+						scoring = append(scoring, goals{away, f.awayGoals})
+						conceding = append(conceding, goals{home, f.homeGoals})
+					}
+				}
+				low, high := attackRange(t)
+				moved = math.Max(moved, step(&s.attack[t], 1, low, high, scoring))
+				moved = math.Max(moved, step(&s.defense[t], -1, 0, 1, conceding))
+
+				atHome = atHome[:0]
+				for _, f := range fixtures {
+					if f.home == t {
+						home, _ := s.expectedGoals(f)
+						atHome = append(atHome, goals{home, f.homeGoals})
+					}
+				}
+				moved = math.Max(moved, step(&s.home[t], 1, 0, 1, atHome))
+			}
+			if moved < calibrationTolerance {
+				return
+			}
+		}
+	}
+
+	fit()
+	fittedMean := 0.0
+	for t := range teams {
+		fittedMean += s.defense[t] / float64(len(teams))
+	}
+	clamped := false
+	for t := range teams {
+		low, high := attackRange(t)
+		attack := s.attack[t] + meanDefense - fittedMean
+		defense := s.defense[t] + meanDefense - fittedMean
+		s.attack[t] = math.Min(math.Max(attack, low), high)
+		s.defense[t] = math.Min(math.Max(defense, 0), 1)
+		clamped = clamped || s.attack[t] != attack || s.defense[t] != defense
+	}
+	if clamped {
+		fit()
+	}
+	return s
+}
+
+// cappedPoissonLogProbability is the log-probability of goals in cappedPoissonProbabilities
+func cappedPoissonLogProbability(goals int, expected float64) float64 {
+	if goals < maxSimulatedGoals {
+		return poissonLogProbability(goals, expected)
+	}
+	return math.Log(cappedPoissonProbabilities(expected)[maxSimulatedGoals])
+}
+
+// cappedPoissonScore is the derivative of cappedPoissonLogProbability by the expected goals
+func cappedPoissonScore(goals int, expected float64) float64 {
+	if goals < maxSimulatedGoals {
+		return float64(goals)/expected - 1
+	}
+	// The chance of maxSimulatedGoals or more grows by the chance of exactly one goal fewer
+	probabilities := cappedPoissonProbabilities(expected)
+	return probabilities[maxSimulatedGoals-1] / probabilities[maxSimulatedGoals]
+}
+
+func poissonLogProbability(goals int, expected float64) float64 {
+	logFactorial, _ := math.Lgamma(float64(goals) + 1)
+	return float64(goals)*math.Log(expected) - expected - logFactorial
+}
+
+// clampAttribute keeps a fitted value within the range team attributes allow
+func clampAttribute(value float64) float64 {
+	return math.Round(math.Min(math.Max(value, 0), 1)*1000) / 1000
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"math"
+	"testing"
+
+	"insider/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFitStrengths(t *testing.T) {
+	truth := strengths{
+		attack:  []float64{0.9, 0.7, 0.6, 0.4},
+		defense: []float64{0.7, 0.5, 0.3, 0.5},
+		home:    []float64{0.4, 0.2, 0.3, 0.1},
+	}
+
+	// Every pairing played many times over, with goals drawn from the model itself
+	sim := NewMatchSimulator().WithSeed(5).(*RandomizedMatchSimulator)
+	var fixtures []fixture
+	for range 150 {
+		for home := range 4 {
+			for away := range 4 {
+				if home == away {
+					continue
+				}
+				f := fixture{home: home, away: away}
+				homeExpected, awayExpected := truth.expectedGoals(f)
+				f.homeGoals = sim.simulateGoalsFromExpected(homeExpected)
+				f.awayGoals = sim.simulateGoalsFromExpected(awayExpected)
+				fixtures = append(fixtures, f)
+			}
+		}
+	}
+
+	// Starting from flat values with the true average defence, which the fit keeps
+	teams := make([]models.Team, 4)
+	for i := range teams {
+		teams[i].Attributes = models.TeamAttributes{Attack: 0.5, Defense: 0.5}
+	}
+	fitted := fitStrengths(teams, fixtures)
+
+	for i := range teams {
+		assert.InDelta(t, truth.attack[i], fitted.attack[i], 0.12, "attack of team %d", i)
+		assert.InDelta(t, truth.defense[i], fitted.defense[i], 0.12, "defense of team %d", i)
+		assert.InDelta(t, truth.home[i], fitted.home[i], 0.2, "home advantage of team %d", i)
+	}
+
+	// The fit is a maximum, the truth cannot explain the results better
+	assert.GreaterOrEqual(t, fitted.logLikelihood(fixtures), truth.logLikelihood(fixtures))
+}
+
+func TestFitStrengths_Floor(t *testing.T) {
+	// A side that never scores is held at the lowest expected goals the simulator plays with
+	flat := models.Team{Attributes: models.TeamAttributes{Attack: 0.5, Defense: 0.5}}
+	teams := []models.Team{flat, flat}
+	fixtures := []fixture{
+		{home: 0, away: 1, homeGoals: 2, awayGoals: 0},
+		{home: 1, away: 0, homeGoals: 0, awayGoals: 3},
+	}
+	fitted := fitStrengths(teams, fixtures)
+	_, away := fitted.expectedGoals(fixtures[0])
+	assert.InDelta(t, minExpectedGoals, away, 1e-6)
+}
+
+func TestFitStrengths_Bounds(t *testing.T) {
+	// A rout every week would take the attack of the winners past 1 and the defence of the losers below 0
+	teams := []models.Team{
+		{Attributes: models.TeamAttributes{Attack: 0.5, Defense: 0.5, Midfield: 0.6}},
+		{Attributes: models.TeamAttributes{Attack: 0.5, Defense: 0.5, Midfield: 0.6}},
+	}
+	fixtures := []fixture{
+		{home: 0, away: 1, homeGoals: 9, awayGoals: 0},
+		{home: 1, away: 0, homeGoals: 0, awayGoals: 8},
+	}
+	fitted := fitStrengths(teams, fixtures)
+	for i := range teams {
+		assert.GreaterOrEqual(t, fitted.attack[i], 0.3)
+		assert.LessOrEqual(t, fitted.attack[i], 1.3+1e-9)
+		assert.GreaterOrEqual(t, fitted.defense[i], 0.0)
+		assert.LessOrEqual(t, fitted.defense[i], 1.0)
+		assert.GreaterOrEqual(t, fitted.home[i], 0.0)
+		assert.LessOrEqual(t, fitted.home[i], 1.0)
+	}
+	assert.InDelta(t, 1.3, fitted.attack[0], 1e-9)
+	assert.InDelta(t, 0.0, fitted.defense[1], 1e-9)
+
+	// The simulator never scores more than maxSimulatedGoals, so 9 goals are as likely as 7
+	_, away := fitted.expectedGoals(fixtures[1])
+	assert.Equal(t, cappedPoissonLogProbability(7, away), cappedPoissonLogProbability(9, away))
+	assert.False(t, math.IsInf(fitted.logLikelihood(fixtures), 0))
+}
+
+func TestCappedPoissonScore(t *testing.T) {
+	// The score is the slope of the log-probability, the capped goals included
+	for _, goals := range []int{0, 3, maxSimulatedGoals, 12} {
+		for _, expected := range []float64{0.4, 2.5, 4.8} {
+			h := 1e-6
+			slope := (cappedPoissonLogProbability(goals, expected+h) - cappedPoissonLogProbability(goals, expected-h)) / (2 * h)
+			assert.InEpsilon(t, slope, cappedPoissonScore(goals, expected), 1e-3, "%d goals from %v", goals, expected)
+		}
+	}
+}
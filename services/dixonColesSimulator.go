@@ -0,0 +1,169 @@
+package services
+
+import (
+	"log"
+	"math"
+	"os"
+	"strings"
+
+	"insider/models"
+)
+
+// Match models the simulator can be started with
+const (
+	MatchModelPoisson    = "poisson"
+	MatchModelDixonColes = "dixon_coles"
+	MatchModelElo        = "elo"
+)
+
+const (
+	// dixonColesRho couples the low scores of the two sides; negative values make 0-0 and 1-1 more likely and
+	// 1-0 and 0-1 less, as Dixon and Coles fitted on English league football
+	dixonColesRho float64 = -0.13
+	// dixonColesBaseGoals is the expected goals of a side whose attack strength equals the opposing defence
+	dixonColesBaseGoals float64 = 1.0
+	// Scorelines above this many goals a side are left out of the grid goals are drawn from
+	dixonColesMaxGoals int = 10
+)
+
+// DixonColesMatchSimulator draws scorelines from the Dixon-Coles model: Poisson goals with log-linear attack and
+// defence strengths, corrected for the dependence between the sides in low scoring matches. Bookings and
+// shootouts are drawn as RandomizedMatchSimulator draws them.
+type DixonColesMatchSimulator struct {
+	*RandomizedMatchSimulator
+	rho float64
+}
+
+func NewDixonColesMatchSimulator() MatchSimulator {
+	return &DixonColesMatchSimulator{
+		RandomizedMatchSimulator: NewMatchSimulator().(*RandomizedMatchSimulator),
+		rho:                      dixonColesRho,
+	}
+}
+
+// NewMatchSimulatorFromEnv returns the simulator of the match model named by MATCH_MODEL, falling back to the
+// Poisson model when unset or unknown
+func NewMatchSimulatorFromEnv() MatchSimulator {
+	value := strings.TrimSpace(os.Getenv("MATCH_MODEL"))
+	switch value {
+	case "", MatchModelPoisson:
+		return NewMatchSimulator()
+	case MatchModelDixonColes:
+		return NewDixonColesMatchSimulator()
+	case MatchModelElo:
+		return NewEloMatchSimulator()
+	}
+	log.Printf("Ignoring unknown MATCH_MODEL %q, expected %s, %s or %s", value, MatchModelPoisson, MatchModelDixonColes,
+		MatchModelElo)
+	return NewMatchSimulator()
+}
+
+func (sim *DixonColesMatchSimulator) WithSeed(seed int64) MatchSimulator {
+	return &DixonColesMatchSimulator{
+		RandomizedMatchSimulator: sim.RandomizedMatchSimulator.WithSeed(seed).(*RandomizedMatchSimulator),
+		rho:                      sim.rho,
+	}
+}
+
+func (sim *DixonColesMatchSimulator) SimulateMatch(home, away models.Team) models.MatchResult {
+	homeExpectedGoals, awayExpectedGoals := sim.expectedGoals(home, away)
+	homeGoals, awayGoals := sim.drawScoreline(dixonColesGrid(homeExpectedGoals, awayExpectedGoals, sim.rho))
+
+	result := models.MatchResult{
+		HomeScore:      homeGoals,
+		AwayScore:      awayGoals,
+		HomeDiscipline: sim.simulateDiscipline(home, true),
+		AwayDiscipline: sim.simulateDiscipline(away, false),
+	}
+	if result.IsDraw() {
+		result.HomePenalties, result.AwayPenalties = sim.simulateShootout()
+	}
+	return result
+}
+
+func (sim *DixonColesMatchSimulator) PredictMatch(home, away models.Team) models.MatchPrediction {
+	homeExpectedGoals, awayExpectedGoals := sim.expectedGoals(home, away)
+	grid := dixonColesGrid(homeExpectedGoals, awayExpectedGoals, sim.rho)
+	return predictionFromGrid(homeExpectedGoals, awayExpectedGoals, grid)
+}
+
+// expectedGoals works out the goals each side is expected to score from the strengths of the teams. The attack
+// strength of a team is its attack plus half its midfield, its defence strength its defence, and its home boost
+// is its home advantage, all on the log scale.
+func (sim *DixonColesMatchSimulator) expectedGoals(home, away models.Team) (float64, float64) {
+	strength := func(attacking, defending models.Team, homeAdvantage float64) float64 {
+		attack := attacking.Attributes.Attack + attacking.Attributes.Midfield*0.5
+		expected := dixonColesBaseGoals * math.Exp(attack-defending.Attributes.Defense+homeAdvantage)
+		return math.Min(math.Max(expected, 0.1), 5.0)
+	}
+	return strength(home, away, home.Attributes.HomeBoost), strength(away, home, 0)
+}
+
+// drawScoreline picks a scoreline from the grid by its probability
+func (sim *RandomizedMatchSimulator) drawScoreline(grid [][]float64) (int, int) {
+	target := sim.random.Float64()
+	cumulative := 0.0
+	for homeGoals, row := range grid {
+		for awayGoals, p := range row {
+			cumulative += p
+			if target < cumulative {
+				return homeGoals, awayGoals
+			}
+		}
+	}
+	// Only reachable through rounding, the last cells are the least likely anyway
+	return 0, 0
+}
+
+// dixonColesGrid returns the probability of every scoreline up to dixonColesMaxGoals a side, indexed by home
+// then away goals, for the given expected goals. rho is clamped to the range keeping every probability positive,
+// and the grid is scaled to sum to 1 for the scorelines it leaves out.
+func dixonColesGrid(homeExpectedGoals, awayExpectedGoals, rho float64) [][]float64 {
+	rho = math.Max(rho, math.Max(-1/homeExpectedGoals, -1/awayExpectedGoals))
+	rho = math.Min(rho, math.Min(1/(homeExpectedGoals*awayExpectedGoals), 1))
+
+	homeGoals := poissonProbabilities(homeExpectedGoals, dixonColesMaxGoals)
+	awayGoals := poissonProbabilities(awayExpectedGoals, dixonColesMaxGoals)
+
+	grid := make([][]float64, dixonColesMaxGoals+1)
+	total := 0.0
+	for x := range grid {
+		grid[x] = make([]float64, dixonColesMaxGoals+1)
+		for y := range grid[x] {
+			grid[x][y] = dixonColesTau(x, y, homeExpectedGoals, awayExpectedGoals, rho) * homeGoals[x] * awayGoals[y]
+			total += grid[x][y]
+		}
+	}
+	for x := range grid {
+		for y := range grid[x] {
+			grid[x][y] /= total
+		}
+	}
+	return grid
+}
+
+// dixonColesTau is the correction Dixon and Coles apply to the independent Poisson probability of a scoreline,
+// only scorelines with at most a goal a side are affected
+func dixonColesTau(x, y int, lambda, mu, rho float64) float64 {
+	switch {
+	case x == 0 && y == 0:
+		return 1 - lambda*mu*rho
+	case x == 0 && y == 1:
+		return 1 + lambda*rho
+	case x == 1 && y == 0:
+		return 1 + mu*rho
+	case x == 1 && y == 1:
+		return 1 - rho
+	}
+	return 1
+}
+
+// poissonProbabilities returns the probability of 0 to n goals for the given expected goals
+func poissonProbabilities(expectedGoals float64, n int) []float64 {
+	probabilities := make([]float64, n+1)
+	probabilities[0] = math.Exp(-expectedGoals)
+	for k := 1; k <= n; k++ {
+		probabilities[k] = probabilities[k-1] * expectedGoals / float64(k)
+	}
+	return probabilities
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"testing"
+
+	"insider/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDixonColesGrid(t *testing.T) {
+	independent := dixonColesGrid(1.4, 1.1, 0)
+	corrected := dixonColesGrid(1.4, 1.1, dixonColesRho)
+
+	total := 0.0
+	for _, row := range corrected {
+		for _, p := range row {
+			assert.GreaterOrEqual(t, p, 0.0)
+			total += p
+		}
+	}
+	assert.InDelta(t, 1.0, total, 1e-9)
+
+	// Low scoring draws gain what the 1-0 and 0-1 lose
+	assert.Greater(t, corrected[0][0], independent[0][0])
+	assert.Greater(t, corrected[1][1], independent[1][1])
+	assert.Less(t, corrected[1][0], independent[1][0])
+	assert.Less(t, corrected[0][1], independent[0][1])
+	assert.InDelta(t, independent[2][3]/independent[3][2], corrected[2][3]/corrected[3][2], 1e-9)
+}
+
+func TestDixonColesGrid_ClampsRho(t *testing.T) {
+	// -1 would make the 0-1 impossible for a side expected to score once and negative beyond that
+	grid := dixonColesGrid(2.5, 1.0, -1)
+	for _, row := range grid {
+		for _, p := range row {
+			assert.GreaterOrEqual(t, p, 0.0)
+		}
+	}
+}
+
+func TestDixonColesMatchSimulator_SimulateMatch(t *testing.T) {
+	strong := models.Team{ID: 1, Name: "S", Attributes: models.TeamAttributes{Attack: 0.9, Defense: 0.8, Midfield: 0.8, HomeBoost: 0.3}}
+	weak := models.Team{ID: 2, Name: "W", Attributes: models.TeamAttributes{Attack: 0.4, Defense: 0.4, Midfield: 0.4, HomeBoost: 0.3}}
+
+	sim := NewDixonColesMatchSimulator().WithSeed(11)
+	again := NewDixonColesMatchSimulator().WithSeed(11)
+
+	const n = 20000
+	draws, strongGoals, weakGoals := 0, 0, 0
+	for range n {
+		result := sim.SimulateMatch(strong, weak)
+		assert.Equal(t, result, again.SimulateMatch(strong, weak), "the same seed plays the same matches")
+		if result.IsDraw() {
+			draws++
+			assert.NotEqual(t, result.HomePenalties, result.AwayPenalties)
+		}
+		strongGoals += result.HomeScore
+		weakGoals += result.AwayScore
+	}
+
+	homeExpectedGoals, awayExpectedGoals := sim.(*DixonColesMatchSimulator).expectedGoals(strong, weak)
+	assert.InDelta(t, homeExpectedGoals, float64(strongGoals)/n, 0.05)
+	assert.InDelta(t, awayExpectedGoals, float64(weakGoals)/n, 0.05)
+
+	grid := dixonColesGrid(homeExpectedGoals, awayExpectedGoals, dixonColesRho)
+	drawProbability := 0.0
+	for k := range grid {
+		drawProbability += grid[k][k]
+	}
+	assert.InDelta(t, drawProbability, float64(draws)/n, 0.015)
+}
+
+func TestNewMatchSimulatorFromEnv(t *testing.T) {
+	t.Setenv("MATCH_MODEL", MatchModelDixonColes)
+	assert.IsType(t, &DixonColesMatchSimulator{}, NewMatchSimulatorFromEnv())
+
+	t.Setenv("MATCH_MODEL", MatchModelElo)
+	assert.IsType(t, &EloMatchSimulator{}, NewMatchSimulatorFromEnv())
+
+	t.Setenv("MATCH_MODEL", "")
+	assert.IsType(t, &RandomizedMatchSimulator{}, NewMatchSimulatorFromEnv())
+
+	t.Setenv("MATCH_MODEL", "unknown")
+	assert.IsType(t, &RandomizedMatchSimulator{}, NewMatchSimulatorFromEnv())
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"math"
+	"sort"
+
+	"insider/database"
+	"insider/models"
+)
+
+const (
+	// eloStartRating is the rating of a team whose attributes average 0.5
+	eloStartRating float64 = 1500
+	// eloAttributeScale is the rating a team starts with per point of overall rating above or below 0.5
+	eloAttributeScale float64 = 1000
+	// eloK is the most rating a single match moves before the margin of victory is counted
+	eloK float64 = 20
+	// eloHomeAdvantage is the rating the home side plays with on top of its own
+	eloHomeAdvantage float64 = 100
+)
+
+// startingRating rates a team from its attributes at the start of a season
+func startingRating(team models.Team) float64 {
+	return eloStartRating + eloAttributeScale*(team.GetOverallRating()-0.5)
+}
+
+// eloExpectation is the share of the points the home side is expected to take, 1 for a win and 0.5 for a draw
+func eloExpectation(homeRating, awayRating float64) float64 {
+	return 1 / (1 + math.Pow(10, (awayRating-homeRating-eloHomeAdvantage)/400))
+}
+
+// eloChange is the rating the home side gains, and the away side loses, with result. Wins by two goals count
+// half as much again and wider ones more, as in the World Football Elo Ratings.
+func eloChange(homeRating, awayRating float64, result models.MatchResult) float64 {
+	actual := 0.5
+	switch {
+	case result.HomeScore > result.AwayScore:
+		actual = 1
+	case result.HomeScore < result.AwayScore:
+		actual = 0
+	}
+
+	margin := 1.0
+	switch diff := winMargin(result); {
+	case diff == 2:
+		margin = 1.5
+	case diff > 2:
+		margin = (11 + float64(diff)) / 8
+	}
+	return eloK * margin * (actual - eloExpectation(homeRating, awayRating))
+}
+
+// startingRatings rates the teams of the schedule as they start the season, by team ID
+func startingRatings(matches []models.Match) map[int]float64 {
+	ratings := make(map[int]float64)
+	for _, team := range scheduledTeams(matches) {
+		ratings[team.ID] = startingRating(team)
+	}
+	return ratings
+}
+
+// ratingsBeforeWeek returns the ratings the teams go into week with, from the results of the weeks before it
+func ratingsBeforeWeek(matches []models.Match, week int) map[int]float64 {
+	history := ratingHistory(matches)
+	ratings := startingRatings(matches)
+	for _, rating := range history {
+		if rating.Week < week {
+			ratings[rating.TeamID] = rating.Rating
+		}
+	}
+	return ratings
+}
+
+// ratingHistory replays the played matches from the starting ratings, returning the rating of every team at the
+// start of the season and at the end of each week up to the last one with a result, by week then team. The
+// matches of a week are all rated from where the teams stood before it, so the order they were played in does
+// not matter.
+func ratingHistory(matches []models.Match) []models.Rating {
+	ratings := startingRatings(matches)
+	teamIDs := make([]int, 0, len(ratings))
+	for teamID := range ratings {
+		teamIDs = append(teamIDs, teamID)
+	}
+	sort.Ints(teamIDs)
+
+	byWeek := make(map[int][]models.Match)
+	lastWeek := 0
+	for _, match := range matches {
+		if match.IsPlayed {
+			byWeek[match.Week] = append(byWeek[match.Week], match)
+			lastWeek = max(lastWeek, match.Week)
+		}
+	}
+
+	history := make([]models.Rating, 0, len(teamIDs)*(lastWeek+1))
+	for week := 0; week <= lastWeek; week++ {
+		changes := make(map[int]float64)
+		for _, match := range byWeek[week] {
+			change := eloChange(ratings[match.HomeTeam.ID], ratings[match.AwayTeam.ID], match.Result)
+			changes[match.HomeTeam.ID] += change
+			changes[match.AwayTeam.ID] -= change
+		}
+		for _, teamID := range teamIDs {
+			ratings[teamID] += changes[teamID]
+			history = append(history, models.Rating{Week: week, TeamID: teamID, Rating: ratings[teamID]})
+		}
+	}
+	return history
+}
+
+// syncRatings stores the rating history of the season as it stands after a change
+func (ls *BasicLeagueService) syncRatings(tx database.Database) error {
+	matches, err := tx.GetMatches()
+	if err != nil {
+		return err
+	}
+	return tx.SaveRatings(ratingHistory(matches))
+}
+
+// ratedSimulator returns the simulator to play a match of week with. A simulator playing from ratings gets the
+// ratings the teams go into the week with.
+func (ls *BasicLeagueService) ratedSimulator(db database.Database, week int) (MatchSimulator, error) {
+	if _, ok := ls.matchSimulator.(RatedMatchSimulator); !ok {
+		return ls.matchSimulator, nil
+	}
+
+	matches, err := db.GetMatches()
+	if err != nil {
+		return nil, err
+	}
+	return ls.simulatorForWeek(matches, week), nil
+}
+
+// simulatorForWeek is ratedSimulator for the given matches of the league
+func (ls *BasicLeagueService) simulatorForWeek(matches []models.Match, week int) MatchSimulator {
+	if rated, ok := ls.matchSimulator.(RatedMatchSimulator); ok {
+		return rated.WithRatings(ratingsBeforeWeek(matches, week))
+	}
+	return ls.matchSimulator
+}
+
+// GetRatings returns the current Elo ratings of the teams of the league, highest first, with their history
+// over the season
+func (ls *BasicLeagueService) GetRatings() ([]models.TeamRating, error) {
+	matches, err := ls.db.GetMatches()
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := ls.db.GetRatings()
+	if err != nil {
+		return nil, err
+	}
+	// A league left untouched since ratings were introduced has none stored yet
+	if len(history) == 0 {
+		history = ratingHistory(matches)
+	}
+
+	names := make(map[int]string)
+	for _, team := range scheduledTeams(matches) {
+		names[team.ID] = team.Name
+	}
+
+	rows := make(map[int]int) // team ID -> index in ratings
+	ratings := make([]models.TeamRating, 0)
+	for _, rating := range history {
+		i, ok := rows[rating.TeamID]
+		if !ok {
+			i = len(ratings)
+			rows[rating.TeamID] = i
+			ratings = append(ratings, models.TeamRating{TeamID: rating.TeamID, TeamName: names[rating.TeamID]})
+		}
+		ratings[i].History = append(ratings[i].History, roundRating(rating.Rating))
+	}
+
+	for i := range ratings {
+		history := ratings[i].History
+		ratings[i].Rating = history[len(history)-1]
+		ratings[i].Change = roundRating(history[len(history)-1] - history[0])
+	}
+	sort.SliceStable(ratings, func(i, j int) bool { return ratings[i].Rating > ratings[j].Rating })
+	return ratings, nil
+}
+
+// roundRating rounds a rating to one decimal for display, the stored ratings keep their precision
+func roundRating(rating float64) float64 {
+	return math.Round(rating*10) / 10
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"testing"
+
+	"insider/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEloChange(t *testing.T) {
+	// Level sides, home advantage makes the home side the favourite
+	win := eloChange(1500, 1500, models.MatchResult{HomeScore: 1, AwayScore: 0})
+	draw := eloChange(1500, 1500, models.MatchResult{HomeScore: 1, AwayScore: 1})
+	loss := eloChange(1500, 1500, models.MatchResult{HomeScore: 0, AwayScore: 1})
+	assert.InDelta(t, eloK*(1-eloExpectation(1500, 1500)), win, 1e-9)
+	assert.Less(t, draw, 0.0, "a draw costs the favourite")
+	assert.InDelta(t, eloK, win-loss, 1e-9)
+	assert.InDelta(t, win*1.5, eloChange(1500, 1500, models.MatchResult{HomeScore: 2, AwayScore: 0}), 1e-9)
+	assert.InDelta(t, win*14/8, eloChange(1500, 1500, models.MatchResult{HomeScore: 3, AwayScore: 0}), 1e-9)
+}
+
+func TestRatingHistory(t *testing.T) {
+	a := &models.Team{ID: 1, Name: "A", Attributes: models.TeamAttributes{Attack: 0.5, Defense: 0.5, Midfield: 0.5}}
+	b := &models.Team{ID: 2, Name: "B", Attributes: models.TeamAttributes{Attack: 0.8, Defense: 0.8, Midfield: 0.8}}
+	c := &models.Team{ID: 3, Name: "C", Attributes: models.TeamAttributes{Attack: 0.2, Defense: 0.2, Midfield: 0.2}}
+	matches := []models.Match{
+		{ID: 1, Week: 1, HomeTeam: a, AwayTeam: b, Result: models.MatchResult{HomeScore: 2, AwayScore: 0}, IsPlayed: true},
+		{ID: 2, Week: 2, HomeTeam: b, AwayTeam: c, Result: models.MatchResult{HomeScore: 1, AwayScore: 1}, IsPlayed: true},
+		{ID: 3, Week: 2, HomeTeam: c, AwayTeam: a},
+		{ID: 4, Week: 3, HomeTeam: c, AwayTeam: b},
+	}
+
+	history := ratingHistory(matches)
+	assert.Len(t, history, 9, "three teams from the start up to week 2")
+	assert.Equal(t, models.Rating{Week: 0, TeamID: 1, Rating: 1500}, history[0])
+	assert.InDelta(t, 1800, history[1].Rating, 1e-9)
+	assert.InDelta(t, 1200, history[2].Rating, 1e-9)
+
+	gain := eloChange(1500, history[1].Rating, matches[0].Result)
+	assert.Greater(t, gain, 0.0)
+	assert.Equal(t, models.Rating{Week: 1, TeamID: 1, Rating: 1500 + gain}, history[3])
+	assert.Equal(t, models.Rating{Week: 2, TeamID: 1, Rating: 1500 + gain}, history[6], "no result, no change")
+
+	// Going into week 2 only week 1 counts, and the order of the matches does not matter
+	before := ratingsBeforeWeek(matches, 2)
+	assert.Equal(t, 1500+gain, before[1])
+	assert.Equal(t, history[1].Rating-gain, before[2])
+	assert.Equal(t, history[2].Rating, before[3])
+	assert.Equal(t, history, ratingHistory([]models.Match{matches[3], matches[1], matches[2], matches[0]}))
+}
+
+func TestEloMatchSimulator(t *testing.T) {
+	team := models.Team{ID: 1, Name: "A", Attributes: models.TeamAttributes{Attack: 0.5, Defense: 0.5, Midfield: 0.5}}
+	other := models.Team{ID: 2, Name: "B", Attributes: team.Attributes}
+
+	sim := NewEloMatchSimulator().(*EloMatchSimulator)
+	home, away := sim.expectedGoals(team, other)
+	assert.Greater(t, home, away, "only home advantage between level teams")
+
+	// The ratings given override those from the attributes
+	rated := sim.WithRatings(map[int]float64{1: 1300, 2: 1700}).(*EloMatchSimulator)
+	home, away = rated.expectedGoals(team, other)
+	assert.Less(t, home, away)
+
+	seeded := rated.WithSeed(3).(*EloMatchSimulator)
+	assert.Equal(t, rated.ratings, seeded.ratings, "a seeded copy keeps its ratings")
+
+	const n = 5000
+	homeGoals, awayGoals := 0, 0
+	for range n {
+		result := seeded.SimulateMatch(team, other)
+		homeGoals += result.HomeScore
+		awayGoals += result.AwayScore
+	}
+	assert.InDelta(t, home, float64(homeGoals)/n, 0.1)
+	assert.InDelta(t, away, float64(awayGoals)/n, 0.1)
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"math"
+
+	"insider/models"
+)
+
+const (
+	// eloBaseGoals is the expected goals of each side when their ratings, with home advantage, are level
+	eloBaseGoals float64 = 1.35
+	// eloGoalScale is the rating difference that multiplies the expected goals of the stronger side by e and
+	// divides those of the weaker one by it
+	eloGoalScale float64 = 600
+)
+
+// EloMatchSimulator plays matches from the Elo ratings of the teams: the rating difference, home advantage
+// included, sets the expected goals of each side and the scoreline is drawn from independent Poisson goals.
+// Teams without a rating play from the one their attributes give them. Bookings and shootouts are drawn as
+// RandomizedMatchSimulator draws them.
+type EloMatchSimulator struct {
+	*RandomizedMatchSimulator
+	ratings map[int]float64 // read-only, safe to share
+}
+
+func NewEloMatchSimulator() MatchSimulator {
+	return &EloMatchSimulator{RandomizedMatchSimulator: NewMatchSimulator().(*RandomizedMatchSimulator)}
+}
+
+func (sim *EloMatchSimulator) WithSeed(seed int64) MatchSimulator {
+	return &EloMatchSimulator{
+		RandomizedMatchSimulator: sim.RandomizedMatchSimulator.WithSeed(seed).(*RandomizedMatchSimulator),
+		ratings:                  sim.ratings,
+	}
+}
+
+func (sim *EloMatchSimulator) WithRatings(ratings map[int]float64) MatchSimulator {
+	return &EloMatchSimulator{
+		RandomizedMatchSimulator: sim.RandomizedMatchSimulator,
+		ratings:                  ratings,
+	}
+}
+
+func (sim *EloMatchSimulator) SimulateMatch(home, away models.Team) models.MatchResult {
+	homeExpectedGoals, awayExpectedGoals := sim.expectedGoals(home, away)
+	homeGoals, awayGoals := sim.drawScoreline(dixonColesGrid(homeExpectedGoals, awayExpectedGoals, 0))
+
+	result := models.MatchResult{
+		HomeScore:      homeGoals,
+		AwayScore:      awayGoals,
+		HomeDiscipline: sim.simulateDiscipline(home, true),
+		AwayDiscipline: sim.simulateDiscipline(away, false),
+	}
+	if result.IsDraw() {
+		result.HomePenalties, result.AwayPenalties = sim.simulateShootout()
+	}
+	return result
+}
+
+func (sim *EloMatchSimulator) PredictMatch(home, away models.Team) models.MatchPrediction {
+	homeExpectedGoals, awayExpectedGoals := sim.expectedGoals(home, away)
+	grid := dixonColesGrid(homeExpectedGoals, awayExpectedGoals, 0)
+	return predictionFromGrid(homeExpectedGoals, awayExpectedGoals, grid)
+}
+
+func (sim *EloMatchSimulator) expectedGoals(home, away models.Team) (float64, float64) {
+	difference := sim.rating(home) + eloHomeAdvantage - sim.rating(away)
+	clamp := func(expected float64) float64 { return math.Min(math.Max(expected, 0.1), 5.0) }
+	return clamp(eloBaseGoals * math.Exp(difference/eloGoalScale)), clamp(eloBaseGoals * math.Exp(-difference/eloGoalScale))
+}
+
+func (sim *EloMatchSimulator) rating(team models.Team) float64 {
+	if rating, ok := sim.ratings[team.ID]; ok {
+		return rating
+	}
+	return startingRating(team)
+}
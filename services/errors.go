@@ -0,0 +1,60 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+)
+
+// The handlers map errors to HTTP status codes by kind: the sentinels below either name a missing resource
+// (404) or a request at odds with the state of the league (409). ValidationError rejects a malformed request
+// (400) and PolicyError a well-formed one the league does not allow (422). Anything else is a failure (500).
+var (
+	ErrLeagueNotFound    = errors.New("league not found")
+	ErrTeamNotFound      = errors.New("team not found")
+	ErrMatchNotFound     = errors.New("match not found")
+	ErrDeductionNotFound = errors.New("point deduction not found")
+	ErrSeasonNotFound    = errors.New("season not found in the history of the league")
+	ErrPyramidNotFound   = errors.New("pyramid not found")
+	ErrTeamInUse         = errors.New("team is part of a league, remove it from its leagues first")
+	ErrSeasonInProgress  = errors.New("season is in progress, changing the roster restarts it")
+	ErrNothingToUndo     = errors.New("there is nothing to undo")
+	ErrStaleState        = errors.New("the league has moved on since this request was made, reload it and try again")
+	ErrLeagueInPyramid   = errors.New("league is a division of a pyramid, delete the pyramid first")
+	ErrSeasonNotFinished = errors.New("every division of the pyramid has to finish its season first")
+)
+
+// ValidationError reports a request the service layer refuses to act on
+type ValidationError struct {
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+// BatchError rejects a batch of rows as a whole, listing what is wrong with each offending row (400)
+type BatchError struct {
+	Rows []RowError `json:"rows"`
+}
+
+// RowError is the problem with one row of a batch, numbered from 1
+type RowError struct {
+	Row     int    `json:"row"`
+	Message string `json:"error"`
+}
+
+func (e *BatchError) Error() string {
+	if len(e.Rows) == 1 {
+		return "1 row is invalid, nothing was applied"
+	}
+	return strconv.Itoa(len(e.Rows)) + " rows are invalid, nothing was applied"
+}
+
+// PolicyError reports a well-formed request that the rules of the league do not allow
+type PolicyError struct {
+	Message string
+}
+
+func (e *PolicyError) Error() string {
+	return e.Message
+}
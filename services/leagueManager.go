@@ -0,0 +1,325 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+
+	"insider/database"
+	"insider/models"
+)
+
+type BasicLeagueManager struct {
+	db             database.Database
+	matchSimulator MatchSimulator
+	matchScheduler MatchScheduler
+
+	mu      sync.Mutex
+	leagues map[int]LeagueService
+
+	seasons sync.Mutex // held while a pyramid moves on to its next season
+}
+
+func NewLeagueManager(db database.Database, simulator MatchSimulator, scheduler MatchScheduler) LeagueManager {
+	return &BasicLeagueManager{
+		db:             db,
+		matchSimulator: simulator,
+		matchScheduler: scheduler,
+		leagues:        make(map[int]LeagueService),
+	}
+}
+
+func (lm *BasicLeagueManager) GetLeagues() ([]models.League, error) {
+	return lm.db.GetLeagues()
+}
+
+// GetLeague returns the service of the given league, building it on first use
+func (lm *BasicLeagueManager) GetLeague(leagueID int) (LeagueService, error) {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	if service, ok := lm.leagues[leagueID]; ok {
+		return service, nil
+	}
+
+	service, err := lm.buildLeague(lm.db, leagueID)
+	if err != nil {
+		return nil, err
+	}
+
+	lm.leagues[leagueID] = service
+	return service, nil
+}
+
+// buildLeague builds the service of a league reading from db, which inside a transaction gives a service
+// that works within it and sees its changes, e.g. a new league. Such a service is not cached.
+func (lm *BasicLeagueManager) buildLeague(db database.Database, leagueID int) (*BasicLeagueService, error) {
+	league, err := db.GetLeague(leagueID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return nil, ErrLeagueNotFound
+		}
+		return nil, err
+	}
+
+	var zones models.TableZones
+	pyramid, err := leaguePyramid(db, leagueID)
+	if err != nil {
+		return nil, err
+	}
+	if pyramid != nil {
+		for _, division := range pyramid.Divisions {
+			if division.LeagueID == leagueID {
+				zones = divisionZones(*pyramid, division.Level)
+			}
+		}
+	}
+
+	leagueDB := db.ForLeague(leagueID)
+	table := NewLeagueTable(league.Teams).WithRules(league.Rules).WithZones(zones)
+	predictor := NewLeaguePredictor(lm.matchSimulator, table)
+	service := NewLeagueService(leagueDB, lm.matchSimulator, table, lm.matchScheduler, predictor, NewOddsGenerator())
+	return service.(*BasicLeagueService), nil
+}
+
+func (lm *BasicLeagueManager) GetMatchLeague(matchID int) (LeagueService, error) {
+	leagueID, err := lm.db.GetMatchLeague(matchID)
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, ErrMatchNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return lm.GetLeague(leagueID)
+}
+
+// CreateLeague registers a league for the given teams and generates its first season from seed
+func (lm *BasicLeagueManager) CreateLeague(name string, teamIDs []int, seed int64, rules models.LeagueRules) (*models.League, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, &ValidationError{Message: "league name is required"}
+	}
+
+	if err := lm.validateTeams(teamIDs); err != nil {
+		return nil, err
+	}
+
+	rules = rules.WithDefaults()
+	if err := validateRules(rules); err != nil {
+		return nil, err
+	}
+
+	// The league is only registered along with its first season, so a failed start leaves nothing behind
+	var leagueID int
+	err := lm.db.Transaction(func(tx database.Database) error {
+		leagues, err := tx.GetLeagues()
+		if err != nil {
+			return err
+		}
+		for _, league := range leagues {
+			if strings.EqualFold(league.Name, name) {
+				return &ValidationError{Message: "a league named " + name + " already exists"}
+			}
+		}
+
+		leagueID, err = tx.CreateLeague(name, teamIDs, rules)
+		if err != nil {
+			return err
+		}
+
+		service, err := lm.buildLeague(tx, leagueID)
+		if err != nil {
+			return err
+		}
+		return service.ResetSimulation(seed)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return lm.db.GetLeague(leagueID)
+}
+
+// DeleteLeague removes a league with its schedule and state. The default league backs
+// the /api/simulation routes and cannot be deleted.
+func (lm *BasicLeagueManager) DeleteLeague(leagueID int) error {
+	if leagueID == models.DefaultLeagueID {
+		return &ValidationError{Message: "the default league cannot be deleted"}
+	}
+
+	pyramid, err := leaguePyramid(lm.db, leagueID)
+	if err != nil {
+		return err
+	}
+	if pyramid != nil {
+		return ErrLeagueInPyramid
+	}
+
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	if err := lm.db.DeleteLeague(leagueID); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return ErrLeagueNotFound
+		}
+		return err
+	}
+
+	delete(lm.leagues, leagueID)
+	return nil
+}
+
+func (lm *BasicLeagueManager) SetLeagueTeams(leagueID int, teamIDs []int, restart bool) (*models.League, error) {
+	if err := lm.validateTeams(teamIDs); err != nil {
+		return nil, err
+	}
+
+	if _, err := lm.GetLeague(leagueID); err != nil {
+		return nil, err
+	}
+
+	// The roster and the season it starts change together, or not at all
+	err := lm.db.Transaction(func(tx database.Database) error {
+		// A division must stay clear of the teams of the other divisions and keep room for its zones
+		pyramid, err := leaguePyramid(tx, leagueID)
+		if err != nil {
+			return err
+		}
+		if pyramid != nil {
+			rosters := map[int][]int{leagueID: teamIDs}
+			for _, division := range pyramid.Divisions {
+				if division.LeagueID == leagueID {
+					continue
+				}
+				teams, err := tx.ForLeague(division.LeagueID).GetTeams()
+				if err != nil {
+					return err
+				}
+				rosters[division.LeagueID] = idsOf(teams)
+			}
+			if err := validatePyramid(*pyramid, rosters); err != nil {
+				return err
+			}
+		}
+
+		leagueDB := tx.ForLeague(leagueID)
+		state, err := leagueDB.GetSimulationState()
+		if err != nil {
+			return err
+		}
+
+		// A round-robin cannot absorb roster changes halfway, so results would be lost
+		if !restart {
+			matches, err := leagueDB.GetMatches()
+			if err != nil {
+				return err
+			}
+			for _, match := range matches {
+				if match.IsPlayed {
+					return ErrSeasonInProgress
+				}
+			}
+		}
+
+		if err := leagueDB.SetTeams(teamIDs); err != nil {
+			return err
+		}
+
+		service, err := lm.buildLeague(tx, leagueID)
+		if err != nil {
+			return err
+		}
+		// Keep the seed so the new season stays reproducible
+		return service.ResetSimulation(state.Seed)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	lm.dropLeagues(leagueID)
+	return lm.db.GetLeague(leagueID)
+}
+
+// SetLeagueRules changes the rules of a league. Results are kept, the table and odds are ranked by the
+// new rules from the next request on.
+func (lm *BasicLeagueManager) SetLeagueRules(leagueID int, rules models.LeagueRules, expectedWeek int) (*models.League, error) {
+	rules = rules.WithDefaults()
+	if err := validateRules(rules); err != nil {
+		return nil, err
+	}
+
+	service, err := lm.buildLeague(lm.db, leagueID)
+	if err != nil {
+		return nil, err
+	}
+	service.expectedWeek = expectedWeek
+	if err := service.setRules(rules); err != nil {
+		return nil, err
+	}
+
+	lm.dropLeagues(leagueID)
+	return lm.db.GetLeague(leagueID)
+}
+
+func (lm *BasicLeagueManager) ReloadLeagues() {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	clear(lm.leagues)
+}
+
+func (lm *BasicLeagueManager) validateTeams(teamIDs []int) error {
+	if len(teamIDs) < 2 {
+		return &ValidationError{Message: "a league needs at least two teams"}
+	}
+
+	teams, err := lm.db.GetAllTeams()
+	if err != nil {
+		return err
+	}
+
+	known := make(map[int]bool, len(teams))
+	for _, team := range teams {
+		known[team.ID] = true
+	}
+
+	seen := make(map[int]bool, len(teamIDs))
+	for _, teamID := range teamIDs {
+		if !known[teamID] {
+			return &ValidationError{Message: "unknown team ID " + strconv.Itoa(teamID)}
+		}
+		if seen[teamID] {
+			return &ValidationError{Message: "team ID " + strconv.Itoa(teamID) + " is listed twice"}
+		}
+		seen[teamID] = true
+	}
+	return nil
+}
+
+func validateRules(rules models.LeagueRules) error {
+	points := rules.Points
+	for _, value := range []int{points.Win, points.Draw, points.Loss, points.GoalsBonusThreshold, points.GoalsBonus,
+		points.MarginBonusThreshold, points.MarginBonus, points.ShootoutWin, points.ShootoutLoss} {
+		if value < 0 {
+			return &ValidationError{Message: "points and bonus thresholds cannot be negative"}
+		}
+	}
+	if points.Win <= points.Loss {
+		return &ValidationError{Message: "a win must be worth more than a loss"}
+	}
+	if points.Shootout && points.ShootoutWin < points.ShootoutLoss {
+		return &ValidationError{Message: "a shootout win cannot be worth less than a shootout loss"}
+	}
+
+	seen := make(map[models.Tiebreaker]bool, len(rules.Tiebreakers))
+	for _, tiebreaker := range rules.Tiebreakers {
+		if !tiebreaker.IsValid() {
+			return &ValidationError{Message: "unknown tiebreaker " + string(tiebreaker)}
+		}
+		if seen[tiebreaker] {
+			return &ValidationError{Message: "tiebreaker " + string(tiebreaker) + " is listed twice"}
+		}
+		seen[tiebreaker] = true
+	}
+	return nil
+}
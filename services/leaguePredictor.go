@@ -1,95 +1,396 @@
 package services
 
 import (
-	"math/rand"
+	"log"
+	"math"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
 	"time"
 
 	"insider/models"
 )
 
+const (
+	DefaultPredictorIterations int = 10000 // number of simulations, arbitrary
+	MaxPredictorIterations     int = 1_000_000
+
+	// Iterations run in fixed-size batches, each with its own random source, so a seeded
+	// prediction gives the same numbers however many workers share the batches
+	predictorBatchSize int = 500
+
+	// A match constrained to an outcome is redrawn up to this many times before settling on its narrowest result
+	maxOutcomeAttempts int = 100
+)
+
 type RandomizedPredictor struct {
-	simulator MatchSimulator
-	table     LeagueTable
-	random    *rand.Rand
+	simulator  MatchSimulator
+	table      LeagueTable
+	seed       int64
+	iterations int
 }
 
 func NewLeaguePredictor(simulator MatchSimulator, table LeagueTable) LeaguePredictor {
 	return &RandomizedPredictor{
-		simulator: simulator,
-		table:     table,
-		random:    rand.New(rand.NewSource(time.Now().UnixNano())),
+		simulator:  simulator,
+		table:      table,
+		seed:       time.Now().UnixNano(),
+		iterations: predictorIterationsFromEnv(),
 	}
 }
 
-func (p *RandomizedPredictor) CalculateChampionshipOdds(table []models.LeagueTableEntry, remaining []models.Match) []models.ChampionshipOdds {
-	wins := make(map[int]int, len(table))
-	const iters int = 10000 // number of simulations, arbitrary
+func (p *RandomizedPredictor) WithSeed(seed int64) LeaguePredictor {
+	return &RandomizedPredictor{
+		simulator:  p.simulator,
+		table:      p.table,
+		seed:       seed,
+		iterations: p.iterations,
+	}
+}
 
-	simTable := make(map[int]*models.LeagueTableEntry, len(table))
+func (p *RandomizedPredictor) WithIterations(iterations int) LeaguePredictor {
+	return &RandomizedPredictor{
+		simulator:  p.simulator,
+		table:      p.table,
+		seed:       p.seed,
+		iterations: iterations,
+	}
+}
 
-	for range iters {
-		clear(simTable)
+func (p *RandomizedPredictor) WithTable(table LeagueTable) LeaguePredictor {
+	return &RandomizedPredictor{
+		simulator:  p.simulator,
+		table:      table,
+		seed:       p.seed,
+		iterations: p.iterations,
+	}
+}
 
-		for _, e := range table {
-			copy := e
-			simTable[e.Team.ID] = &copy
+func (p *RandomizedPredictor) WithSimulator(simulator MatchSimulator) LeaguePredictor {
+	return &RandomizedPredictor{
+		simulator:  simulator,
+		table:      p.table,
+		seed:       p.seed,
+		iterations: p.iterations,
+	}
+}
+
+func (p *RandomizedPredictor) CalculateChampionshipOdds(table []models.LeagueTableEntry, matches []models.Match) []models.ChampionshipOdds {
+	return championshipOdds(p.PredictStandings(table, matches))
+}
+
+func (p *RandomizedPredictor) PredictStandings(table []models.LeagueTableEntry, matches []models.Match) []models.TeamProjection {
+	var played, remaining []models.Match
+	for _, m := range matches {
+		if m.IsPlayed {
+			played = append(played, m)
+		} else {
+			remaining = append(remaining, m)
 		}
+	}
 
-		for _, m := range remaining {
-			homeTeam := simTable[m.HomeTeam.ID]
-			awayTeam := simTable[m.AwayTeam.ID]
-
-			result := p.simulator.SimulateMatch(homeTeam.Team, awayTeam.Team)
-			hs, as := result.HomeScore, result.AwayScore
-
-			homeTeam.Played++
-			awayTeam.Played++
-			homeTeam.GoalsFor += hs
-			homeTeam.GoalsAgainst += as
-			awayTeam.GoalsFor += as
-			awayTeam.GoalsAgainst += hs
-
-			if result.IsWin() {
-				homeTeam.Won++
-				awayTeam.Lost++
-				homeTeam.Points += 3
-			} else if result.IsDraw() {
-				homeTeam.Drawn++
-				awayTeam.Drawn++
-				homeTeam.Points += 1
-				awayTeam.Points += 1
-			} else {
-				awayTeam.Won++
-				homeTeam.Lost++
-				awayTeam.Points += 3
+	n := len(table)
+	iterations := p.iterations
+	if iterations <= 0 {
+		iterations = DefaultPredictorIterations
+	}
+
+	rows := make(map[int]int, n) // team ID -> index in table
+	for i, e := range table {
+		rows[e.Team.ID] = i
+	}
+
+	batches := (iterations + predictorBatchSize - 1) / predictorBatchSize
+	tallies := make([]*standingsTally, batches)
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(runtime.GOMAXPROCS(0), batches) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range jobs {
+				size := min(predictorBatchSize, iterations-batch*predictorBatchSize)
+				simulator := p.simulator.WithSeed(deriveSeed(p.seed, int64(batch)))
+				tallies[batch] = p.simulateBatch(simulator, size, table, rows, played, remaining)
 			}
+		}()
+	}
+	for batch := range batches {
+		jobs <- batch
+	}
+	close(jobs)
+	wg.Wait()
+
+	total := newStandingsTally(n)
+	for _, tally := range tallies {
+		total.merge(tally)
+	}
+
+	out := make([]models.TeamProjection, 0, n)
+	for i, e := range table {
+		probabilities := make([]float64, n)
+		stdErrors := make([]float64, n)
+		for pos, count := range total.positions[i] {
+			probabilities[pos] = float64(count) / float64(iterations)
+			stdErrors[pos] = standardError(probabilities[pos], iterations)
 		}
 
-		champID := 0
-		bestPts := -1
-		bestGD := -1_000_000
-		bestGF := -1
-		for _, e := range simTable {
-			gd := e.GoalsFor - e.GoalsAgainst
-			if e.Points > bestPts || (e.Points == bestPts && gd > bestGD) ||
-				(e.Points == bestPts && gd == bestGD && e.GoalsFor > bestGF) {
-				bestPts = e.Points
-				bestGD = gd
-				bestGF = e.GoalsFor
-				champID = e.Team.ID
+		_, sum := total.points[i].total()
+		out = append(out, models.TeamProjection{
+			TeamID:                e.Team.ID,
+			TeamName:              e.Team.Name,
+			Iterations:            iterations,
+			PositionProbabilities: probabilities,
+			PositionStdErrors:     stdErrors,
+			ExpectedPoints:        float64(sum) / float64(iterations),
+			ExpectedGoalDiff:      float64(total.goalDiffs[i]) / float64(iterations),
+			PointsDistribution:    pointsDistribution(&total.points[i]),
+		})
+	}
+	return out
+}
+
+// standingsTally accumulates the final standings of a number of simulated seasons, indexed by table row
+type standingsTally struct {
+	positions [][]int
+	points    []pointsHistogram
+	goalDiffs []int
+}
+
+func newStandingsTally(teams int) *standingsTally {
+	tally := &standingsTally{
+		positions: make([][]int, teams),
+		points:    make([]pointsHistogram, teams),
+		goalDiffs: make([]int, teams),
+	}
+	for i := range teams {
+		tally.positions[i] = make([]int, teams)
+	}
+	return tally
+}
+
+func (t *standingsTally) merge(other *standingsTally) {
+	for i := range t.positions {
+		for pos, count := range other.positions[i] {
+			t.positions[i][pos] += count
+		}
+		t.points[i].merge(&other.points[i])
+		t.goalDiffs[i] += other.goalDiffs[i]
+	}
+}
+
+func (p *RandomizedPredictor) simulateBatch(simulator MatchSimulator, iterations int,
+	table []models.LeagueTableEntry, rows map[int]int, played, remaining []models.Match) *standingsTally {
+	tally := newStandingsTally(len(table))
+	simTable := make([]models.LeagueTableEntry, len(table))
+
+	points := p.rules().Points
+
+	// The season so far followed by the simulated rest, for head-to-head tiebreakers
+	simMatches := make([]models.Match, len(played), len(played)+len(remaining))
+	copy(simMatches, played)
+
+	for range iterations {
+		copy(simTable, table)
+		simMatches = simMatches[:len(played)]
+
+		for _, m := range remaining {
+			homeRow, ok1 := rows[m.HomeTeam.ID]
+			awayRow, ok2 := rows[m.AwayTeam.ID]
+			if !ok1 || !ok2 {
+				continue // Skip if either team entry is missing
 			}
+
+			homeTeam := &simTable[homeRow]
+			awayTeam := &simTable[awayRow]
+
+			m.Result = simulateWithOutcome(simulator, homeTeam.Team, awayTeam.Team, m.Outcome)
+			m.IsPlayed = true
+			applyResult(homeTeam, awayTeam, m.Result, points)
+			simMatches = append(simMatches, m)
+		}
+
+		p.rank(simTable, simMatches)
+
+		for pos, e := range simTable {
+			row := rows[e.Team.ID]
+			tally.positions[row][pos]++
+			tally.points[row].add(e.Points, 1)
+			tally.goalDiffs[row] += e.GoalDiff
+		}
+	}
+	return tally
+}
+
+// rank orders a simulated table exactly as the league table would
+func (p *RandomizedPredictor) rank(table []models.LeagueTableEntry, matches []models.Match) {
+	if p.table == nil {
+		rankStandings(table, matches, p.rules(), 0)
+		return
+	}
+	p.table.Rank(table, matches)
+}
+
+func (p *RandomizedPredictor) rules() models.LeagueRules {
+	if p.table == nil {
+		return models.LeagueRules{}.WithDefaults()
+	}
+	return p.table.Rules()
+}
+
+// simulateWithOutcome plays a match, redrawing until the result has the given outcome if there is one.
+// Rejection keeps the scorelines distributed as the simulator would produce them given that outcome.
+func simulateWithOutcome(simulator MatchSimulator, home, away models.Team, outcome models.Outcome) models.MatchResult {
+	if outcome == "" {
+		return simulator.SimulateMatch(home, away)
+	}
+
+	for range maxOutcomeAttempts {
+		result := simulator.SimulateMatch(home, away)
+		if result.Outcome() == outcome {
+			return result
 		}
-		wins[champID]++
 	}
 
-	out := make([]models.ChampionshipOdds, 0, len(table))
-	for _, e := range table {
-		prob := float64(wins[e.Team.ID]) / float64(iters)
+	switch outcome {
+	case models.OutcomeHomeWin:
+		return models.MatchResult{HomeScore: 1, AwayScore: 0}
+	case models.OutcomeAwayWin:
+		return models.MatchResult{HomeScore: 0, AwayScore: 1}
+	default:
+		return models.MatchResult{HomeScore: 0, AwayScore: 0}
+	}
+}
+
+// championshipOdds reads the title odds off the first-place column of the projections
+func championshipOdds(projections []models.TeamProjection) []models.ChampionshipOdds {
+	out := make([]models.ChampionshipOdds, 0, len(projections))
+	for _, proj := range projections {
+		prob := 0.0
+		if len(proj.PositionProbabilities) > 0 {
+			prob = proj.PositionProbabilities[0]
+		}
+		low, high := wilsonInterval(prob, proj.Iterations)
 		out = append(out, models.ChampionshipOdds{
-			TeamID:      e.Team.ID,
-			TeamName:    e.Team.Name,
+			TeamID:      proj.TeamID,
+			TeamName:    proj.TeamName,
 			Probability: prob,
+			StdError:    standardError(prob, proj.Iterations),
+			CILow:       low,
+			CIHigh:      high,
 		})
 	}
 	return out
 }
+
+// standardError of a probability estimated from n independent simulations
+func standardError(p float64, n int) float64 {
+	if n <= 0 {
+		return 0
+	}
+	return math.Sqrt(p * (1 - p) / float64(n))
+}
+
+// wilsonInterval returns the 95% Wilson score interval of a probability estimated from n simulations.
+// Unlike p ± 1.96·SE it stays inside [0, 1] and does not collapse to a point at 0% or 100%.
+func wilsonInterval(p float64, n int) (float64, float64) {
+	if n <= 0 {
+		return 0, 1
+	}
+
+	const z = 1.959963984540054
+	nf := float64(n)
+	denom := 1 + z*z/nf
+	center := (p + z*z/(2*nf)) / denom
+	margin := z * math.Sqrt(p*(1-p)/nf+z*z/(4*nf*nf)) / denom
+	return math.Max(0, center-margin), math.Min(1, center+margin)
+}
+
+// predictorIterationsFromEnv reads PREDICTOR_ITERATIONS, falling back to the default when unset or invalid
+func predictorIterationsFromEnv() int {
+	value, ok := os.LookupEnv("PREDICTOR_ITERATIONS")
+	if !ok || value == "" {
+		return DefaultPredictorIterations
+	}
+
+	iterations, err := strconv.Atoi(value)
+	if err != nil || iterations < 1 || iterations > MaxPredictorIterations {
+		log.Printf("Ignoring invalid PREDICTOR_ITERATIONS %q, expected 1 to %d", value, MaxPredictorIterations)
+		return DefaultPredictorIterations
+	}
+	return iterations
+}
+
+// pointsHistogram counts the final points of simulated seasons by value, so its size depends on how far apart
+// the points end up rather than on the number of seasons
+type pointsHistogram struct {
+	low    int // the points counts[0] counts, deductions can take them below 0
+	counts []int
+}
+
+func (h *pointsHistogram) add(points, count int) {
+	if count == 0 {
+		return
+	}
+	if len(h.counts) == 0 {
+		h.low = points
+	}
+	if points < h.low {
+		grown := make([]int, len(h.counts)+h.low-points)
+		copy(grown[h.low-points:], h.counts)
+		h.counts, h.low = grown, points
+	}
+	if i := points - h.low; i >= len(h.counts) {
+		h.counts = append(h.counts, make([]int, i-len(h.counts)+1)...)
+	}
+	h.counts[points-h.low] += count
+}
+
+func (h *pointsHistogram) merge(other *pointsHistogram) {
+	for i, count := range other.counts {
+		h.add(other.low+i, count)
+	}
+}
+
+// total returns the number of seasons counted and the sum of their points
+func (h *pointsHistogram) total() (int, int) {
+	seasons, sum := 0, 0
+	for i, count := range h.counts {
+		seasons += count
+		sum += (h.low + i) * count
+	}
+	return seasons, sum
+}
+
+// pointsDistribution summarizes the histogram using nearest-rank percentiles
+func pointsDistribution(h *pointsHistogram) models.PointsDistribution {
+	seasons, _ := h.total()
+	if seasons == 0 {
+		return models.PointsDistribution{}
+	}
+
+	// percentile returns the points of the season ranked p of the way up, the lowest being ranked 1
+	percentile := func(p float64) int {
+		rank := max(int(math.Ceil(p*float64(seasons))), 1)
+		cumulative := 0
+		for i, count := range h.counts {
+			cumulative += count
+			if cumulative >= rank {
+				return h.low + i
+			}
+		}
+		return h.low + len(h.counts) - 1
+	}
+
+	return models.PointsDistribution{
+		Min:    percentile(0),
+		P10:    percentile(0.10),
+		P25:    percentile(0.25),
+		Median: percentile(0.50),
+		P75:    percentile(0.75),
+		P90:    percentile(0.90),
+		Max:    percentile(1),
+	}
+}
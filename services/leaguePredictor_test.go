@@ -1,6 +1,7 @@
 package services
 
 import (
+	"runtime"
 	"testing"
 
 	"insider/models"
@@ -14,6 +15,14 @@ func (noopSim) SimulateMatch(home, away models.Team) models.MatchResult {
 	panic("should not be called when no remaining matches")
 }
 
+func (noopSim) PredictMatch(home, away models.Team) models.MatchPrediction {
+	panic("should not be called by the predictor")
+}
+
+func (s noopSim) WithSeed(int64) MatchSimulator {
+	return s
+}
+
 func TestRandomizedPredictor_NoRemainingMatches(t *testing.T) {
 	// setup a table where A leads B
 	e1 := models.LeagueTableEntry{Team: models.Team{ID: 1, Name: "A"}, Points: 10}
@@ -23,7 +32,6 @@ func TestRandomizedPredictor_NoRemainingMatches(t *testing.T) {
 	pred := &RandomizedPredictor{
 		simulator: noopSim{},
 		table:     nil,
-		random:    nil,
 	}
 
 	odds := pred.CalculateChampionshipOdds(table, nil)
@@ -41,3 +49,131 @@ func TestRandomizedPredictor_NoRemainingMatches(t *testing.T) {
 		}
 	}
 }
+
+type homeWinSim struct{}
+
+func (homeWinSim) SimulateMatch(home, away models.Team) models.MatchResult {
+	return models.MatchResult{HomeScore: 1, AwayScore: 0}
+}
+
+func (homeWinSim) PredictMatch(home, away models.Team) models.MatchPrediction {
+	return models.MatchPrediction{HomeWin: 1}
+}
+
+func (s homeWinSim) WithSeed(int64) MatchSimulator {
+	return s
+}
+
+func TestRandomizedPredictor_PredictStandings(t *testing.T) {
+	a := models.Team{ID: 1, Name: "A"}
+	b := models.Team{ID: 2, Name: "B"}
+	c := models.Team{ID: 3, Name: "C"}
+	table := []models.LeagueTableEntry{
+		{Team: a, Points: 6},
+		{Team: b, Points: 4},
+		{Team: c, Points: 3},
+	}
+
+	// C wins both home games and overtakes everyone, B beats A at home
+	remaining := []models.Match{
+		{HomeTeam: &c, AwayTeam: &a},
+		{HomeTeam: &c, AwayTeam: &b},
+		{HomeTeam: &b, AwayTeam: &a},
+	}
+
+	pred := &RandomizedPredictor{simulator: homeWinSim{}}
+	projections := pred.PredictStandings(table, remaining)
+	assert.Len(t, projections, 3)
+
+	byID := make(map[int]models.TeamProjection)
+	for _, p := range projections {
+		byID[p.TeamID] = p
+		assert.Len(t, p.PositionProbabilities, 3)
+	}
+
+	// final points: A 6, B 7, C 9
+	assert.Equal(t, []float64{0, 0, 1}, byID[1].PositionProbabilities)
+	assert.Equal(t, []float64{0, 1, 0}, byID[2].PositionProbabilities)
+	assert.Equal(t, []float64{1, 0, 0}, byID[3].PositionProbabilities)
+
+	assert.Equal(t, 9.0, byID[3].ExpectedPoints)
+	assert.Equal(t, 2.0, byID[3].ExpectedGoalDiff)
+	assert.Equal(t, -2.0, byID[1].ExpectedGoalDiff)
+	assert.Equal(t, models.PointsDistribution{Min: 7, P10: 7, P25: 7, Median: 7, P75: 7, P90: 7, Max: 7},
+		byID[2].PointsDistribution)
+
+	odds := championshipOdds(projections)
+	assert.Equal(t, 1.0, odds[2].Probability, "odds should follow the first-place column")
+}
+
+func TestPointsDistribution(t *testing.T) {
+	var samples pointsHistogram
+	for _, points := range []int{10, 1, 9, 2, 8, 3, 7, 4, 6, 5} {
+		samples.add(points, 1)
+	}
+	dist := pointsDistribution(&samples)
+
+	assert.Equal(t, models.PointsDistribution{Min: 1, P10: 1, P25: 3, Median: 5, P75: 8, P90: 9, Max: 10}, dist)
+	assert.Equal(t, models.PointsDistribution{}, pointsDistribution(&pointsHistogram{}))
+
+	// Points below the lowest counted so far, even below zero, grow the histogram downwards
+	samples.add(-3, 2)
+	seasons, sum := samples.total()
+	assert.Equal(t, 12, seasons)
+	assert.Equal(t, 55-6, sum)
+	assert.Equal(t, -3, pointsDistribution(&samples).Min)
+	assert.Equal(t, 4, pointsDistribution(&samples).Median)
+}
+
+func TestRandomizedPredictor_SeededBatchesAreReproducible(t *testing.T) {
+	a := models.Team{ID: 1, Name: "A", PlayStyle: models.PlayStyleBalanced}
+	b := models.Team{ID: 2, Name: "B", PlayStyle: models.PlayStyleAttacking}
+	table := []models.LeagueTableEntry{{Team: a, Points: 3}, {Team: b, Points: 3}}
+	remaining := []models.Match{{HomeTeam: &a, AwayTeam: &b}, {HomeTeam: &b, AwayTeam: &a}}
+
+	pred := NewLeaguePredictor(NewMatchSimulator(), nil).WithSeed(99).WithIterations(1234)
+
+	first := pred.PredictStandings(table, remaining)
+	// A single worker takes every batch, restoring whatever the test binary was started with afterwards
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(1))
+	second := pred.PredictStandings(table, remaining)
+
+	assert.Equal(t, first, second, "worker count should not change seeded predictions")
+	assert.Equal(t, 1234, first[0].Iterations)
+	assert.InDelta(t, 1.0, first[0].PositionProbabilities[0]+first[1].PositionProbabilities[0], 1e-9)
+
+	odds := championshipOdds(first)
+	for _, o := range odds {
+		assert.LessOrEqual(t, o.CILow, o.Probability)
+		assert.GreaterOrEqual(t, o.CIHigh, o.Probability)
+		assert.Greater(t, o.StdError, 0.0)
+	}
+}
+
+func TestWilsonInterval(t *testing.T) {
+	low, high := wilsonInterval(0, 10000)
+	assert.Equal(t, 0.0, low)
+	assert.InDelta(t, 0.000384, high, 1e-6, "a 0% estimate is still uncertain")
+
+	low, high = wilsonInterval(0.5, 10000)
+	assert.InDelta(t, 0.5-1.96*standardError(0.5, 10000), low, 1e-4)
+	assert.InDelta(t, 0.5+1.96*standardError(0.5, 10000), high, 1e-4)
+}
+
+func TestRandomizedPredictor_RanksLikeTheTable(t *testing.T) {
+	teams := []models.Team{{ID: 1, Name: "A"}, {ID: 2, Name: "B"}}
+	matches := []models.Match{
+		{HomeTeam: &teams[0], AwayTeam: &teams[1], IsPlayed: true, Result: models.MatchResult{HomeScore: 0, AwayScore: 4}},
+		{HomeTeam: &teams[1], AwayTeam: &teams[0], IsPlayed: true, Result: models.MatchResult{HomeScore: 0, AwayScore: 1, HomeDiscipline: 3}},
+	}
+
+	// A leads on fair play, B would on goal difference
+	rules := models.LeagueRules{Tiebreakers: []models.Tiebreaker{models.TiebreakerFairPlay}}
+	leagueTable := NewLeagueTable(teams).WithRules(rules)
+	table := leagueTable.CalculateTable(matches)
+	assert.Equal(t, "A", table[0].Team.Name)
+
+	projections := NewLeaguePredictor(noopSim{}, nil).WithTable(leagueTable).WithIterations(10).PredictStandings(table, matches)
+	assert.Equal(t, "A", projections[0].TeamName)
+	assert.Equal(t, 1.0, projections[0].PositionProbabilities[0], "the predictor should break ties like the table")
+}
@@ -1,22 +1,49 @@
 package services
 
 import (
+	"errors"
 	"log"
+	"strconv"
+	"strings"
+	"time"
 
 	"insider/database"
 	"insider/models"
 )
 
+// The requests that change a season, logged as the source of its events. All but a reset, a change of rules
+// and an undo itself can be undone.
+const (
+	actionSimulateWeek  string = "simulate_week"
+	actionSimulateMatch string = "simulate_match"
+	actionEditResult    string = "edit_result"
+	actionClearResult   string = "clear_result"
+	actionImportResults string = "import_results"
+	actionRewind        string = "rewind"
+	actionReset         string = "reset"
+	actionUndo          string = "undo"
+	actionSetRules      string = "set_rules"
+)
+
+// Page sizes of the audit log
+const (
+	defaultEventLimit int = 50
+	maxEventLimit     int = 500
+)
+
 type BasicLeagueService struct {
 	db             database.Database
 	matchSimulator MatchSimulator
 	table          LeagueTable
 	matchScheduler MatchScheduler
 	predictor      LeaguePredictor
+	oddsGenerator  OddsGenerator
 	teamMap        map[int]models.Team
+	expectedWeek   int // 0 accepts any week
 }
 
-func NewLeagueService(db database.Database, simulator MatchSimulator, table LeagueTable, scheduler MatchScheduler, predictor LeaguePredictor) LeagueService {
+func NewLeagueService(db database.Database, simulator MatchSimulator, table LeagueTable, scheduler MatchScheduler, predictor LeaguePredictor,
+	oddsGenerator OddsGenerator) LeagueService {
 	teams, err := db.GetTeams()
 	if err != nil {
 		panic("Failed to retrieve teams from database: " + err.Error())
@@ -33,11 +60,16 @@ func NewLeagueService(db database.Database, simulator MatchSimulator, table Leag
 		table:          table,
 		matchScheduler: scheduler,
 		predictor:      predictor,
+		oddsGenerator:  oddsGenerator,
 		teamMap:        teamMap,
 	}
 }
 
-func (ls *BasicLeagueService) GetCurrentState() (*models.LeagueSimulation, error) {
+func (ls *BasicLeagueService) GetCurrentState(options StateOptions) (*models.LeagueSimulation, error) {
+	if err := validateStateOptions(options); err != nil {
+		return nil, err
+	}
+
 	state, err := ls.db.GetSimulationState()
 	if err != nil {
 		return nil, err
@@ -48,125 +80,780 @@ func (ls *BasicLeagueService) GetCurrentState() (*models.LeagueSimulation, error
 		return nil, err
 	}
 
-	table := ls.table.CalculateTable(matches)
-
-	simulation := &models.LeagueSimulation{
-		CurrentWeek: state.CurrentWeek,
-		MaxWeeks:    state.MaxWeeks,
-		Table:       table,
-		Matches:     matches,
+	results, err := ls.resultLog(matches)
+	if err != nil {
+		return nil, err
 	}
 
-	if state.CurrentWeek > 4 {
-		remainingMatches := ls.getRemainingMatches(matches)
-		if len(remainingMatches) > 0 {
-			odds := ls.predictor.CalculateChampionshipOdds(table, remainingMatches)
-			simulation.ChampionshipOdds = odds
+	if options.Week > 0 {
+		if options.Week >= state.CurrentWeek {
+			return nil, &ValidationError{Message: "week " + strconv.Itoa(options.Week) + " has not been completed yet"}
+		}
+
+		// Seen from the end of that week, with the odds seeded as they were back then
+		matches = results.asOfWeek(options.Week)
+		state = &models.SimulationState{
+			LeagueID:    state.LeagueID,
+			CurrentWeek: options.Week + 1,
+			MaxWeeks:    state.MaxWeeks,
+			Seed:        state.Seed,
+			Season:      state.Season,
 		}
 	}
+
+	simulation, err := ls.buildSimulation(state, matches, results, options, oddsShown(state))
+	if err != nil {
+		return nil, err
+	}
+	simulation.AsOfWeek = options.Week
 	return simulation, nil
 }
 
-func (ls *BasicLeagueService) SimulateNextWeek() (*models.WeekSimulation, error) {
+func (ls *BasicLeagueService) PredictScenario(scenarios []models.Scenario, options StateOptions) (*models.LeagueSimulation, error) {
+	if err := validateStateOptions(options); err != nil {
+		return nil, err
+	}
+
 	state, err := ls.db.GetSimulationState()
 	if err != nil {
 		return nil, err
 	}
 
-	if state.CurrentWeek > state.MaxWeeks {
-		sim, err := ls.GetCurrentState()
-		if err != nil {
-			return nil, err
-		}
-		return ls.filterStateByWeek(sim, sim.CurrentWeek-1), nil
+	matches, err := ls.db.GetMatches()
+	if err != nil {
+		return nil, err
 	}
 
-	weekMatches, err := ls.db.GetMatchesForWeek(state.CurrentWeek)
+	results, err := ls.resultLog(matches)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, match := range weekMatches {
-		if !match.IsPlayed {
-			homeTeam := ls.teamMap[match.HomeTeam.ID]
-			awayTeam := ls.teamMap[match.AwayTeam.ID]
+	matches, err = applyScenarios(matches, scenarios)
+	if err != nil {
+		return nil, err
+	}
 
-			result := ls.matchSimulator.SimulateMatch(homeTeam, awayTeam)
+	// Odds are shown whatever the week, that is the point of asking
+	return ls.buildSimulation(state, matches, results, options, true)
+}
 
-			err = ls.db.UpdateMatchResult(match.ID, result)
-			if err != nil {
-				return nil, err
-			}
+// buildSimulation derives the table, title race and, if asked, the predictor output from a set of matches, and
+// the history of the completed weeks from results
+func (ls *BasicLeagueService) buildSimulation(state *models.SimulationState, matches []models.Match,
+	results *resultLog, options StateOptions, predict bool) (*models.LeagueSimulation, error) {
+	deductions, err := ls.db.GetDeductions()
+	if err != nil {
+		return nil, err
+	}
+	if options.Week > 0 {
+		deductions = deductionsAsOfWeek(deductions, options.Week)
+	}
+
+	// Lots are drawn once per season, and the copy keeps concurrent requests from sharing entries
+	leagueTable := ls.table.WithSeed(deriveSeed(state.Seed, seedStreamLots)).WithDeductions(deductions)
+	table := leagueTable.CalculateTable(matches)
+
+	simulation := &models.LeagueSimulation{
+		LeagueID:    state.LeagueID,
+		CurrentWeek: state.CurrentWeek,
+		MaxWeeks:    state.MaxWeeks,
+		Seed:        state.Seed,
+		Season:      state.Season,
+		Table:       table,
+		Matches:     matches,
+	}
+
+	// Outcome constraints are left out of the title race, so it covers a superset of the possible endings
+	// and anything it reports as decided still holds
+	remainingMatches := ls.getRemainingMatches(matches)
+	simulation.TitleRace = AnalyzeTitleRace(table, remainingMatches, leagueTable.Rules().Points)
+
+	if predict && len(remainingMatches) > 0 {
+		// The same seed as the live odds, so a what-if differs from them only by the scenario
+		predictor := ls.predictor.WithSeed(deriveSeed(state.Seed, seedStreamOdds, int64(state.CurrentWeek))).
+			WithTable(leagueTable)
+		// The remaining matches are played from the ratings as they stand after every result so far
+		if rated, ok := ls.matchSimulator.(RatedMatchSimulator); ok {
+			predictor = predictor.WithSimulator(rated.WithRatings(ratingsBeforeWeek(matches, state.MaxWeeks+1)))
 		}
+		if options.Iterations > 0 {
+			predictor = predictor.WithIterations(options.Iterations)
+		}
+		projections := predictor.PredictStandings(table, matches)
+		simulation.Projections = projections
+		simulation.ChampionshipOdds = championshipOdds(projections)
 	}
 
-	err = ls.db.UpdateCurrentWeek(state.CurrentWeek + 1)
+	simulation.History = ls.history(leagueTable, deductions, table, results, state.CurrentWeek-1)
+	return simulation, nil
+}
+
+// history replays the completed weeks one by one, with the results and deductions as they stood by then,
+// listing teams in the order of table
+func (ls *BasicLeagueService) history(leagueTable LeagueTable, deductions []models.PointDeduction,
+	table []models.LeagueTableEntry, results *resultLog, weeks int) []models.TeamHistory {
+	out := make([]models.TeamHistory, len(table))
+	rows := make(map[int]int, len(table))
+	for i, e := range table {
+		out[i] = models.TeamHistory{
+			TeamID:    e.Team.ID,
+			TeamName:  e.Team.Name,
+			Positions: make([]int, 0, weeks),
+			Points:    make([]int, 0, weeks),
+		}
+		rows[e.Team.ID] = i
+	}
+
+	for week := 1; week <= weeks; week++ {
+		weekTable := leagueTable.WithDeductions(deductionsAsOfWeek(deductions, week))
+		for _, e := range weekTable.CalculateTable(results.asOfWeek(week)) {
+			row := rows[e.Team.ID]
+			out[row].Positions = append(out[row].Positions, e.Position)
+			out[row].Points = append(out[row].Points, e.Points)
+		}
+	}
+	return out
+}
+
+func (ls *BasicLeagueService) SimulateNextWeek() (*models.WeekSimulation, error) {
+	var playedWeek int
+	err := ls.change(func(tx database.Database, state *models.SimulationState) error {
+		// Once the season is over the last week is shown again
+		playedWeek = min(state.CurrentWeek, state.MaxWeeks)
+		if state.CurrentWeek > state.MaxWeeks {
+			return nil
+		}
+		return ls.simulateWeek(tx, state)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	sim, err := ls.GetCurrentState()
+	sim, err := ls.GetCurrentState(StateOptions{})
 	if err != nil {
 		return nil, err
 	}
-	return ls.filterStateByWeek(sim, sim.CurrentWeek-1), nil
+	return ls.filterStateByWeek(sim, playedWeek), nil
 }
 
 func (ls *BasicLeagueService) SimulateRemainingWeeks() (*models.LeagueSimulation, error) {
-	state, err := ls.db.GetSimulationState()
+	err := ls.change(func(tx database.Database, state *models.SimulationState) error {
+		if state.CurrentWeek > state.MaxWeeks {
+			log.Println("Simulation already completed")
+			return nil
+		}
+		return ls.simulateUntil(tx, state, state.MaxWeeks)
+	})
 	if err != nil {
 		return nil, err
 	}
+	return ls.GetCurrentState(StateOptions{})
+}
 
-	if state.CurrentWeek > state.MaxWeeks {
-		log.Println("Simulation already completed")
-		return ls.GetCurrentState()
+func (ls *BasicLeagueService) SimulateUntilWeek(week int) (*models.LeagueSimulation, error) {
+	err := ls.change(func(tx database.Database, state *models.SimulationState) error {
+		if state.CurrentWeek > state.MaxWeeks {
+			return &ValidationError{Message: "the season is already completed"}
+		}
+		if week < state.CurrentWeek || week > state.MaxWeeks {
+			return &ValidationError{Message: "week must be between " + strconv.Itoa(state.CurrentWeek) +
+				" and " + strconv.Itoa(state.MaxWeeks)}
+		}
+		return ls.simulateUntil(tx, state, week)
+	})
+	if err != nil {
+		return nil, err
 	}
+	return ls.GetCurrentState(StateOptions{})
+}
 
-	diff := state.MaxWeeks - state.CurrentWeek + 1
+func (ls *BasicLeagueService) SimulateMatch(matchID int) (*models.Match, error) {
+	var match *models.Match
+	err := ls.change(func(tx database.Database, state *models.SimulationState) error {
+		matches, err := tx.GetMatches()
+		if err != nil {
+			return err
+		}
+
+		for i := range matches {
+			if matches[i].ID == matchID {
+				match = &matches[i]
+			}
+		}
+		if match == nil {
+			return ErrMatchNotFound
+		}
+
+		if match.IsPlayed {
+			return &ValidationError{Message: "match " + strconv.Itoa(matchID) + " has already been played"}
+		}
+		if match.Week > state.CurrentWeek {
+			return &ValidationError{Message: "only matches up to the current week (" + strconv.Itoa(state.CurrentWeek) +
+				") can be simulated"}
+		}
 
-	for range diff {
-		_, err = ls.SimulateNextWeek()
+		if err := ls.saveUndo(tx, actionSimulateMatch, state, []models.Match{*match}); err != nil {
+			return err
+		}
+
+		result, err := ls.playMatch(tx, state, *match, actionSimulateMatch)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		match.Result = result
+		match.IsPlayed = true
+		return ls.endWeekIfPlayed(tx, state, matches, actionSimulateMatch)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return match, nil
+}
+
+// endWeekIfPlayed moves the league on once every match up to the current week is played
+func (ls *BasicLeagueService) endWeekIfPlayed(tx database.Database, state *models.SimulationState,
+	matches []models.Match, action string) error {
+	if len(dueMatches(matches, state.CurrentWeek)) > 0 {
+		return nil
+	}
+	return ls.moveWeek(tx, state, state.CurrentWeek+1, action)
+}
+
+// dueMatches returns the unplayed matches up to week, which includes those of earlier weeks whose result was cleared
+func dueMatches(matches []models.Match, week int) []models.Match {
+	var due []models.Match
+	for _, m := range matches {
+		if m.Week <= week && !m.IsPlayed {
+			due = append(due, m)
 		}
 	}
-	return ls.GetCurrentState()
+	return due
 }
 
-func (ls *BasicLeagueService) ResetSimulation() error {
-	err := ls.db.ResetSimulation()
+// simulateUntil plays the weeks from the current one up to and including week, undone as a single step
+func (ls *BasicLeagueService) simulateUntil(tx database.Database, state *models.SimulationState, week int) error {
+	matches, err := tx.GetMatches()
 	if err != nil {
 		return err
 	}
+	if err := ls.saveUndo(tx, actionSimulateWeek, state, dueMatches(matches, week)); err != nil {
+		return err
+	}
+
+	for state.CurrentWeek <= week {
+		if err := ls.playWeek(tx, state); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	// Regenerate the schedule
-	teams, err := ls.db.GetTeams()
+// simulateWeek plays what is left of the current week, and of earlier weeks, and moves state on to the next one
+func (ls *BasicLeagueService) simulateWeek(tx database.Database, state *models.SimulationState) error {
+	matches, err := tx.GetMatches()
 	if err != nil {
 		return err
 	}
+	if err := ls.saveUndo(tx, actionSimulateWeek, state, dueMatches(matches, state.CurrentWeek)); err != nil {
+		return err
+	}
+	return ls.playWeek(tx, state)
+}
 
-	matches := ls.matchScheduler.GenerateSchedule(teams)
+// playWeek plays the matches due by the current week and moves on to the next, leaving the undo step to the caller
+func (ls *BasicLeagueService) playWeek(tx database.Database, state *models.SimulationState) error {
+	matches, err := tx.GetMatches()
+	if err != nil {
+		return err
+	}
 
-	err = ls.db.InsertMatches(matches)
+	for _, match := range dueMatches(matches, state.CurrentWeek) {
+		if _, err := ls.playMatch(tx, state, match, actionSimulateWeek); err != nil {
+			return err
+		}
+	}
+	return ls.moveWeek(tx, state, state.CurrentWeek+1, actionSimulateWeek)
+}
+
+// playMatch simulates a match and stores its result
+func (ls *BasicLeagueService) playMatch(tx database.Database, state *models.SimulationState, match models.Match,
+	action string) (models.MatchResult, error) {
+	homeTeam := ls.teamMap[match.HomeTeam.ID]
+	awayTeam := ls.teamMap[match.AwayTeam.ID]
+
+	simulator, err := ls.ratedSimulator(tx, match.Week)
 	if err != nil {
+		return models.MatchResult{}, err
+	}
+
+	// Seeding per fixture keeps each result independent of the order the matches of a week are played in, and
+	// unless the simulator plays from ratings, of edits made to other matches
+	simulator = simulator.WithSeed(
+		deriveSeed(state.Seed, seedStreamMatch, int64(match.Week), int64(homeTeam.ID), int64(awayTeam.ID)))
+	result := simulator.SimulateMatch(homeTeam, awayTeam)
+
+	if err := tx.UpdateMatchResult(match.ID, result, models.OriginSimulated); err != nil {
+		return models.MatchResult{}, err
+	}
+
+	return result, logEvent(tx, models.Event{
+		Type:      models.EventResultSimulated,
+		Source:    action,
+		Week:      state.CurrentWeek,
+		MatchID:   match.ID,
+		NewResult: &result,
+		Origin:    models.OriginSimulated,
+	})
+}
+
+func (ls *BasicLeagueService) ResetSimulation(seed int64) error {
+	return ls.change(func(tx database.Database, state *models.SimulationState) error {
+		archived, err := ls.archiveSeason(tx, state)
+		if err != nil {
+			return err
+		}
+
+		// A season nobody played keeps its number
+		season := state.Season
+		if archived {
+			season++
+		}
+		if err := tx.ResetSimulation(seed, season); err != nil {
+			return err
+		}
+
+		// Regenerate the schedule
+		teams, err := tx.GetTeams()
+		if err != nil {
+			return err
+		}
+
+		matches := ls.matchScheduler.WithSeed(deriveSeed(seed, seedStreamSchedule)).GenerateSchedule(teams)
+
+		err = tx.InsertMatches(matches)
+		if err != nil {
+			return err
+		}
+
+		// The season length depends on the team count, so derive it from the schedule
+		maxWeeks := 0
+		for _, match := range matches {
+			maxWeeks = max(maxWeeks, match.Week)
+		}
+		if err := tx.UpdateMaxWeeks(maxWeeks); err != nil {
+			return err
+		}
+
+		err = logEvent(tx, models.Event{
+			Type:   models.EventSeasonReset,
+			Source: actionReset,
+			Week:   state.CurrentWeek,
+			Seed:   &seed,
+		})
+		*state = models.SimulationState{LeagueID: state.LeagueID, CurrentWeek: 1, MaxWeeks: maxWeeks, Seed: seed, Season: season}
 		return err
+	})
+}
+
+func (ls *BasicLeagueService) UpdateMatchResult(matchID int, result models.MatchResult) error {
+	if result.HomeScore < 0 || result.AwayScore < 0 {
+		return &ValidationError{Message: "scores cannot be negative"}
 	}
-	return nil
+	if err := checkPenalties(result, ls.table.Rules().Points); err != nil {
+		return err
+	}
+
+	return ls.change(func(tx database.Database, state *models.SimulationState) error {
+		matches, err := tx.GetMatches()
+		if err != nil {
+			return err
+		}
+
+		var match *models.Match
+		for i := range matches {
+			if matches[i].ID == matchID {
+				match = &matches[i]
+			}
+		}
+		if match == nil {
+			return ErrMatchNotFound
+		}
+
+		if err := checkResultWeek(*match, state.CurrentWeek); err != nil {
+			return err
+		}
+
+		if err := ls.saveUndo(tx, actionEditResult, state, []models.Match{*match}); err != nil {
+			return err
+		}
+
+		if err := ls.setResult(tx, state, *match, result, models.OriginManual, actionEditResult); err != nil {
+			return err
+		}
+
+		if match.IsPlayed {
+			return nil
+		}
+		match.IsPlayed = true
+		return ls.endWeekIfPlayed(tx, state, matches, actionEditResult)
+	})
+}
+
+func (ls *BasicLeagueService) UpdateMatchResults(entries []models.ResultEntry) error {
+	return ls.change(func(tx database.Database, state *models.SimulationState) error {
+		matches, err := tx.GetMatches()
+		if err != nil {
+			return err
+		}
+
+		updates, err := resolveResults(matches, entries, state.CurrentWeek, ls.table.Rules().Points)
+		if err != nil {
+			return err
+		}
+
+		previous := make([]models.Match, len(updates))
+		for n, update := range updates {
+			previous[n] = matches[update.index]
+		}
+		if err := ls.saveUndo(tx, actionImportResults, state, previous); err != nil {
+			return err
+		}
+
+		for _, update := range updates {
+			match := matches[update.index]
+			if err := ls.setResult(tx, state, match, update.result, models.OriginImported, actionImportResults); err != nil {
+				return err
+			}
+			matches[update.index].IsPlayed = true
+		}
+		return ls.endWeekIfPlayed(tx, state, matches, actionImportResults)
+	})
+}
+
+func (ls *BasicLeagueService) ClearMatchResult(matchID int) error {
+	return ls.change(func(tx database.Database, state *models.SimulationState) error {
+		matches, err := tx.GetMatches()
+		if err != nil {
+			return err
+		}
+
+		var match *models.Match
+		for i := range matches {
+			if matches[i].ID == matchID {
+				match = &matches[i]
+			}
+		}
+		if match == nil {
+			return ErrMatchNotFound
+		}
+		if !match.IsPlayed {
+			return &ValidationError{Message: "match " + strconv.Itoa(matchID) + " has not been played"}
+		}
+
+		if err := ls.saveUndo(tx, actionClearResult, state, []models.Match{*match}); err != nil {
+			return err
+		}
+
+		if err := ls.clearResult(tx, state, *match, actionClearResult); err != nil {
+			return err
+		}
+
+		// A finished season is open again until the match is played
+		if state.CurrentWeek > state.MaxWeeks {
+			return ls.moveWeek(tx, state, state.MaxWeeks, actionClearResult)
+		}
+		return nil
+	})
+}
+
+func (ls *BasicLeagueService) RewindToWeek(week int) error {
+	return ls.change(func(tx database.Database, state *models.SimulationState) error {
+		// Week 0 is the start of the season, before anything was played
+		if week < 0 || week >= state.CurrentWeek {
+			return &ValidationError{Message: "week must be between 0 and " + strconv.Itoa(state.CurrentWeek-1)}
+		}
+
+		matches, err := tx.GetMatches()
+		if err != nil {
+			return err
+		}
+
+		var cleared []models.Match
+		for _, match := range matches {
+			if match.Week > week && match.IsPlayed {
+				cleared = append(cleared, match)
+			}
+		}
+
+		if err := ls.saveUndo(tx, actionRewind, state, cleared); err != nil {
+			return err
+		}
+
+		for _, match := range cleared {
+			if err := logEvent(tx, clearedEvent(state, match, actionRewind)); err != nil {
+				return err
+			}
+		}
+		if err := tx.ClearResultsAfterWeek(week); err != nil {
+			return err
+		}
+		return ls.moveWeek(tx, state, week+1, actionRewind)
+	})
+}
+
+func (ls *BasicLeagueService) Undo() error {
+	return ls.change(func(tx database.Database, state *models.SimulationState) error {
+		step, err := tx.GetUndo()
+		if errors.Is(err, database.ErrNotFound) {
+			return ErrNothingToUndo
+		}
+		if err != nil {
+			return err
+		}
+
+		matches, err := tx.GetMatches()
+		if err != nil {
+			return err
+		}
+		byID := make(map[int]models.Match, len(matches))
+		for _, match := range matches {
+			byID[match.ID] = match
+		}
+
+		for _, record := range step.Matches {
+			match := byID[record.ID]
+			switch {
+			case record.IsPlayed:
+				err = ls.setResult(tx, state, match, record.Result, record.Origin, actionUndo)
+			case match.IsPlayed:
+				err = ls.clearResult(tx, state, match, actionUndo)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := ls.moveWeek(tx, state, step.CurrentWeek, actionUndo); err != nil {
+			return err
+		}
+
+		// Undoing twice would not give back what the first undo threw away
+		return tx.DeleteUndo()
+	})
 }
 
-func (ls *BasicLeagueService) UpdateMatchResult(matchID int, homeScore, awayScore int) error {
-	result := models.MatchResult{
-		HomeScore: homeScore,
-		AwayScore: awayScore,
+func (ls *BasicLeagueService) WithExpectedWeek(week int) LeagueService {
+	copied := *ls
+	copied.expectedWeek = week
+	return &copied
+}
+
+// setRules changes the rules of the league and logs the change. A completed season is archived again, ranked
+// by the new rules.
+func (ls *BasicLeagueService) setRules(rules models.LeagueRules) error {
+	ruled := *ls
+	ruled.table = ls.table.WithRules(rules)
+	return ruled.change(func(tx database.Database, state *models.SimulationState) error {
+		if err := tx.UpdateRules(rules); err != nil {
+			return err
+		}
+		return logEvent(tx, models.Event{
+			Type:   models.EventRulesChanged,
+			Source: actionSetRules,
+			Week:   state.CurrentWeek,
+			Rules:  &rules,
+		})
+	})
+}
+
+// change runs fn in a single transaction, with the state of the league as it is when the transaction starts.
+// current_week only moves from the week read there, so a request racing another one fails with
+// ErrStaleState instead of playing a week twice or skipping one. fn keeps state up to date, the season
+// archive and the ratings follow it.
+func (ls *BasicLeagueService) change(fn func(tx database.Database, state *models.SimulationState) error) error {
+	err := ls.db.Transaction(func(tx database.Database) error {
+		state, err := tx.GetSimulationState()
+		if err != nil {
+			return err
+		}
+
+		if ls.expectedWeek > 0 && state.CurrentWeek != ls.expectedWeek {
+			return ErrStaleState
+		}
+		if err := fn(tx, state); err != nil {
+			return err
+		}
+		if err := ls.syncRatings(tx); err != nil {
+			return err
+		}
+		return ls.syncArchive(tx, state)
+	})
+	if errors.Is(err, database.ErrConflict) {
+		return ErrStaleState
+	}
+	return err
+}
+
+// setResult stores a result entered by hand, or restored, and logs the edit
+func (ls *BasicLeagueService) setResult(tx database.Database, state *models.SimulationState, match models.Match,
+	result models.MatchResult, origin models.Origin, action string) error {
+	if err := tx.UpdateMatchResult(match.ID, result, origin); err != nil {
+		return err
+	}
+
+	event := models.Event{
+		Type:      models.EventResultEdited,
+		Source:    action,
+		Week:      state.CurrentWeek,
+		MatchID:   match.ID,
+		NewResult: &result,
+		Origin:    origin,
+	}
+	if match.IsPlayed {
+		event.OldResult = &match.Result
 	}
+	return logEvent(tx, event)
+}
+
+// clearResult sets a played match back to unplayed and logs the result it had
+func (ls *BasicLeagueService) clearResult(tx database.Database, state *models.SimulationState, match models.Match,
+	action string) error {
+	if err := tx.ClearMatchResult(match.ID); err != nil {
+		return err
+	}
+	return logEvent(tx, clearedEvent(state, match, action))
+}
 
-	if err := ls.db.UpdateMatchResult(matchID, result); err != nil {
+func clearedEvent(state *models.SimulationState, match models.Match, action string) models.Event {
+	return models.Event{
+		Type:      models.EventResultCleared,
+		Source:    action,
+		Week:      state.CurrentWeek,
+		MatchID:   match.ID,
+		OldResult: &match.Result,
+	}
+}
+
+// moveWeek sets the current week of the league, and of state, logging the move
+func (ls *BasicLeagueService) moveWeek(tx database.Database, state *models.SimulationState, week int, action string) error {
+	if week == state.CurrentWeek {
+		return nil
+	}
+	if err := tx.UpdateCurrentWeek(state.CurrentWeek, week); err != nil {
 		return err
 	}
+
+	event := models.Event{
+		Type:    models.EventWeekAdvanced,
+		Source:  action,
+		Week:    state.CurrentWeek,
+		NewWeek: week,
+	}
+	if week < state.CurrentWeek {
+		event.Type = models.EventWeekRewound
+	}
+	state.CurrentWeek = week
+	return logEvent(tx, event)
+}
+
+func logEvent(tx database.Database, event models.Event) error {
+	event.CreatedAt = time.Now().UTC()
+	return tx.InsertEvent(event)
+}
+
+// saveUndo records how the matches and the current week stand before action changes them
+func (ls *BasicLeagueService) saveUndo(tx database.Database, action string, state *models.SimulationState, matches []models.Match) error {
+	records := make([]models.MatchRecord, len(matches))
+	for i, match := range matches {
+		records[i] = models.MatchRecord{ID: match.ID, Result: match.Result, IsPlayed: match.IsPlayed, Origin: match.Origin}
+	}
+
+	return tx.SaveUndo(models.UndoStep{
+		Action:      action,
+		CurrentWeek: state.CurrentWeek,
+		Matches:     records,
+	})
+}
+
+func validateStateOptions(options StateOptions) error {
+	if options.Iterations < 0 || options.Iterations > MaxPredictorIterations {
+		return &ValidationError{Message: "iterations must be between 1 and " + strconv.Itoa(MaxPredictorIterations)}
+	}
+	if options.Week < 0 {
+		return &ValidationError{Message: "week must be positive"}
+	}
 	return nil
 }
 
+func (ls *BasicLeagueService) GetDeductions() ([]models.PointDeduction, error) {
+	return ls.db.GetDeductions()
+}
+
+func (ls *BasicLeagueService) AddDeduction(deduction models.PointDeduction) (*models.PointDeduction, error) {
+	if _, ok := ls.teamMap[deduction.TeamID]; !ok {
+		return nil, &ValidationError{Message: "team " + strconv.Itoa(deduction.TeamID) + " does not play in this league"}
+	}
+	if deduction.Points < 1 {
+		return nil, &ValidationError{Message: "a deduction must take off at least one point"}
+	}
+	deduction.Reason = strings.TrimSpace(deduction.Reason)
+	if deduction.Reason == "" {
+		return nil, &ValidationError{Message: "a deduction needs a reason"}
+	}
+
+	// Through change, so the final table of a completed season is archived again
+	err := ls.change(func(tx database.Database, state *models.SimulationState) error {
+		deduction.Week = state.CurrentWeek
+		id, err := tx.InsertDeduction(deduction)
+		deduction.ID = id
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &deduction, nil
+}
+
+func (ls *BasicLeagueService) RemoveDeduction(deductionID int) error {
+	err := ls.change(func(tx database.Database, _ *models.SimulationState) error {
+		return tx.DeleteDeduction(deductionID)
+	})
+	if errors.Is(err, database.ErrNotFound) {
+		return ErrDeductionNotFound
+	}
+	return err
+}
+
+func (ls *BasicLeagueService) GetEvents(query EventQuery) (*models.EventPage, error) {
+	if query.Limit == 0 {
+		query.Limit = defaultEventLimit
+	}
+	if query.Limit < 1 || query.Limit > maxEventLimit {
+		return nil, &ValidationError{Message: "limit must be between 1 and " + strconv.Itoa(maxEventLimit)}
+	}
+	if query.Offset < 0 {
+		return nil, &ValidationError{Message: "offset cannot be negative"}
+	}
+
+	events, total, err := ls.db.GetEvents(query.MatchID, query.Limit, query.Offset)
+	if err != nil {
+		return nil, err
+	}
+	return &models.EventPage{Events: events, Total: total, Limit: query.Limit, Offset: query.Offset}, nil
+}
+
+// deductionsAsOfWeek returns the deductions made by the end of week
+func deductionsAsOfWeek(deductions []models.PointDeduction, week int) []models.PointDeduction {
+	out := make([]models.PointDeduction, 0, len(deductions))
+	for _, deduction := range deductions {
+		if deduction.Week <= week {
+			out = append(out, deduction)
+		}
+	}
+	return out
+}
+
 func (ls *BasicLeagueService) getRemainingMatches(matches []models.Match) []models.Match {
 	remaining := make([]models.Match, 0)
 	for _, match := range matches {
@@ -190,3 +877,9 @@ func (ls *BasicLeagueService) filterStateByWeek(state *models.LeagueSimulation,
 		Matches:    filteredMatches,
 	}
 }
+
+// oddsShown tells whether the state of a league carries odds: over the last third of the season, once two
+// thirds of its weeks are played, which for the original six week season is after week 4
+func oddsShown(state *models.SimulationState) bool {
+	return state.CurrentWeek > state.MaxWeeks*2/3
+}
@@ -7,7 +7,11 @@ import (
 )
 
 type DefaultLeagueTable struct {
-	entryMap map[int]*models.LeagueTableEntry
+	entryMap   map[int]*models.LeagueTableEntry
+	rules      models.LeagueRules
+	lotsSeed   int64
+	deductions []models.PointDeduction
+	zones      models.TableZones
 }
 
 func NewLeagueTable(teams []models.Team) *DefaultLeagueTable {
@@ -29,20 +33,62 @@ func NewLeagueTable(teams []models.Team) *DefaultLeagueTable {
 
 	return &DefaultLeagueTable{
 		entryMap: entries,
+		rules:    models.LeagueRules{}.WithDefaults(),
+	}
+}
+
+func (lt *DefaultLeagueTable) Rules() models.LeagueRules {
+	return lt.rules
+}
+
+func (lt *DefaultLeagueTable) WithRules(rules models.LeagueRules) LeagueTable {
+	table := lt.clone()
+	table.rules = rules.WithDefaults()
+	return table
+}
+
+func (lt *DefaultLeagueTable) WithDeductions(deductions []models.PointDeduction) LeagueTable {
+	table := lt.clone()
+	table.deductions = deductions
+	return table
+}
+
+func (lt *DefaultLeagueTable) Zones() models.TableZones {
+	return lt.zones
+}
+
+func (lt *DefaultLeagueTable) WithZones(zones models.TableZones) LeagueTable {
+	table := lt.clone()
+	table.zones = zones
+	return table
+}
+
+func (lt *DefaultLeagueTable) WithSeed(seed int64) LeagueTable {
+	table := lt.clone()
+	table.lotsSeed = seed
+	return table
+}
+
+// clone copies the table with entries of its own, so the copy can be calculated concurrently
+func (lt *DefaultLeagueTable) clone() *DefaultLeagueTable {
+	entries := make(map[int]*models.LeagueTableEntry, len(lt.entryMap))
+	for id, e := range lt.entryMap {
+		entry := *e
+		entries[id] = &entry
+	}
+
+	return &DefaultLeagueTable{
+		entryMap:   entries,
+		rules:      lt.rules,
+		lotsSeed:   lt.lotsSeed,
+		deductions: lt.deductions,
+		zones:      lt.zones,
 	}
 }
 
 func (lt *DefaultLeagueTable) CalculateTable(matches []models.Match) []models.LeagueTableEntry {
 	for _, e := range lt.entryMap {
-		e.Position = 0
-		e.Played = 0
-		e.Won = 0
-		e.Drawn = 0
-		e.Lost = 0
-		e.GoalsFor = 0
-		e.GoalsAgainst = 0
-		e.GoalDiff = 0
-		e.Points = 0
+		*e = models.LeagueTableEntry{Team: e.Team}
 	}
 
 	for _, match := range matches {
@@ -57,54 +103,226 @@ func (lt *DefaultLeagueTable) CalculateTable(matches []models.Match) []models.Le
 			continue // Skip if either team entry is missing
 		}
 
-		homeEntry.Played++
-		awayEntry.Played++
-
-		result := match.Result
-		hs, as := result.HomeScore, result.AwayScore
-
-		homeEntry.GoalsFor += hs
-		homeEntry.GoalsAgainst += as
-		awayEntry.GoalsFor += as
-		awayEntry.GoalsAgainst += hs
-
-		if result.IsWin() {
-			homeEntry.Won++
-			awayEntry.Lost++
-			homeEntry.Points += 3
-		} else if result.IsDraw() {
-			homeEntry.Drawn++
-			awayEntry.Drawn++
-			homeEntry.Points += 1
-			awayEntry.Points += 1
-		} else {
-			awayEntry.Won++
-			homeEntry.Lost++
-			awayEntry.Points += 3
+		applyResult(homeEntry, awayEntry, match.Result, lt.rules.Points)
+	}
+
+	for _, deduction := range lt.deductions {
+		if entry := lt.entryMap[deduction.TeamID]; entry != nil {
+			entry.Points -= deduction.Points
+			entry.PointsDeducted += deduction.Points
+			entry.Deductions = append(entry.Deductions, deduction)
 		}
 	}
 
 	var table []models.LeagueTableEntry
 	for _, entry := range lt.entryMap {
-		entry.GoalDiff = entry.GoalsFor - entry.GoalsAgainst
 		table = append(table, *entry)
 	}
 
-	// Sort entries by points, then goal difference, then goals for
-	sort.Slice(table, func(i, j int) bool {
-		a, b := table[i], table[j]
-		if a.Points != b.Points {
-			return a.Points > b.Points
+	lt.Rank(table, matches)
+	return table
+}
+
+func (lt *DefaultLeagueTable) Rank(table []models.LeagueTableEntry, matches []models.Match) {
+	rankStandings(table, matches, lt.rules, lt.lotsSeed)
+	markZones(table, lt.zones)
+}
+
+// markZones marks the ranked entries by the zone their position falls in, the promotion places first
+func markZones(table []models.LeagueTableEntry, zones models.TableZones) {
+	for i := range table {
+		switch {
+		case i < zones.Promotion:
+			table[i].Zone = models.ZonePromotion
+		case i < zones.Promotion+zones.Playoff:
+			table[i].Zone = models.ZonePlayoff
+		case i >= len(table)-zones.Relegation:
+			table[i].Zone = models.ZoneRelegation
+		default:
+			table[i].Zone = ""
+		}
+	}
+}
+
+// applyResult adds a played match to the entries of both sides
+func applyResult(home, away *models.LeagueTableEntry, result models.MatchResult, points models.PointsSystem) {
+	hs, as := result.HomeScore, result.AwayScore
+
+	home.Played++
+	away.Played++
+	home.GoalsFor += hs
+	home.GoalsAgainst += as
+	away.GoalsFor += as
+	away.GoalsAgainst += hs
+	away.AwayGoalsFor += as
+	home.DisciplinaryPoints += result.HomeDiscipline
+	away.DisciplinaryPoints += result.AwayDiscipline
+
+	if result.IsWin() {
+		home.Won++
+		away.Lost++
+	} else if result.IsDraw() {
+		home.Drawn++
+		away.Drawn++
+	} else {
+		away.Won++
+		home.Lost++
+	}
+
+	homePoints, awayPoints := matchPoints(result, points)
+	home.Points += homePoints
+	away.Points += awayPoints
+}
+
+// matchPoints returns the points each side earns from a result under the points system
+func matchPoints(result models.MatchResult, points models.PointsSystem) (int, int) {
+	var home, away int
+	switch {
+	case result.IsWin():
+		home, away = points.Win, points.Loss
+	case result.IsLoss():
+		home, away = points.Loss, points.Win
+	case points.Shootout && result.HomePenalties > result.AwayPenalties:
+		home, away = points.ShootoutWin, points.ShootoutLoss
+	case points.Shootout && result.HomePenalties < result.AwayPenalties:
+		home, away = points.ShootoutLoss, points.ShootoutWin
+	default:
+		// Also a draw in a shootout league whose shootout was never recorded
+		home, away = points.Draw, points.Draw
+	}
+
+	if points.GoalsBonusThreshold > 0 {
+		if result.HomeScore >= points.GoalsBonusThreshold {
+			home += points.GoalsBonus
+		}
+		if result.AwayScore >= points.GoalsBonusThreshold {
+			away += points.GoalsBonus
+		}
+	}
+
+	if points.MarginBonusThreshold > 0 {
+		margin := result.HomeScore - result.AwayScore
+		if margin >= points.MarginBonusThreshold {
+			home += points.MarginBonus
 		}
-		if a.GoalDiff != b.GoalDiff {
-			return a.GoalDiff > b.GoalDiff
+		if -margin >= points.MarginBonusThreshold {
+			away += points.MarginBonus
 		}
-		return a.GoalsFor > b.GoalsFor
+	}
+	return home, away
+}
+
+// rankStandings orders entries by points, separates teams level on points with the tiebreakers in order
+// and assigns positions. The predictor ranks its simulated tables with it too, so both always agree.
+// matches are the results the head-to-head tiebreakers look at, unplayed ones are ignored.
+func rankStandings(table []models.LeagueTableEntry, matches []models.Match, rules models.LeagueRules, lotsSeed int64) {
+	for i := range table {
+		table[i].GoalDiff = table[i].GoalsFor - table[i].GoalsAgainst
+	}
+
+	// Start from alphabetical order so teams level on everything always appear the same way
+	sort.Slice(table, func(i, j int) bool {
+		return table[i].Team.Name < table[j].Team.Name
+	})
+
+	sort.SliceStable(table, func(i, j int) bool {
+		return table[i].Points > table[j].Points
 	})
 
+	for i := 0; i < len(table); {
+		j := i + 1
+		for j < len(table) && table[j].Points == table[i].Points {
+			j++
+		}
+		breakTies(table[i:j], matches, rules, rules.Tiebreakers, lotsSeed)
+		i = j
+	}
+
 	for i := range table {
 		table[i].Position = i + 1
 	}
+}
+
+// breakTies orders a group of teams level so far by the first tiebreaker, then resolves whatever is still
+// level. A smaller group goes through the whole chain again, as head-to-head records differ between the
+// teams still level; a group the tiebreaker did not split at all moves on to the next one.
+func breakTies(group []models.LeagueTableEntry, matches []models.Match, rules models.LeagueRules,
+	tiebreakers []models.Tiebreaker, lotsSeed int64) {
+	if len(group) < 2 || len(tiebreakers) == 0 {
+		return
+	}
 
-	return table
+	keys := tiebreakerKeys(group, matches, tiebreakers[0], rules.Points, lotsSeed)
+	sort.SliceStable(group, func(i, j int) bool {
+		return keys[group[i].Team.ID] > keys[group[j].Team.ID]
+	})
+
+	if keys[group[0].Team.ID] == keys[group[len(group)-1].Team.ID] {
+		breakTies(group, matches, rules, tiebreakers[1:], lotsSeed)
+		return
+	}
+
+	for i := 0; i < len(group); {
+		j := i + 1
+		for j < len(group) && keys[group[j].Team.ID] == keys[group[i].Team.ID] {
+			j++
+		}
+		breakTies(group[i:j], matches, rules, rules.Tiebreakers, lotsSeed)
+		i = j
+	}
+}
+
+// tiebreakerKeys scores each team of the group on a tiebreaker, higher ranks first
+func tiebreakerKeys(group []models.LeagueTableEntry, matches []models.Match, tiebreaker models.Tiebreaker,
+	points models.PointsSystem, lotsSeed int64) map[int]int64 {
+	keys := make(map[int]int64, len(group))
+
+	switch tiebreaker {
+	case models.TiebreakerHeadToHeadPoints, models.TiebreakerHeadToHeadGoalDiff:
+		// A mini-league of the matches played between the teams of the group
+		for _, e := range group {
+			keys[e.Team.ID] = 0
+		}
+		for _, m := range matches {
+			_, homeIn := keys[m.HomeTeam.ID]
+			_, awayIn := keys[m.AwayTeam.ID]
+			if !m.IsPlayed || !homeIn || !awayIn {
+				continue
+			}
+
+			if tiebreaker == models.TiebreakerHeadToHeadPoints {
+				homePoints, awayPoints := matchPoints(m.Result, points)
+				keys[m.HomeTeam.ID] += int64(homePoints)
+				keys[m.AwayTeam.ID] += int64(awayPoints)
+			} else {
+				diff := int64(m.Result.HomeScore - m.Result.AwayScore)
+				keys[m.HomeTeam.ID] += diff
+				keys[m.AwayTeam.ID] -= diff
+			}
+		}
+	default:
+		for _, e := range group {
+			keys[e.Team.ID] = tiebreakerKey(e, tiebreaker, lotsSeed)
+		}
+	}
+	return keys
+}
+
+func tiebreakerKey(e models.LeagueTableEntry, tiebreaker models.Tiebreaker, lotsSeed int64) int64 {
+	switch tiebreaker {
+	case models.TiebreakerGoalDifference:
+		return int64(e.GoalDiff)
+	case models.TiebreakerGoalsFor:
+		return int64(e.GoalsFor)
+	case models.TiebreakerAwayGoals:
+		return int64(e.AwayGoalsFor)
+	case models.TiebreakerWins:
+		return int64(e.Won)
+	case models.TiebreakerFairPlay:
+		return -int64(e.DisciplinaryPoints) // fewer is better
+	case models.TiebreakerDrawingOfLots:
+		// Each team draws one number per season, so the lots fall the same way in the table and the predictor
+		return deriveSeed(lotsSeed, int64(e.Team.ID))
+	}
+	return 0
 }
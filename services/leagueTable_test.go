@@ -86,3 +86,131 @@ func TestDefaultLeagueTable_ResetsBetweenCalls(t *testing.T) {
 	assert.Equal(t, 3, a3.Points, "expected Points to be 3 after third call")
 	assert.Equal(t, 3, a3.GoalsFor, "expected GoalsFor to be 3 after third call")
 }
+
+func TestRankStandings_HeadToHeadIsReappliedToSmallerGroups(t *testing.T) {
+	a, b, c := models.Team{ID: 1, Name: "A"}, models.Team{ID: 2, Name: "B"}, models.Team{ID: 3, Name: "C"}
+	played := func(home, away *models.Team, hs, as int) models.Match {
+		return models.Match{HomeTeam: home, AwayTeam: away, IsPlayed: true, Result: models.MatchResult{HomeScore: hs, AwayScore: as}}
+	}
+
+	// Among the three A has 9 points, B and C 4 each, but C took 4 of the 5 points between B and C
+	matches := []models.Match{
+		played(&a, &b, 0, 1), played(&b, &a, 0, 1),
+		played(&a, &c, 2, 0), played(&c, &a, 0, 1),
+		played(&b, &c, 1, 1), played(&c, &b, 2, 0),
+	}
+
+	// Level on points overall, with B ahead of C on goal difference
+	table := []models.LeagueTableEntry{
+		{Team: a, Points: 20, GoalsFor: 10, GoalsAgainst: 10},
+		{Team: b, Points: 20, GoalsFor: 15, GoalsAgainst: 5},
+		{Team: c, Points: 20, GoalsFor: 10, GoalsAgainst: 10},
+	}
+
+	h2h := models.LeagueRules{Tiebreakers: []models.Tiebreaker{models.TiebreakerHeadToHeadPoints, models.TiebreakerGoalDifference}}
+	rankStandings(table, matches, h2h.WithDefaults(), 0)
+	assert.Equal(t, []string{"A", "C", "B"}, []string{table[0].Team.Name, table[1].Team.Name, table[2].Team.Name})
+
+	// Without head-to-head goal difference decides
+	rankStandings(table, matches, models.LeagueRules{}.WithDefaults(), 0)
+	assert.Equal(t, "B", table[0].Team.Name)
+}
+
+func TestRankStandings_TiebreakerChain(t *testing.T) {
+	level := func() []models.LeagueTableEntry {
+		return []models.LeagueTableEntry{
+			{Team: models.Team{ID: 1, Name: "A"}, Points: 10, Won: 3, AwayGoalsFor: 2, DisciplinaryPoints: 9},
+			{Team: models.Team{ID: 2, Name: "B"}, Points: 10, Won: 2, AwayGoalsFor: 5, DisciplinaryPoints: 4},
+		}
+	}
+
+	for tiebreaker, leader := range map[models.Tiebreaker]string{
+		models.TiebreakerWins:      "A",
+		models.TiebreakerAwayGoals: "B",
+		models.TiebreakerFairPlay:  "B",
+	} {
+		table := level()
+		rankStandings(table, nil, models.LeagueRules{Tiebreakers: []models.Tiebreaker{tiebreaker}}, 0)
+		assert.Equal(t, leader, table[0].Team.Name, tiebreaker)
+		assert.Equal(t, 1, table[0].Position)
+	}
+
+	// Lots fall the same way for the same seed, and both ways across seeds
+	lots := models.LeagueRules{Tiebreakers: []models.Tiebreaker{models.TiebreakerDrawingOfLots}}
+	leaders := make(map[string]bool)
+	for seed := range int64(20) {
+		first, second := level(), level()
+		rankStandings(first, nil, lots, seed)
+		rankStandings(second, nil, lots, seed)
+		assert.Equal(t, first, second)
+		leaders[first[0].Team.Name] = true
+	}
+	assert.Len(t, leaders, 2)
+}
+
+func TestDefaultLeagueTable_WithRules(t *testing.T) {
+	teams := []models.Team{{ID: 1, Name: "A"}, {ID: 2, Name: "B"}}
+
+	// B wins big, A wins the second meeting and gets booked less
+	matches := []models.Match{
+		{HomeTeam: &teams[0], AwayTeam: &teams[1], IsPlayed: true, Result: models.MatchResult{HomeScore: 0, AwayScore: 4, AwayDiscipline: 5}},
+		{HomeTeam: &teams[1], AwayTeam: &teams[0], IsPlayed: true, Result: models.MatchResult{HomeScore: 0, AwayScore: 1, HomeDiscipline: 2}},
+	}
+
+	table := NewLeagueTable(teams).CalculateTable(matches)
+	assert.Equal(t, "B", table[0].Team.Name, "goal difference by default")
+	assert.Equal(t, 4, table[0].AwayGoalsFor)
+	assert.Equal(t, 7, table[0].DisciplinaryPoints)
+
+	rules := models.LeagueRules{Tiebreakers: []models.Tiebreaker{models.TiebreakerFairPlay}}
+	table = NewLeagueTable(teams).WithRules(rules).CalculateTable(matches)
+	assert.Equal(t, "A", table[0].Team.Name)
+}
+
+func TestMatchPoints(t *testing.T) {
+	rugby := models.PointsSystem{Win: 4, Draw: 2, Loss: 0, GoalsBonusThreshold: 4, GoalsBonus: 1, MarginBonusThreshold: 3, MarginBonus: 1}
+	shootout := models.PointsSystem{Win: 3, Loss: 0, Shootout: true, ShootoutWin: 2, ShootoutLoss: 1}
+
+	cases := []struct {
+		name       string
+		system     models.PointsSystem
+		result     models.MatchResult
+		home, away int
+	}{
+		{"two points for a win", models.PointsSystem{Win: 2, Draw: 1}, models.MatchResult{HomeScore: 1, AwayScore: 0}, 2, 0},
+		{"away win", models.DefaultPointsSystem, models.MatchResult{HomeScore: 0, AwayScore: 2}, 0, 3},
+		{"both bonuses", rugby, models.MatchResult{HomeScore: 5, AwayScore: 1}, 6, 0},
+		{"losing side scores four", rugby, models.MatchResult{HomeScore: 4, AwayScore: 7}, 1, 6},
+		{"shootout won away", shootout, models.MatchResult{HomeScore: 1, AwayScore: 1, HomePenalties: 3, AwayPenalties: 4}, 1, 2},
+		{"draw without shootout", shootout, models.MatchResult{HomeScore: 0, AwayScore: 0}, 0, 0},
+		{"penalties ignored", models.DefaultPointsSystem, models.MatchResult{HomeScore: 2, AwayScore: 2, HomePenalties: 5, AwayPenalties: 4}, 1, 1},
+	}
+
+	for _, tc := range cases {
+		home, away := matchPoints(tc.result, tc.system)
+		assert.Equal(t, [2]int{tc.home, tc.away}, [2]int{home, away}, tc.name)
+	}
+}
+
+func TestDefaultLeagueTable_Deductions(t *testing.T) {
+	teams := []models.Team{{ID: 1, Name: "A"}, {ID: 2, Name: "B"}}
+	matches := []models.Match{{
+		HomeTeam: &teams[0], AwayTeam: &teams[1], IsPlayed: true,
+		Result: models.MatchResult{HomeScore: 2, AwayScore: 0},
+	}}
+
+	deductions := []models.PointDeduction{
+		{ID: 1, TeamID: 1, Points: 3, Reason: "Financial irregularities"},
+		{ID: 2, TeamID: 1, Points: 1, Reason: "Fielding an ineligible player"},
+	}
+	table := NewLeagueTable(teams).WithDeductions(deductions).CalculateTable(matches)
+
+	assert.Equal(t, "B", table[0].Team.Name, "A drops below B after its deductions")
+	assert.Equal(t, -1, table[1].Points)
+	assert.Equal(t, 4, table[1].PointsDeducted)
+	assert.Equal(t, deductions, table[1].Deductions)
+
+	// The table the deductions were added to is left alone
+	table = NewLeagueTable(teams).CalculateTable(matches)
+	assert.Equal(t, "A", table[0].Team.Name)
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"sort"
+	"strconv"
+
+	"insider/models"
+)
+
+// How many of the likeliest scorelines a prediction lists
+const likeliestScoresListed int = 5
+
+// Total goals lines of a prediction, halves so a match always falls on one side of them
+var goalLines = []float64{0.5, 1.5, 2.5, 3.5, 4.5}
+
+// predictionFromGrid sums up the probabilities of the scorelines in grid, indexed by home then away goals
+func predictionFromGrid(homeExpectedGoals, awayExpectedGoals float64, grid [][]float64) models.MatchPrediction {
+	prediction := models.MatchPrediction{
+		HomeExpectedGoals: homeExpectedGoals,
+		AwayExpectedGoals: awayExpectedGoals,
+		Goals:             make([]models.GoalLine, len(goalLines)),
+		Scorelines:        grid,
+	}
+	for i, line := range goalLines {
+		prediction.Goals[i].Line = line
+	}
+
+	var scores []models.ScoreProbability
+	for homeGoals, row := range grid {
+		for awayGoals, p := range row {
+			switch {
+			case homeGoals > awayGoals:
+				prediction.HomeWin += p
+			case homeGoals == awayGoals:
+				prediction.Draw += p
+			default:
+				prediction.AwayWin += p
+			}
+			if homeGoals > 0 && awayGoals > 0 {
+				prediction.BothTeamsToScore += p
+			}
+			for i, line := range goalLines {
+				if float64(homeGoals+awayGoals) > line {
+					prediction.Goals[i].Over += p
+				} else {
+					prediction.Goals[i].Under += p
+				}
+			}
+			scores = append(scores, models.ScoreProbability{HomeScore: homeGoals, AwayScore: awayGoals, Probability: p})
+		}
+	}
+
+	// Equally likely scorelines keep the order of the grid
+	sort.SliceStable(scores, func(i, j int) bool { return scores[i].Probability > scores[j].Probability })
+	prediction.MostLikelyScores = scores[:min(len(scores), likeliestScoresListed)]
+	return prediction
+}
+
+// independentGrid returns the probabilities of the scorelines when each side scores independently, by home
+// then away goals
+func independentGrid(homeGoals, awayGoals []float64) [][]float64 {
+	grid := make([][]float64, len(homeGoals))
+	for x := range grid {
+		grid[x] = make([]float64, len(awayGoals))
+		for y := range grid[x] {
+			grid[x][y] = homeGoals[x] * awayGoals[y]
+		}
+	}
+	return grid
+}
+
+// PredictMatches returns the exact outcome probabilities of every unplayed match, in schedule order, without
+// their scoreline grids
+func (ls *BasicLeagueService) PredictMatches() ([]models.FixturePrediction, error) {
+	matches, err := ls.db.GetMatches()
+	if err != nil {
+		return nil, err
+	}
+
+	predictions := make([]models.FixturePrediction, 0)
+	for _, match := range ls.getRemainingMatches(matches) {
+		prediction := ls.predictMatch(matches, match)
+		prediction.Prediction.Scorelines = nil
+		predictions = append(predictions, prediction)
+	}
+	return predictions, nil
+}
+
+func (ls *BasicLeagueService) PredictMatch(matchID int) (*models.FixturePrediction, error) {
+	matches, err := ls.db.GetMatches()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, match := range matches {
+		if match.ID != matchID {
+			continue
+		}
+		if match.IsPlayed {
+			return nil, &ValidationError{Message: "match " + strconv.Itoa(matchID) + " has already been played"}
+		}
+		prediction := ls.predictMatch(matches, match)
+		return &prediction, nil
+	}
+	return nil, ErrMatchNotFound
+}
+
+// predictMatch predicts match as it would be played now, with the teams as they are and, for a model playing
+// from ratings, the ratings they go into its week with
+func (ls *BasicLeagueService) predictMatch(matches []models.Match, match models.Match) models.FixturePrediction {
+	homeTeam := ls.teamMap[match.HomeTeam.ID]
+	awayTeam := ls.teamMap[match.AwayTeam.ID]
+	return models.FixturePrediction{
+		Match:      match,
+		Prediction: ls.simulatorForWeek(matches, match.Week).PredictMatch(homeTeam, awayTeam),
+	}
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"testing"
+
+	"insider/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPredictionFromGrid(t *testing.T) {
+	// 0-0, 0-1, 1-0 and 1-1 only
+	prediction := predictionFromGrid(0.6, 0.5, [][]float64{{0.1, 0.2}, {0.3, 0.4}})
+
+	assert.InDelta(t, 0.3, prediction.HomeWin, 1e-9)
+	assert.InDelta(t, 0.5, prediction.Draw, 1e-9)
+	assert.InDelta(t, 0.2, prediction.AwayWin, 1e-9)
+	assert.InDelta(t, 0.4, prediction.BothTeamsToScore, 1e-9)
+	assert.Equal(t, []models.ScoreProbability{
+		{HomeScore: 1, AwayScore: 1, Probability: 0.4},
+		{HomeScore: 1, AwayScore: 0, Probability: 0.3},
+		{HomeScore: 0, AwayScore: 1, Probability: 0.2},
+		{HomeScore: 0, AwayScore: 0, Probability: 0.1},
+	}, prediction.MostLikelyScores)
+
+	if assert.Len(t, prediction.Goals, len(goalLines)) {
+		assert.Equal(t, 0.5, prediction.Goals[0].Line)
+		assert.InDelta(t, 0.9, prediction.Goals[0].Over, 1e-9)
+		assert.InDelta(t, 0.1, prediction.Goals[0].Under, 1e-9)
+		assert.InDelta(t, 0.4, prediction.Goals[1].Over, 1e-9)
+		assert.InDelta(t, 0.0, prediction.Goals[2].Over, 1e-9)
+	}
+}
+
+// The exact probabilities are those the simulator plays, as a long run of simulated matches shows
+func TestRandomizedMatchSimulator_PredictMatch(t *testing.T) {
+	home := models.Team{ID: 1, Attributes: models.TeamAttributes{Attack: 0.8, Defense: 0.6, Midfield: 0.7, HomeBoost: 0.4},
+		PlayStyle: models.PlayStyleAttacking}
+	away := models.Team{ID: 2, Attributes: models.TeamAttributes{Attack: 0.6, Defense: 0.7, Midfield: 0.5, HomeBoost: 0.2},
+		PlayStyle: models.PlayStyleDefensive}
+
+	for _, sim := range []MatchSimulator{NewMatchSimulator(), NewDixonColesMatchSimulator(), NewEloMatchSimulator()} {
+		prediction := sim.PredictMatch(home, away)
+		assert.InDelta(t, 1.0, prediction.HomeWin+prediction.Draw+prediction.AwayWin, 1e-9)
+
+		seeded := sim.WithSeed(21)
+		const n = 40000
+		wins, draws, both, overTwo := 0, 0, 0, 0
+		for range n {
+			result := seeded.SimulateMatch(home, away)
+			switch {
+			case result.HomeScore > result.AwayScore:
+				wins++
+			case result.IsDraw():
+				draws++
+			}
+			if result.HomeScore > 0 && result.AwayScore > 0 {
+				both++
+			}
+			if result.HomeScore+result.AwayScore > 2 {
+				overTwo++
+			}
+		}
+		assert.InDelta(t, prediction.HomeWin, float64(wins)/n, 0.01)
+		assert.InDelta(t, prediction.Draw, float64(draws)/n, 0.01)
+		assert.InDelta(t, prediction.BothTeamsToScore, float64(both)/n, 0.01)
+		assert.InDelta(t, prediction.Goals[2].Over, float64(overTwo)/n, 0.01)
+	}
+}
+
+func TestCappedPoissonProbabilities(t *testing.T) {
+	probabilities := cappedPoissonProbabilities(5)
+	assert.Len(t, probabilities, maxSimulatedGoals+1)
+
+	total := 0.0
+	for _, p := range probabilities {
+		total += p
+	}
+	assert.InDelta(t, 1.0, total, 1e-12)
+	assert.Greater(t, probabilities[maxSimulatedGoals], probabilities[maxSimulatedGoals-1],
+		"seven holds every higher score too")
+}
@@ -17,43 +17,83 @@ func NewMatchScheduler() MatchScheduler {
 	}
 }
 
-// GenerateSchedule creates a round-robin schedule for the given teams.
-// Assumes an even number of teams since task specifies 4 teams anyway.
+func (s *RoundRobinScheduler) WithSeed(seed int64) MatchScheduler {
+	return &RoundRobinScheduler{
+		random: newRandom(seed),
+	}
+}
+
+// GenerateSchedule creates a double round-robin schedule for the given teams using the circle method.
+// Every pair meets twice, once at each home ground; the second half of the season mirrors the first.
+// With an odd number of teams a bye slot is added, so one team rests each week.
 func (s *RoundRobinScheduler) GenerateSchedule(teams []models.Team) []models.Match {
-	n := len(teams)
+	if len(teams) < 2 {
+		return []models.Match{}
+	}
+
+	slots := make([]*models.Team, 0, len(teams)+1)
+	for i := range teams {
+		team := teams[i]
+		slots = append(slots, &team)
+	}
 
-	s.random.Shuffle(n, func(i, j int) {
-		teams[i], teams[j] = teams[j], teams[i]
+	s.random.Shuffle(len(slots), func(i, j int) {
+		slots[i], slots[j] = slots[j], slots[i]
 	})
 
-	matches := make([]models.Match, 0, n*(n-1))
+	if len(slots)%2 == 1 {
+		slots = append(slots, nil) // bye
+	}
 
-	matchID := 1
+	n := len(slots)
+	rounds := n - 1
 
-	weekCount := n * (n - 1) / 2
-	for week := range weekCount {
-		for i := range 2 {
-			home := teams[i]
-			away := teams[n-1-i]
+	firstHalf := make([][2]*models.Team, 0, rounds*n/2)
+	roundOf := make([]int, 0, rounds*n/2)
 
-			if week%2 == 1 {
+	for round := range rounds {
+		for i := range n / 2 {
+			home := slots[i]
+			away := slots[n-1-i]
+
+			// The fixed slot alternates home and away so it never plays a long run at one ground
+			if i == 0 && round%2 == 1 {
 				home, away = away, home
 			}
 
-			match := models.Match{
-				ID:       matchID,
-				Week:     week + 1,
-				HomeTeam: &home,
-				AwayTeam: &away,
-				IsPlayed: false,
+			if home == nil || away == nil {
+				continue
 			}
 
-			matchID++
-			matches = append(matches, match)
+			firstHalf = append(firstHalf, [2]*models.Team{home, away})
+			roundOf = append(roundOf, round)
 		}
 
-		// Rotate teams for next week
-		teams = append(teams[:1], append([]models.Team{teams[n-1]}, teams[1:n-1]...)...)
+		// Keep the first slot fixed and rotate the rest clockwise
+		last := slots[n-1]
+		copy(slots[2:], slots[1:n-1])
+		slots[1] = last
+	}
+
+	matches := make([]models.Match, 0, 2*len(firstHalf))
+	matchID := 1
+
+	for leg := range 2 {
+		for i, pair := range firstHalf {
+			home, away := pair[0], pair[1]
+			if leg == 1 {
+				home, away = away, home
+			}
+
+			matches = append(matches, models.Match{
+				ID:       matchID,
+				Week:     leg*rounds + roundOf[i] + 1,
+				HomeTeam: home,
+				AwayTeam: away,
+				IsPlayed: false,
+			})
+			matchID++
+		}
 	}
 
 	return matches
@@ -34,3 +34,61 @@ func TestRoundRobinScheduler_GenerateSchedule(t *testing.T) {
 		seen[key] = struct{}{}
 	}
 }
+
+func TestRoundRobinScheduler_ArbitraryTeamCounts(t *testing.T) {
+	for _, n := range []int{2, 3, 5, 6, 10, 18, 20} {
+		teams := make([]models.Team, n)
+		for i := range teams {
+			teams[i] = models.Team{ID: i + 1}
+		}
+
+		matches := NewMatchScheduler().GenerateSchedule(teams)
+		assert.Len(t, matches, n*(n-1), "n=%d: every pair should meet twice", n)
+
+		weeks := 2 * (n - 1)
+		if n%2 == 1 {
+			weeks = 2 * n // one bye per team per half
+		}
+
+		type pair struct{ home, away int }
+		fixtures := make(map[pair]int)
+		busy := make(map[[2]int]bool)
+		for _, m := range matches {
+			assert.GreaterOrEqual(t, m.Week, 1, "n=%d: week must be positive", n)
+			assert.LessOrEqual(t, m.Week, weeks, "n=%d: week %d out of range", n, m.Week)
+
+			for _, id := range []int{m.HomeTeam.ID, m.AwayTeam.ID} {
+				key := [2]int{m.Week, id}
+				assert.False(t, busy[key], "n=%d: team %d plays twice in week %d", n, id, m.Week)
+				busy[key] = true
+			}
+			fixtures[pair{m.HomeTeam.ID, m.AwayTeam.ID}]++
+		}
+
+		for i := 1; i <= n; i++ {
+			for j := 1; j <= n; j++ {
+				if i != j {
+					assert.Equal(t, 1, fixtures[pair{i, j}], "n=%d: %d should host %d exactly once", n, i, j)
+				}
+			}
+		}
+	}
+}
+
+func TestRoundRobinScheduler_DoesNotMutateInput(t *testing.T) {
+	teams := []models.Team{{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4}}
+	_ = NewMatchScheduler().GenerateSchedule(teams)
+
+	for i, team := range teams {
+		assert.Equal(t, i+1, team.ID, "input order should be preserved")
+	}
+}
+
+func TestRoundRobinScheduler_WithSeedIsReproducible(t *testing.T) {
+	teams := []models.Team{{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4}, {ID: 5}, {ID: 6}}
+
+	first := NewMatchScheduler().WithSeed(7).GenerateSchedule(teams)
+	second := NewMatchScheduler().WithSeed(7).GenerateSchedule(teams)
+
+	assert.Equal(t, first, second, "same seed should produce the same schedule")
+}
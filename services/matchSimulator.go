@@ -8,6 +8,14 @@ import (
 	"insider/models"
 )
 
+const (
+	// simulateGoalsFromExpected never returns more goals than this
+	maxSimulatedGoals int = 7
+	// Expected goals are held between these, however lopsided the match
+	minExpectedGoals float64 = 0.1
+	maxExpectedGoals float64 = 5.0
+)
+
 type matchupMatrix struct {
 	advantages [][]float64
 	styleIndex map[models.PlayStyle]int
@@ -40,6 +48,13 @@ func NewMatchSimulator() MatchSimulator {
 	}
 }
 
+func (sim *RandomizedMatchSimulator) WithSeed(seed int64) MatchSimulator {
+	return &RandomizedMatchSimulator{
+		random:        newRandom(seed),
+		matchupMatrix: sim.matchupMatrix, // read-only, safe to share
+	}
+}
+
 func (sim *RandomizedMatchSimulator) SimulateMatch(home, away models.Team) models.MatchResult {
 	headToHead := sim.calculateHeadToHeadMatchup(home, away)
 
@@ -50,10 +65,42 @@ func (sim *RandomizedMatchSimulator) SimulateMatch(home, away models.Team) model
 	homeGoals := sim.simulateGoalsFromExpected(homeExpectedGoals)
 	awayGoals := sim.simulateGoalsFromExpected(awayExpectedGoals)
 
-	return models.MatchResult{
-		HomeScore: homeGoals,
-		AwayScore: awayGoals,
+	// Drawn after the goals, so bookings and shootouts never change the scoreline a seed produces
+	result := models.MatchResult{
+		HomeScore:      homeGoals,
+		AwayScore:      awayGoals,
+		HomeDiscipline: sim.simulateDiscipline(home, true),
+		AwayDiscipline: sim.simulateDiscipline(away, false),
+	}
+
+	// Every draw gets a shootout, leagues without shootout points ignore it
+	if result.IsDraw() {
+		result.HomePenalties, result.AwayPenalties = sim.simulateShootout()
 	}
+	return result
+}
+
+// PredictMatch works out the exact probability of every scoreline from the expected goals SimulateMatch draws
+// the goals of each side from, independently
+func (sim *RandomizedMatchSimulator) PredictMatch(home, away models.Team) models.MatchPrediction {
+	headToHead := sim.calculateHeadToHeadMatchup(home, away)
+	homeExpectedGoals := sim.calculateExpectedGoals(home, away, true, headToHead)
+	awayExpectedGoals := sim.calculateExpectedGoals(away, home, false, -headToHead)
+
+	grid := independentGrid(cappedPoissonProbabilities(homeExpectedGoals), cappedPoissonProbabilities(awayExpectedGoals))
+	return predictionFromGrid(homeExpectedGoals, awayExpectedGoals, grid)
+}
+
+// cappedPoissonProbabilities returns the probability of each number of goals simulateGoalsFromExpected returns,
+// the chance of more than it allows going to the most it does
+func cappedPoissonProbabilities(expectedGoals float64) []float64 {
+	probabilities := poissonProbabilities(expectedGoals, maxSimulatedGoals)
+	tail := 1.0
+	for _, p := range probabilities[:maxSimulatedGoals] {
+		tail -= p
+	}
+	probabilities[maxSimulatedGoals] = max(tail, 0)
+	return probabilities
 }
 
 func (sim *RandomizedMatchSimulator) calculateHeadToHeadMatchup(home, away models.Team) float64 {
@@ -82,14 +129,11 @@ func (sim *RandomizedMatchSimulator) calculateExpectedGoals(attackingTeam, defen
 	expected += headToHead
 	expected += homeAdvantage
 
-	if expected < 0.1 {
-		expected = 0.1
-	}
-	if expected > 5.0 {
-		expected = 5.0
-	}
+	return clampExpectedGoals(expected)
+}
 
-	return expected
+func clampExpectedGoals(expected float64) float64 {
+	return math.Min(math.Max(expected, minExpectedGoals), maxExpectedGoals)
 }
 
 func (sim *RandomizedMatchSimulator) simulateGoalsFromExpected(expectedGoals float64) int {
@@ -97,10 +141,66 @@ func (sim *RandomizedMatchSimulator) simulateGoalsFromExpected(expectedGoals flo
 	L := math.Exp(-expectedGoals)
 	p := 1.0
 
-	for p > L && goals < 8 { // Limit to 8 goals
+	for p > L && goals <= maxSimulatedGoals {
 		goals++
 		p *= sim.random.Float64()
 	}
 
 	return goals - 1
 }
+
+// simulateDiscipline draws the disciplinary points of one side, a point per yellow card and three for a red.
+// Defensive sides foul more and away sides are booked a little more often.
+func (sim *RandomizedMatchSimulator) simulateDiscipline(team models.Team, isHome bool) int {
+	expectedYellows := 1.7
+	switch team.PlayStyle {
+	case models.PlayStyleDefensive:
+		expectedYellows += 0.4
+	case models.PlayStyleAttacking:
+		expectedYellows += 0.1
+	case models.PlayStylePossession:
+		expectedYellows -= 0.2
+	}
+	if !isHome {
+		expectedYellows += 0.2
+	}
+
+	// Bookings are as rare and independent as goals, so the same Poisson draw fits
+	points := sim.simulateGoalsFromExpected(expectedYellows)
+	if sim.random.Float64() < 0.05 {
+		points += 3
+	}
+	return points
+}
+
+// simulateShootout takes five penalties each, stopping once one side cannot be caught, then goes to sudden death
+func (sim *RandomizedMatchSimulator) simulateShootout() (int, int) {
+	const conversion = 0.75
+	home, away := 0, 0
+
+	for kick := range 5 {
+		if sim.random.Float64() < conversion {
+			home++
+		}
+		if home > away+5-kick || away > home+4-kick {
+			return home, away
+		}
+
+		if sim.random.Float64() < conversion {
+			away++
+		}
+		if home > away+4-kick || away > home+4-kick {
+			return home, away
+		}
+	}
+
+	for home == away {
+		if sim.random.Float64() < conversion {
+			home++
+		}
+		if sim.random.Float64() < conversion {
+			away++
+		}
+	}
+	return home, away
+}
@@ -0,0 +1,239 @@
+package services
+
+import (
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+
+	"insider/models"
+)
+
+const (
+	// DefaultOddsMargin is the overround of a book unless ODDS_MARGIN says otherwise, 5% as on a typical 1X2
+	DefaultOddsMargin float64 = 0.05
+	MaxOddsMargin     float64 = 0.5
+
+	// Decimal prices are kept between the shortest and the longest a bookmaker would offer
+	minDecimalOdds float64 = 1.01
+	maxDecimalOdds float64 = 1001
+	// Scorelines above this many goals a side are priced together in the correct score market
+	correctScoreMaxGoals int = 4
+)
+
+// ProportionalOddsGenerator spreads the margin over the selections of a market in proportion to their
+// probabilities, so every price is the fair one shortened by the same factor
+type ProportionalOddsGenerator struct {
+	margin float64
+}
+
+func NewOddsGenerator() OddsGenerator {
+	return &ProportionalOddsGenerator{margin: oddsMarginFromEnv()}
+}
+
+func (g *ProportionalOddsGenerator) Margin() float64 {
+	return g.margin
+}
+
+func (g *ProportionalOddsGenerator) WithMargin(margin float64) OddsGenerator {
+	return &ProportionalOddsGenerator{margin: margin}
+}
+
+func (g *ProportionalOddsGenerator) MatchMarkets(prediction models.MatchPrediction) []models.Market {
+	markets := []models.Market{
+		g.market(models.MarketMatchResult, []models.Selection{
+			{Name: "home", Probability: prediction.HomeWin},
+			{Name: "draw", Probability: prediction.Draw},
+			{Name: "away", Probability: prediction.AwayWin},
+		}),
+		g.correctScoreMarket(prediction.Scorelines),
+	}
+	for _, line := range prediction.Goals {
+		market := g.market(models.MarketOverUnder, []models.Selection{
+			{Name: "over", Probability: line.Over},
+			{Name: "under", Probability: line.Under},
+		})
+		market.Line = line.Line
+		markets = append(markets, market)
+	}
+	return markets
+}
+
+// correctScoreMarket prices every scoreline up to correctScoreMaxGoals a side from the grid, by home then away
+// goals, and the rest of the grid as "other"
+func (g *ProportionalOddsGenerator) correctScoreMarket(grid [][]float64) models.Market {
+	var selections []models.Selection
+	other := 0.0
+	for homeGoals, row := range grid {
+		for awayGoals, p := range row {
+			if homeGoals > correctScoreMaxGoals || awayGoals > correctScoreMaxGoals {
+				other += p
+				continue
+			}
+			selections = append(selections, models.Selection{
+				Name:        strconv.Itoa(homeGoals) + "-" + strconv.Itoa(awayGoals),
+				Probability: p,
+			})
+		}
+	}
+	selections = append(selections, models.Selection{Name: "other", Probability: other})
+	return g.market(models.MarketCorrectScore, selections)
+}
+
+// OutrightMarket prices the title, favourites first. Only teams the title race has eliminated are listed
+// unpriced; a team that never won it in the simulations but still can is offered at the longest price.
+func (g *ProportionalOddsGenerator) OutrightMarket(odds []models.ChampionshipOdds,
+	race []models.TitleStatus) models.Market {
+	eliminated := make(map[int]bool, len(race))
+	for _, status := range race {
+		eliminated[status.TeamID] = status.Eliminated
+	}
+
+	selections := make([]models.Selection, len(odds))
+	for i, o := range odds {
+		selections[i] = models.Selection{Name: o.TeamName, TeamID: o.TeamID, Probability: o.Probability}
+	}
+	sort.SliceStable(selections, func(i, j int) bool { return selections[i].Probability > selections[j].Probability })
+
+	market := g.market(models.MarketOutright, selections)
+	for i, selection := range market.Selections {
+		if selection.Price == nil && !eliminated[selection.TeamID] {
+			market.Selections[i].Price = decimalPrice(maxDecimalOdds)
+		}
+	}
+	market.Overround = overround(market.Selections)
+	return market
+}
+
+// market prices the selections, whose probabilities add up to 1
+func (g *ProportionalOddsGenerator) market(marketType models.MarketType, selections []models.Selection) models.Market {
+	market := models.Market{Type: marketType, Selections: selections}
+	for i := range market.Selections {
+		market.Selections[i].Price = g.price(market.Selections[i].Probability)
+	}
+	market.Overround = overround(market.Selections)
+	return market
+}
+
+// overround adds up the probabilities implied by the prices as offered, after rounding
+func overround(selections []models.Selection) float64 {
+	total := 0.0
+	for _, selection := range selections {
+		if selection.Price != nil {
+			total += 1 / selection.Price.Decimal
+		}
+	}
+	return math.Round(total*10000) / 10000
+}
+
+// price returns the price of a selection with the given fair probability, nil when it cannot win. Decimal odds
+// are rounded down to the hundredth, in favour of the book.
+func (g *ProportionalOddsGenerator) price(probability float64) *models.Price {
+	if probability <= 0 {
+		return nil
+	}
+	decimal := math.Floor(100/(probability*(1+g.margin))) / 100
+	return decimalPrice(math.Min(math.Max(decimal, minDecimalOdds), maxDecimalOdds))
+}
+
+// decimalPrice writes a decimal price in every format, each exactly the same price
+func decimalPrice(decimal float64) *models.Price {
+	return &models.Price{
+		Decimal:    decimal,
+		Fractional: fractionalOdds(decimal),
+		American:   americanOdds(decimal),
+	}
+}
+
+// fractionalOdds writes a decimal price, which has at most two decimals, as the profit per stake in lowest terms
+func fractionalOdds(decimal float64) string {
+	numerator, denominator := int(math.Round((decimal-1)*100)), 100
+	divisor := gcd(numerator, denominator)
+	return strconv.Itoa(numerator/divisor) + "/" + strconv.Itoa(denominator/divisor)
+}
+
+// americanOdds writes a decimal price as the profit on a 100 stake for an underdog, or the stake needed to win
+// 100 for a favourite
+func americanOdds(decimal float64) string {
+	if decimal >= 2 {
+		return "+" + strconv.Itoa(int(math.Round((decimal-1)*100)))
+	}
+	return "-" + strconv.Itoa(int(math.Round(100/(decimal-1))))
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// oddsMarginFromEnv reads ODDS_MARGIN, falling back to the default when unset or invalid
+func oddsMarginFromEnv() float64 {
+	value, ok := os.LookupEnv("ODDS_MARGIN")
+	if !ok || value == "" {
+		return DefaultOddsMargin
+	}
+
+	margin, err := strconv.ParseFloat(value, 64)
+	if err != nil || !(margin >= 0 && margin <= MaxOddsMargin) {
+		log.Printf("Ignoring invalid ODDS_MARGIN %q, expected 0 to %g", value, MaxOddsMargin)
+		return DefaultOddsMargin
+	}
+	return margin
+}
+
+// GetMarkets prices every unplayed match from its exact prediction, in schedule order, and the title from the
+// championship odds while it is still open
+func (ls *BasicLeagueService) GetMarkets(options MarketOptions) (*models.OddsBoard, error) {
+	generator := ls.oddsGenerator
+	if options.Margin != nil {
+		if !(*options.Margin >= 0 && *options.Margin <= MaxOddsMargin) {
+			return nil, &ValidationError{
+				Message: "margin must be between 0 and " + strconv.FormatFloat(MaxOddsMargin, 'g', -1, 64),
+			}
+		}
+		generator = generator.WithMargin(*options.Margin)
+	}
+
+	stateOptions := StateOptions{Iterations: options.Iterations}
+	if err := validateStateOptions(stateOptions); err != nil {
+		return nil, err
+	}
+
+	state, err := ls.db.GetSimulationState()
+	if err != nil {
+		return nil, err
+	}
+
+	matches, err := ls.db.GetMatches()
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := ls.resultLog(matches)
+	if err != nil {
+		return nil, err
+	}
+
+	// A book opens on the title from the first week, unlike the odds of the live state
+	simulation, err := ls.buildSimulation(state, matches, results, stateOptions, true)
+	if err != nil {
+		return nil, err
+	}
+
+	board := &models.OddsBoard{Margin: generator.Margin(), Matches: make([]models.MatchMarkets, 0)}
+	for _, match := range ls.getRemainingMatches(matches) {
+		prediction := ls.predictMatch(matches, match)
+		board.Matches = append(board.Matches, models.MatchMarkets{
+			Match:   match,
+			Markets: generator.MatchMarkets(prediction.Prediction),
+		})
+	}
+	if len(simulation.ChampionshipOdds) > 0 {
+		outright := generator.OutrightMarket(simulation.ChampionshipOdds, simulation.TitleRace)
+		board.Outright = &outright
+	}
+	return board, nil
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"testing"
+
+	"insider/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProportionalOddsGenerator_Price(t *testing.T) {
+	fair := NewOddsGenerator().WithMargin(0).(*ProportionalOddsGenerator)
+	assert.Equal(t, &models.Price{Decimal: 2, Fractional: "1/1", American: "+100"}, fair.price(0.5))
+	assert.Equal(t, &models.Price{Decimal: 3.5, Fractional: "5/2", American: "+250"}, fair.price(1/3.5))
+	assert.Equal(t, &models.Price{Decimal: 1.25, Fractional: "1/4", American: "-400"}, fair.price(0.8))
+	assert.Nil(t, fair.price(0), "a selection that cannot win is not offered")
+	assert.Equal(t, minDecimalOdds, fair.price(1).Decimal)
+	assert.Equal(t, maxDecimalOdds, fair.price(1e-9).Decimal)
+
+	// The margin shortens every price, rounding down in favour of the book
+	priced := fair.WithMargin(0.1).(*ProportionalOddsGenerator)
+	assert.Equal(t, 1.81, priced.price(0.5).Decimal)
+	assert.Equal(t, "81/100", priced.price(0.5).Fractional)
+	assert.Equal(t, "-123", priced.price(0.5).American)
+}
+
+func TestProportionalOddsGenerator_MatchMarkets(t *testing.T) {
+	home := models.Team{ID: 1, Attributes: models.TeamAttributes{Attack: 0.8, Defense: 0.6, Midfield: 0.7, HomeBoost: 0.4}}
+	away := models.Team{ID: 2, Attributes: models.TeamAttributes{Attack: 0.6, Defense: 0.7, Midfield: 0.5, HomeBoost: 0.2}}
+	prediction := NewDixonColesMatchSimulator().PredictMatch(home, away)
+
+	markets := NewOddsGenerator().WithMargin(0.05).MatchMarkets(prediction)
+	if !assert.Len(t, markets, 2+len(goalLines)) {
+		return
+	}
+
+	result := markets[0]
+	assert.Equal(t, models.MarketMatchResult, result.Type)
+	assert.Equal(t, "home", result.Selections[0].Name)
+	assert.Equal(t, prediction.HomeWin, result.Selections[0].Probability)
+	assert.Less(t, result.Selections[0].Price.Decimal, result.Selections[2].Price.Decimal, "the stronger side is the favourite")
+
+	correctScore := markets[1]
+	assert.Equal(t, models.MarketCorrectScore, correctScore.Type)
+	assert.Len(t, correctScore.Selections, (correctScoreMaxGoals+1)*(correctScoreMaxGoals+1)+1)
+	assert.Equal(t, "2-1", correctScore.Selections[2*(correctScoreMaxGoals+1)+1].Name)
+	assert.Equal(t, "other", correctScore.Selections[len(correctScore.Selections)-1].Name)
+	total := 0.0
+	for _, selection := range correctScore.Selections {
+		total += selection.Probability
+	}
+	assert.InDelta(t, 1.0, total, 1e-9)
+
+	overUnder := markets[2]
+	assert.Equal(t, models.MarketOverUnder, overUnder.Type)
+	assert.Equal(t, 0.5, overUnder.Line)
+	assert.Equal(t, []string{"over", "under"}, []string{overUnder.Selections[0].Name, overUnder.Selections[1].Name})
+
+	// Rounding down only ever adds to the margin, and by little on a market of few selections
+	assert.GreaterOrEqual(t, result.Overround, 1.05)
+	assert.InDelta(t, 1.05, result.Overround, 0.01)
+	assert.GreaterOrEqual(t, correctScore.Overround, 1.05)
+	// A near certainty is priced at the shortest odds offered, which gives up some of the margin
+	assert.Equal(t, minDecimalOdds, overUnder.Selections[0].Price.Decimal)
+	assert.Less(t, overUnder.Overround, 1.05)
+}
+
+func TestProportionalOddsGenerator_OutrightMarket(t *testing.T) {
+	market := NewOddsGenerator().WithMargin(0.2).OutrightMarket([]models.ChampionshipOdds{
+		{TeamID: 1, TeamName: "A", Probability: 0.25},
+		{TeamID: 2, TeamName: "B", Probability: 0.75},
+		{TeamID: 3, TeamName: "C", Probability: 0},
+		{TeamID: 4, TeamName: "D", Probability: 0},
+	}, []models.TitleStatus{
+		{TeamID: 1}, {TeamID: 2}, {TeamID: 3, Eliminated: true}, {TeamID: 4},
+	})
+
+	assert.Equal(t, models.MarketOutright, market.Type)
+	assert.Equal(t, []int{2, 1, 3}, []int{market.Selections[0].TeamID, market.Selections[1].TeamID, market.Selections[2].TeamID},
+		"favourites first")
+	assert.Equal(t, 1.11, market.Selections[0].Price.Decimal)
+	assert.Equal(t, 3.33, market.Selections[1].Price.Decimal)
+	assert.Nil(t, market.Selections[2].Price)
+	assert.Equal(t, 4, market.Selections[3].TeamID)
+	assert.Equal(t, maxDecimalOdds, market.Selections[3].Price.Decimal, "never won in the simulations, but still can")
+	assert.Equal(t, "1000/1", market.Selections[3].Price.Fractional)
+	assert.InDelta(t, 1.2, market.Overround, 0.01)
+}
+
+func TestOddsMarginFromEnv(t *testing.T) {
+	t.Setenv("ODDS_MARGIN", "0.1")
+	assert.Equal(t, 0.1, oddsMarginFromEnv())
+
+	for _, value := range []string{"", "-0.1", "0.9", "five", "NaN"} {
+		t.Setenv("ODDS_MARGIN", value)
+		assert.Equal(t, DefaultOddsMargin, oddsMarginFromEnv(), value)
+	}
+}
@@ -0,0 +1,329 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"insider/database"
+	"insider/models"
+)
+
+// Teams contesting the promotion playoff of a division
+const playoffTeams int = 4
+
+func (lm *BasicLeagueManager) GetPyramids() ([]models.Pyramid, error) {
+	return lm.db.GetPyramids()
+}
+
+func (lm *BasicLeagueManager) GetPyramid(pyramidID int) (*models.Pyramid, error) {
+	pyramid, err := lm.db.GetPyramid(pyramidID)
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, ErrPyramidNotFound
+	}
+	return pyramid, err
+}
+
+func (lm *BasicLeagueManager) CreatePyramid(name string, leagueIDs []int, places int, playoffs bool) (*models.Pyramid, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, &ValidationError{Message: "pyramid name is required"}
+	}
+	if len(leagueIDs) < 2 {
+		return nil, &ValidationError{Message: "a pyramid needs at least two divisions"}
+	}
+	if places < 1 {
+		return nil, &ValidationError{Message: "at least one team must go up and down between divisions"}
+	}
+
+	pyramids, err := lm.db.GetPyramids()
+	if err != nil {
+		return nil, err
+	}
+	for _, pyramid := range pyramids {
+		if strings.EqualFold(pyramid.Name, name) {
+			return nil, &ValidationError{Message: "a pyramid named " + name + " already exists"}
+		}
+	}
+
+	pyramid := models.Pyramid{Name: name, Places: places, Playoffs: playoffs}
+	rosters := make(map[int][]int, len(leagueIDs))
+	for i, leagueID := range leagueIDs {
+		if _, ok := rosters[leagueID]; ok {
+			return nil, &ValidationError{Message: "league " + strconv.Itoa(leagueID) + " is listed twice"}
+		}
+
+		league, err := lm.db.GetLeague(leagueID)
+		if errors.Is(err, database.ErrNotFound) {
+			return nil, ErrLeagueNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := lm.db.GetLeaguePyramid(leagueID); err == nil {
+			return nil, ErrLeagueInPyramid
+		} else if !errors.Is(err, database.ErrNotFound) {
+			return nil, err
+		}
+
+		pyramid.Divisions = append(pyramid.Divisions, models.Division{Level: i + 1, LeagueID: leagueID, Name: league.Name})
+		rosters[leagueID] = idsOf(league.Teams)
+	}
+
+	if err := validatePyramid(pyramid, rosters); err != nil {
+		return nil, err
+	}
+
+	pyramidID, err := lm.db.CreatePyramid(pyramid)
+	if err != nil {
+		return nil, err
+	}
+
+	// The tables of the divisions mark their zones from now on
+	lm.dropLeagues(leagueIDs...)
+	return lm.db.GetPyramid(pyramidID)
+}
+
+// DeletePyramid unlinks the divisions of a pyramid, which carry on as leagues of their own
+func (lm *BasicLeagueManager) DeletePyramid(pyramidID int) error {
+	pyramid, err := lm.GetPyramid(pyramidID)
+	if err != nil {
+		return err
+	}
+
+	if err := lm.db.DeletePyramid(pyramidID); err != nil {
+		return err
+	}
+
+	for _, division := range pyramid.Divisions {
+		lm.dropLeagues(division.LeagueID)
+	}
+	return nil
+}
+
+// StartNextSeason ends the season of a pyramid once every division has played all its matches. Teams in the
+// promotion zone go up, those in the relegation zone go down, the playoffs are played, and every division
+// starts its next season with a seed derived from the last one, archiving the finished season.
+func (lm *BasicLeagueManager) StartNextSeason(pyramidID int) (*models.PyramidSeason, error) {
+	// One pyramid season ends at a time, or two requests could both move the same teams
+	lm.seasons.Lock()
+	defer lm.seasons.Unlock()
+
+	pyramid, err := lm.GetPyramid(pyramidID)
+	if err != nil {
+		return nil, err
+	}
+
+	var movements []models.Movement
+	var playoffs []models.PlayoffMatch
+	// The tables are read and every division moves on in one transaction, so a result edited, cleared or
+	// rewound meanwhile cannot slip in between, and a failure leaves every division as it was
+	err = lm.db.Transaction(func(tx database.Database) error {
+		finals := make([]*models.LeagueSimulation, len(pyramid.Divisions))
+		tables := make([][]models.LeagueTableEntry, len(pyramid.Divisions))
+		for i, division := range pyramid.Divisions {
+			service, err := lm.buildLeague(tx, division.LeagueID)
+			if err != nil {
+				return err
+			}
+
+			final, err := service.GetCurrentState(StateOptions{})
+			if err != nil {
+				return err
+			}
+			if final.CurrentWeek <= final.MaxWeeks {
+				return ErrSeasonNotFinished
+			}
+			finals[i] = final
+			tables[i] = final.Table
+		}
+
+		// No two playoff matches of a division have the same pairing, so it keys the seed like a fixture does
+		movements, playoffs = pyramidMovements(*pyramid, tables, func(division int, home, away models.Team) models.MatchResult {
+			seed := deriveSeed(finals[division].Seed, seedStreamPlayoff, int64(home.ID), int64(away.ID))
+			return lm.matchSimulator.WithSeed(seed).SimulateMatch(home, away)
+		})
+
+		rosters := make(map[int][]int, len(pyramid.Divisions))
+		for i, division := range pyramid.Divisions {
+			for _, e := range tables[i] {
+				rosters[division.LeagueID] = append(rosters[division.LeagueID], e.Team.ID)
+			}
+		}
+		for _, movement := range movements {
+			from := rosters[movement.FromLeagueID]
+			for i, teamID := range from {
+				if teamID == movement.TeamID {
+					rosters[movement.FromLeagueID] = append(from[:i:i], from[i+1:]...)
+					break
+				}
+			}
+			rosters[movement.ToLeagueID] = append(rosters[movement.ToLeagueID], movement.TeamID)
+		}
+
+		for _, division := range pyramid.Divisions {
+			if err := tx.ForLeague(division.LeagueID).SetTeams(rosters[division.LeagueID]); err != nil {
+				return err
+			}
+		}
+		for i, division := range pyramid.Divisions {
+			service, err := lm.buildLeague(tx, division.LeagueID)
+			if err != nil {
+				return err
+			}
+			if err := service.ResetSimulation(deriveSeed(finals[i].Seed, seedStreamNextSeason)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, division := range pyramid.Divisions {
+		lm.dropLeagues(division.LeagueID)
+	}
+	return &models.PyramidSeason{Pyramid: *pyramid, Movements: movements, Playoffs: playoffs}, nil
+}
+
+// leaguePyramid returns the pyramid a league is a division of, nil if it stands alone
+func leaguePyramid(db database.Database, leagueID int) (*models.Pyramid, error) {
+	pyramid, err := db.GetLeaguePyramid(leagueID)
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, nil
+	}
+	return pyramid, err
+}
+
+// dropLeagues removes league services from the cache, so they are built again with their new setup on next use
+func (lm *BasicLeagueManager) dropLeagues(leagueIDs ...int) {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	for _, leagueID := range leagueIDs {
+		delete(lm.leagues, leagueID)
+	}
+}
+
+// validatePyramid checks that no team plays in two divisions and that every division is big enough for its
+// zones not to overlap. rosters holds the team IDs of each division by league ID.
+func validatePyramid(pyramid models.Pyramid, rosters map[int][]int) error {
+	divisionOf := make(map[int]string)
+	for _, division := range pyramid.Divisions {
+		roster := rosters[division.LeagueID]
+		for _, teamID := range roster {
+			if other, ok := divisionOf[teamID]; ok {
+				return &ValidationError{Message: "team " + strconv.Itoa(teamID) + " plays in both " + other + " and " + division.Name}
+			}
+			divisionOf[teamID] = division.Name
+		}
+
+		zones := divisionZones(pyramid, division.Level)
+		if needed := zones.Promotion + zones.Playoff + zones.Relegation; len(roster) < needed {
+			return &ValidationError{Message: division.Name + " needs at least " + strconv.Itoa(needed) +
+				" teams for its promotion and relegation places"}
+		}
+	}
+	return nil
+}
+
+// divisionZones returns the places leading out of the division at level: promotion for all but the top
+// division, relegation for all but the bottom one
+func divisionZones(pyramid models.Pyramid, level int) models.TableZones {
+	var zones models.TableZones
+	if level > 1 {
+		zones.Promotion = pyramid.Places
+		if pyramid.Playoffs {
+			zones.Promotion--
+			zones.Playoff = playoffTeams
+		}
+	}
+	if level < len(pyramid.Divisions) {
+		zones.Relegation = pyramid.Places
+	}
+	return zones
+}
+
+// pyramidMovements works out from the final tables of the divisions, ranked with their zones and listed from
+// the top down, which teams change division. play settles a playoff match of the division at the given index.
+func pyramidMovements(pyramid models.Pyramid, tables [][]models.LeagueTableEntry,
+	play func(division int, home, away models.Team) models.MatchResult) ([]models.Movement, []models.PlayoffMatch) {
+	movements := make([]models.Movement, 0)
+	var playoffs []models.PlayoffMatch
+
+	move := func(team models.Team, from, to int, zone models.Zone) {
+		movements = append(movements, models.Movement{
+			TeamID:       team.ID,
+			TeamName:     team.Name,
+			FromLeagueID: pyramid.Divisions[from].LeagueID,
+			ToLeagueID:   pyramid.Divisions[to].LeagueID,
+			Zone:         zone,
+		})
+	}
+
+	for i, table := range tables {
+		var contenders []models.Team
+		for _, e := range table {
+			switch e.Zone {
+			case models.ZonePromotion:
+				move(e.Team, i, i-1, models.ZonePromotion)
+			case models.ZonePlayoff:
+				contenders = append(contenders, e.Team)
+			case models.ZoneRelegation:
+				move(e.Team, i, i+1, models.ZoneRelegation)
+			}
+		}
+
+		if len(contenders) == playoffTeams {
+			leagueID := pyramid.Divisions[i].LeagueID
+			placed := make(map[int]int, playoffTeams)
+			for n, team := range contenders {
+				placed[team.ID] = n
+			}
+
+			// Whoever finished higher hosts
+			knockout := func(round models.PlayoffRound, home, away models.Team) models.Team {
+				if placed[away.ID] < placed[home.ID] {
+					home, away = away, home
+				}
+				result := play(i, home, away)
+				playoffs = append(playoffs, models.PlayoffMatch{
+					LeagueID: leagueID,
+					Round:    round,
+					Match:    models.Match{HomeTeam: &home, AwayTeam: &away, Result: result, IsPlayed: true},
+				})
+				return playoffWinner(result, home, away)
+			}
+
+			// The best placed meets the worst placed
+			first := knockout(models.PlayoffSemiFinal, contenders[0], contenders[3])
+			second := knockout(models.PlayoffSemiFinal, contenders[1], contenders[2])
+			move(knockout(models.PlayoffFinal, first, second), i, i-1, models.ZonePlayoff)
+		}
+	}
+	return movements, playoffs
+}
+
+func playoffWinner(result models.MatchResult, home, away models.Team) models.Team {
+	switch {
+	case result.HomeScore != result.AwayScore:
+		if result.HomeScore > result.AwayScore {
+			return home
+		}
+		return away
+	case result.HomePenalties >= result.AwayPenalties:
+		return home
+	default:
+		return away
+	}
+}
+
+func idsOf(teams []models.Team) []int {
+	ids := make([]int, len(teams))
+	for i, team := range teams {
+		ids[i] = team.ID
+	}
+	return ids
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"testing"
+
+	"insider/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func pyramidTable(zones models.TableZones, ids ...int) []models.LeagueTableEntry {
+	table := make([]models.LeagueTableEntry, len(ids))
+	for i, id := range ids {
+		table[i] = models.LeagueTableEntry{Position: i + 1, Team: models.Team{ID: id, Name: string(rune('A' + id - 1))}}
+	}
+	markZones(table, zones)
+	return table
+}
+
+func TestDivisionZones(t *testing.T) {
+	pyramid := models.Pyramid{Places: 2, Divisions: make([]models.Division, 3)}
+	assert.Equal(t, models.TableZones{Relegation: 2}, divisionZones(pyramid, 1))
+	assert.Equal(t, models.TableZones{Promotion: 2, Relegation: 2}, divisionZones(pyramid, 2))
+	assert.Equal(t, models.TableZones{Promotion: 2}, divisionZones(pyramid, 3))
+
+	pyramid.Playoffs = true
+	assert.Equal(t, models.TableZones{Promotion: 1, Playoff: 4, Relegation: 2}, divisionZones(pyramid, 2))
+}
+
+func TestMarkZones(t *testing.T) {
+	table := pyramidTable(models.TableZones{Promotion: 1, Playoff: 2, Relegation: 1}, 1, 2, 3, 4, 5)
+	var zones []models.Zone
+	for _, e := range table {
+		zones = append(zones, e.Zone)
+	}
+	assert.Equal(t, []models.Zone{models.ZonePromotion, models.ZonePlayoff, models.ZonePlayoff, "", models.ZoneRelegation}, zones)
+}
+
+func TestPyramidMovements(t *testing.T) {
+	pyramid := models.Pyramid{Places: 1, Divisions: []models.Division{
+		{Level: 1, LeagueID: 10}, {Level: 2, LeagueID: 20}, {Level: 3, LeagueID: 30},
+	}}
+	tables := [][]models.LeagueTableEntry{
+		pyramidTable(divisionZones(pyramid, 1), 1, 2, 3),
+		pyramidTable(divisionZones(pyramid, 2), 4, 5, 6),
+		pyramidTable(divisionZones(pyramid, 3), 7, 8, 9),
+	}
+
+	movements, playoffs := pyramidMovements(pyramid, tables, func(int, models.Team, models.Team) models.MatchResult {
+		t.Fatal("no playoffs without playoff places")
+		return models.MatchResult{}
+	})
+	assert.Empty(t, playoffs)
+	assert.Equal(t, []models.Movement{
+		{TeamID: 3, TeamName: "C", FromLeagueID: 10, ToLeagueID: 20, Zone: models.ZoneRelegation},
+		{TeamID: 4, TeamName: "D", FromLeagueID: 20, ToLeagueID: 10, Zone: models.ZonePromotion},
+		{TeamID: 6, TeamName: "F", FromLeagueID: 20, ToLeagueID: 30, Zone: models.ZoneRelegation},
+		{TeamID: 7, TeamName: "G", FromLeagueID: 30, ToLeagueID: 20, Zone: models.ZonePromotion},
+	}, movements)
+}
+
+func TestPyramidMovements_Playoffs(t *testing.T) {
+	pyramid := models.Pyramid{Places: 2, Playoffs: true, Divisions: []models.Division{{Level: 1, LeagueID: 10}, {Level: 2, LeagueID: 20}}}
+	tables := [][]models.LeagueTableEntry{
+		pyramidTable(divisionZones(pyramid, 1), 1, 2, 3, 4),
+		pyramidTable(divisionZones(pyramid, 2), 5, 6, 7, 8, 9, 10),
+	}
+
+	// The away side wins the first semi-final, the second goes to penalties, and the final to the lower placed
+	results := map[[2]int]models.MatchResult{
+		{6, 9}: {HomeScore: 0, AwayScore: 1},
+		{7, 8}: {HomeScore: 2, AwayScore: 2, HomePenalties: 4, AwayPenalties: 5},
+		{8, 9}: {HomeScore: 1, AwayScore: 3},
+	}
+	movements, playoffs := pyramidMovements(pyramid, tables, func(division int, home, away models.Team) models.MatchResult {
+		assert.Equal(t, 1, division)
+		result, ok := results[[2]int{home.ID, away.ID}]
+		assert.True(t, ok, "unexpected pairing %d v %d", home.ID, away.ID)
+		return result
+	})
+
+	var rounds []models.PlayoffRound
+	for _, p := range playoffs {
+		rounds = append(rounds, p.Round)
+		assert.Equal(t, 20, p.LeagueID)
+	}
+	assert.Equal(t, []models.PlayoffRound{models.PlayoffSemiFinal, models.PlayoffSemiFinal, models.PlayoffFinal}, rounds)
+
+	// The higher placed 8 hosts the final against 9
+	assert.Equal(t, 8, playoffs[2].Match.HomeTeam.ID)
+	assert.Equal(t, []models.Movement{
+		{TeamID: 3, TeamName: "C", FromLeagueID: 10, ToLeagueID: 20, Zone: models.ZoneRelegation},
+		{TeamID: 4, TeamName: "D", FromLeagueID: 10, ToLeagueID: 20, Zone: models.ZoneRelegation},
+		{TeamID: 5, TeamName: "E", FromLeagueID: 20, ToLeagueID: 10, Zone: models.ZonePromotion},
+		{TeamID: 9, TeamName: "I", FromLeagueID: 20, ToLeagueID: 10, Zone: models.ZonePlayoff},
+	}, movements)
+}
@@ -0,0 +1,240 @@
+package services
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"insider/models"
+)
+
+// CSV header names of the ResultEntry fields. The football-data.co.uk names are understood too, so their
+// files can be imported as they are; other columns are ignored.
+var resultColumns = map[string]string{
+	"match_id":       "match_id",
+	"home_team":      "home_team",
+	"away_team":      "away_team",
+	"home_score":     "home_score",
+	"away_score":     "away_score",
+	"home_penalties": "home_penalties",
+	"away_penalties": "away_penalties",
+	"hometeam":       "home_team",
+	"awayteam":       "away_team",
+	"fthg":           "home_score",
+	"ftag":           "away_score",
+}
+
+// resultUpdate is a batch row resolved to the match it scores
+type resultUpdate struct {
+	index  int // into the matches of the league
+	result models.MatchResult
+}
+
+// ParseResultsCSV reads a batch of results from CSV with a header row. Rows that cannot be read are reported
+// together in a BatchError.
+func ParseResultsCSV(r io.Reader) ([]models.ResultEntry, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, &ValidationError{Message: "the CSV is empty"}
+	}
+	if err != nil {
+		return nil, &ValidationError{Message: "invalid CSV: " + err.Error()}
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		if field, ok := resultColumns[strings.ToLower(strings.TrimSpace(name))]; ok {
+			columns[field] = i
+		}
+	}
+	_, byID := columns["match_id"]
+	_, byHome := columns["home_team"]
+	_, byAway := columns["away_team"]
+	_, hasHomeScore := columns["home_score"]
+	_, hasAwayScore := columns["away_score"]
+	if !(byID || byHome && byAway) || !hasHomeScore || !hasAwayScore {
+		return nil, &ValidationError{Message: "the CSV header needs home_score, away_score and either match_id " +
+			"or home_team and away_team"}
+	}
+
+	var entries []models.ResultEntry
+	var rowErrors []RowError
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, &ValidationError{Message: "invalid CSV: " + err.Error()}
+		}
+
+		cell := func(field string) string {
+			if i, ok := columns[field]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		number := func(field string) (*int, bool) {
+			if cell(field) == "" {
+				return nil, true
+			}
+			n, err := strconv.Atoi(cell(field))
+			if err != nil {
+				rowErrors = append(rowErrors, RowError{Row: row, Message: field + " must be a whole number"})
+				return nil, false
+			}
+			return &n, true
+		}
+
+		entry := models.ResultEntry{HomeTeam: cell("home_team"), AwayTeam: cell("away_team")}
+		matchID, ok := number("match_id")
+		if !ok {
+			continue
+		}
+		if matchID != nil {
+			entry.MatchID = *matchID
+		}
+		if entry.HomeScore, ok = number("home_score"); !ok {
+			continue
+		}
+		if entry.AwayScore, ok = number("away_score"); !ok {
+			continue
+		}
+		homePenalties, ok := number("home_penalties")
+		if !ok {
+			continue
+		}
+		awayPenalties, ok := number("away_penalties")
+		if !ok {
+			continue
+		}
+		if homePenalties != nil {
+			entry.HomePenalties = *homePenalties
+		}
+		if awayPenalties != nil {
+			entry.AwayPenalties = *awayPenalties
+		}
+		entries = append(entries, entry)
+	}
+
+	if len(rowErrors) > 0 {
+		return nil, &BatchError{Rows: rowErrors}
+	}
+	return entries, nil
+}
+
+// resolveResults matches every entry of a batch to a match of the league that may be given a result in
+// currentWeek under points. Every offending row is reported, so a batch can be fixed in one go.
+func resolveResults(matches []models.Match, entries []models.ResultEntry, currentWeek int,
+	points models.PointsSystem) ([]resultUpdate, error) {
+	if len(entries) == 0 {
+		return nil, &ValidationError{Message: "the batch has no results"}
+	}
+
+	byID := make(map[int]int, len(matches))
+	byTeams := make(map[[2]string]int, len(matches))
+	for i, m := range matches {
+		byID[m.ID] = i
+		byTeams[[2]string{strings.ToLower(m.HomeTeam.Name), strings.ToLower(m.AwayTeam.Name)}] = i
+	}
+
+	updates := make([]resultUpdate, 0, len(entries))
+	var rowErrors []RowError
+	seen := make(map[int]int, len(entries)) // match index -> row
+	for n, entry := range entries {
+		row := n + 1
+		fail := func(message string) {
+			rowErrors = append(rowErrors, RowError{Row: row, Message: message})
+		}
+
+		var i int
+		var ok bool
+		homeName, awayName := strings.TrimSpace(entry.HomeTeam), strings.TrimSpace(entry.AwayTeam)
+		switch {
+		case entry.MatchID != 0 && (homeName != "" || awayName != ""):
+			fail("give either match_id or home_team and away_team, not both")
+			continue
+		case entry.MatchID != 0:
+			if i, ok = byID[entry.MatchID]; !ok {
+				fail("match " + strconv.Itoa(entry.MatchID) + " is not part of this league")
+				continue
+			}
+		case homeName != "" && awayName != "":
+			if i, ok = byTeams[[2]string{strings.ToLower(homeName), strings.ToLower(awayName)}]; !ok {
+				fail("there is no match of " + homeName + " at home to " + awayName)
+				continue
+			}
+		default:
+			fail("give either match_id or home_team and away_team")
+			continue
+		}
+
+		if earlier, ok := seen[i]; ok {
+			fail("the match is already scored in row " + strconv.Itoa(earlier))
+			continue
+		}
+		seen[i] = row
+
+		if entry.HomeScore == nil || entry.AwayScore == nil {
+			fail("both scores are required")
+			continue
+		}
+		if *entry.HomeScore < 0 || *entry.AwayScore < 0 {
+			fail("scores cannot be negative")
+			continue
+		}
+		if err := checkResultWeek(matches[i], currentWeek); err != nil {
+			fail(err.Error())
+			continue
+		}
+		result := models.MatchResult{
+			HomeScore:     *entry.HomeScore,
+			AwayScore:     *entry.AwayScore,
+			HomePenalties: entry.HomePenalties,
+			AwayPenalties: entry.AwayPenalties,
+		}
+		if err := checkPenalties(result, points); err != nil {
+			fail(err.Error())
+			continue
+		}
+
+		updates = append(updates, resultUpdate{index: i, result: result})
+	}
+
+	if len(rowErrors) > 0 {
+		return nil, &BatchError{Rows: rowErrors}
+	}
+	return updates, nil
+}
+
+// checkResultWeek refuses results for weeks after the current one, which are played in order
+func checkResultWeek(match models.Match, currentWeek int) error {
+	if match.Week > currentWeek {
+		return &PolicyError{Message: "match " + strconv.Itoa(match.ID) + " is in week " + strconv.Itoa(match.Week) +
+			", results can only be entered up to the current week (" + strconv.Itoa(currentWeek) + ")"}
+	}
+	return nil
+}
+
+// checkPenalties refuses a shootout that cannot have taken place, and a draw left undecided in a league that
+// settles draws on penalties. No penalties on either side means there was no shootout.
+func checkPenalties(result models.MatchResult, points models.PointsSystem) error {
+	shootout := result.HomePenalties != 0 || result.AwayPenalties != 0
+	switch {
+	case result.HomePenalties < 0 || result.AwayPenalties < 0:
+		return &ValidationError{Message: "penalties cannot be negative"}
+	case shootout && !result.IsDraw():
+		return &ValidationError{Message: "only a drawn match goes to penalties"}
+	case shootout && result.HomePenalties == result.AwayPenalties:
+		return &ValidationError{Message: "a shootout cannot end level"}
+	case !shootout && result.IsDraw() && points.Shootout:
+		return &PolicyError{Message: "the league decides draws on penalties, give home_penalties and away_penalties"}
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"insider/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseResultsCSV(t *testing.T) {
+	entries, err := ParseResultsCSV(strings.NewReader("match_id,home_score,away_score\n11,0,0\n12, 3 ,1\n"))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, 11, entries[0].MatchID)
+	assert.Equal(t, 0, *entries[0].HomeScore)
+	assert.Equal(t, 3, *entries[1].HomeScore)
+
+	// football-data.co.uk columns, with the ones that do not matter skipped
+	entries, err = ParseResultsCSV(strings.NewReader("Div,Date,HomeTeam,AwayTeam,FTHG,FTAG,FTR\nE0,12/08/2023,B,A,2,1,H\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "B", entries[0].HomeTeam)
+	assert.Equal(t, "A", entries[0].AwayTeam)
+	assert.Equal(t, 1, *entries[0].AwayScore)
+
+	// A shootout goes in optional columns
+	entries, err = ParseResultsCSV(strings.NewReader("match_id,home_score,away_score,home_penalties,away_penalties\n11,1,1,4,3\n12,2,0,,\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, 4, entries[0].HomePenalties)
+	assert.Equal(t, 3, entries[0].AwayPenalties)
+	assert.Zero(t, entries[1].HomePenalties)
+
+	// A missing score is left for the batch validation to report
+	entries, err = ParseResultsCSV(strings.NewReader("match_id,home_score,away_score\n11,,2\n"))
+	assert.NoError(t, err)
+	assert.Nil(t, entries[0].HomeScore)
+
+	_, err = ParseResultsCSV(strings.NewReader("match_id,home_score,away_score\n11,two,0\n12,1,0\n13,1,x\n"))
+	var batchErr *BatchError
+	assert.ErrorAs(t, err, &batchErr)
+	assert.Equal(t, []RowError{
+		{Row: 1, Message: "home_score must be a whole number"},
+		{Row: 3, Message: "away_score must be a whole number"},
+	}, batchErr.Rows)
+
+	for _, input := range []string{"", "match_id,home_score\n11,1\n", "home_team,home_score,away_score\nA,1,0\n"} {
+		_, err = ParseResultsCSV(strings.NewReader(input))
+		var validationErr *ValidationError
+		assert.ErrorAs(t, err, &validationErr, input)
+	}
+}
+
+func TestResolveResults(t *testing.T) {
+	matches := scenarioFixture()
+	one, two, negative := 1, 2, -1
+
+	updates, err := resolveResults(matches, []models.ResultEntry{
+		{MatchID: 10, HomeScore: &two, AwayScore: &one},
+		{HomeTeam: " b ", AwayTeam: "A", HomeScore: &one, AwayScore: &one},
+	}, 2, models.DefaultPointsSystem)
+	assert.NoError(t, err)
+	assert.Equal(t, []resultUpdate{
+		{index: 0, result: models.MatchResult{HomeScore: 2, AwayScore: 1}},
+		{index: 1, result: models.MatchResult{HomeScore: 1, AwayScore: 1}},
+	}, updates)
+
+	// Every bad row is reported, not just the first
+	_, err = resolveResults(matches, []models.ResultEntry{
+		{MatchID: 99, HomeScore: &one, AwayScore: &one},
+		{HomeTeam: "A", AwayTeam: "C", HomeScore: &one, AwayScore: &one},
+		{MatchID: 10, HomeTeam: "A", AwayTeam: "B", HomeScore: &one, AwayScore: &one},
+		{HomeScore: &one, AwayScore: &one},
+		{MatchID: 11, HomeScore: &one},
+		{MatchID: 11, HomeScore: &negative, AwayScore: &one},
+		{MatchID: 10, HomeScore: &one, AwayScore: &one},
+		{MatchID: 10, HomeScore: &one, AwayScore: &one},
+		{MatchID: 12, HomeScore: &one, AwayScore: &one},
+	}, 2, models.DefaultPointsSystem)
+	var batchErr *BatchError
+	assert.ErrorAs(t, err, &batchErr)
+	rows := make([]int, len(batchErr.Rows))
+	for i, r := range batchErr.Rows {
+		rows[i] = r.Row
+	}
+	assert.Equal(t, []int{1, 2, 3, 4, 5, 6, 8, 9}, rows)
+	assert.Equal(t, "the match is already scored in row 7", batchErr.Rows[6].Message)
+
+	_, err = resolveResults(matches, nil, 2, models.DefaultPointsSystem)
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+}
+
+func TestResolveResultsPenalties(t *testing.T) {
+	matches := scenarioFixture()
+	zero, one, two := 0, 1, 2
+	shootout := models.PointsSystem{Win: 3, Loss: 0, Shootout: true, ShootoutWin: 2, ShootoutLoss: 1}
+
+	updates, err := resolveResults(matches, []models.ResultEntry{
+		{MatchID: 10, HomeScore: &one, AwayScore: &one, HomePenalties: 5, AwayPenalties: 4},
+		{MatchID: 11, HomeScore: &two, AwayScore: &one},
+	}, 2, shootout)
+	assert.NoError(t, err)
+	assert.Equal(t, models.MatchResult{HomeScore: 1, AwayScore: 1, HomePenalties: 5, AwayPenalties: 4}, updates[0].result)
+
+	_, err = resolveResults(matches, []models.ResultEntry{
+		{MatchID: 10, HomeScore: &two, AwayScore: &one, HomePenalties: 5, AwayPenalties: 4},
+		{MatchID: 11, HomeScore: &zero, AwayScore: &zero},
+		{MatchID: 12, HomeScore: &one, AwayScore: &one, HomePenalties: 3, AwayPenalties: 3},
+	}, 3, shootout)
+	var batchErr *BatchError
+	assert.ErrorAs(t, err, &batchErr)
+	assert.Equal(t, []RowError{
+		{Row: 1, Message: "only a drawn match goes to penalties"},
+		{Row: 2, Message: "the league decides draws on penalties, give home_penalties and away_penalties"},
+		{Row: 3, Message: "a shootout cannot end level"},
+	}, batchErr.Rows)
+
+	// Without shootout points a draw needs none
+	_, err = resolveResults(matches, []models.ResultEntry{{MatchID: 11, HomeScore: &zero, AwayScore: &zero}}, 2,
+		models.DefaultPointsSystem)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"insider/models"
+)
+
+// resultLog rebuilds the results of the completed weeks of a season from its audit log, so a result entered,
+// edited or cleared after a week ended does not show in that week
+type resultLog struct {
+	matches []models.Match
+	// before holds the results as they were before the first event of the season
+	before []models.Match
+	events []models.Event
+	index  map[int]int
+}
+
+// resultLog reads the events of the season behind matches, the matches of the league as they stand
+func (ls *BasicLeagueService) resultLog(matches []models.Match) (*resultLog, error) {
+	events, err := ls.db.GetSeasonEvents()
+	if err != nil {
+		return nil, err
+	}
+	return newResultLog(matches, events), nil
+}
+
+func newResultLog(matches []models.Match, events []models.Event) *resultLog {
+	rl := &resultLog{matches: matches, events: events, index: make(map[int]int, len(matches))}
+	for i, match := range matches {
+		rl.index[match.ID] = i
+	}
+
+	// Taking back every change, newest first, leaves the results from before the log, e.g. those of a
+	// database migrated halfway through a season
+	rl.before = make([]models.Match, len(matches))
+	copy(rl.before, matches)
+	for i := len(events) - 1; i >= 0; i-- {
+		event := events[i]
+		if row, ok := rl.resultEvent(event); ok {
+			setMatchResult(&rl.before[row], event.OldResult, "")
+		}
+	}
+	return rl
+}
+
+// resultEvent tells whether event changed the result of one of the matches, and which
+func (rl *resultLog) resultEvent(event models.Event) (int, bool) {
+	switch event.Type {
+	case models.EventResultSimulated, models.EventResultEdited, models.EventResultCleared:
+		row, ok := rl.index[event.MatchID]
+		return row, ok
+	}
+	return 0, false
+}
+
+// asOfWeek returns a copy of the matches with the results they had at the end of week, the last time the league
+// moved on to the week after it. Without such an event, for a week completed before the log was kept, every
+// result after week is undone and the others are taken as they stand.
+func (rl *resultLog) asOfWeek(week int) []models.Match {
+	end := -1
+	for i, event := range rl.events {
+		if (event.Type == models.EventWeekAdvanced || event.Type == models.EventWeekRewound) && event.NewWeek == week+1 {
+			end = i
+		}
+	}
+
+	out := make([]models.Match, len(rl.matches))
+	if end < 0 {
+		copy(out, rl.matches)
+		for i := range out {
+			if out[i].Week > week {
+				setMatchResult(&out[i], nil, "")
+			}
+		}
+		return out
+	}
+
+	copy(out, rl.before)
+	for _, event := range rl.events[:end+1] {
+		if row, ok := rl.resultEvent(event); ok {
+			setMatchResult(&out[row], event.NewResult, event.Origin)
+		}
+	}
+	return out
+}
+
+// setMatchResult gives match the result, or takes its result away if there is none
+func setMatchResult(match *models.Match, result *models.MatchResult, origin models.Origin) {
+	if result == nil {
+		match.Result = models.MatchResult{}
+		match.IsPlayed = false
+		match.Origin = ""
+		return
+	}
+	match.Result = *result
+	match.IsPlayed = true
+	match.Origin = origin
+}
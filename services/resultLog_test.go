@@ -0,0 +1,64 @@
+package services
+
+import (
+	"testing"
+
+	"insider/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResultLogAsOfWeek(t *testing.T) {
+	a := &models.Team{ID: 1, Name: "A"}
+	b := &models.Team{ID: 2, Name: "B"}
+	score := func(home, away int) *models.MatchResult { return &models.MatchResult{HomeScore: home, AwayScore: away} }
+	match := func(id, week int, result *models.MatchResult, origin models.Origin) models.Match {
+		m := models.Match{ID: id, Week: week, HomeTeam: a, AwayTeam: b}
+		if result != nil {
+			m.Result, m.IsPlayed, m.Origin = *result, true, origin
+		}
+		return m
+	}
+
+	// Match 1 was played before the log was kept, then the league played week 2, and weeks later the
+	// result of week 1 was edited and that of week 2 cleared
+	events := []models.Event{
+		{Type: models.EventSeasonReset},
+		{Type: models.EventResultSimulated, MatchID: 2, NewResult: score(1, 1), Origin: models.OriginSimulated},
+		{Type: models.EventWeekAdvanced, Week: 2, NewWeek: 3},
+		{Type: models.EventWeekAdvanced, Week: 3, NewWeek: 4},
+		{Type: models.EventResultEdited, MatchID: 1, OldResult: score(2, 0), NewResult: score(0, 3),
+			Origin: models.OriginManual},
+		{Type: models.EventResultCleared, MatchID: 2, OldResult: score(1, 1)},
+	}
+	matches := []models.Match{
+		match(1, 1, score(0, 3), models.OriginManual),
+		match(2, 2, nil, ""),
+		match(3, 3, nil, ""),
+	}
+	results := newResultLog(matches, events)
+
+	assert.Equal(t, []models.Match{
+		match(1, 1, score(2, 0), ""),
+		match(2, 2, score(1, 1), models.OriginSimulated),
+		match(3, 3, nil, ""),
+	}, results.asOfWeek(2))
+	assert.Equal(t, results.asOfWeek(2), results.asOfWeek(3), "nothing was played in week 3")
+
+	// Week 1 ended before the log was kept, so only later results are undone
+	assert.Equal(t, []models.Match{
+		match(1, 1, score(0, 3), models.OriginManual),
+		match(2, 2, nil, ""),
+		match(3, 3, nil, ""),
+	}, results.asOfWeek(1))
+
+	// A rewind ends a week again, with the results standing then
+	events = append(events,
+		models.Event{Type: models.EventResultEdited, MatchID: 2, NewResult: score(4, 0), Origin: models.OriginManual},
+		models.Event{Type: models.EventWeekRewound, Week: 4, NewWeek: 3},
+		models.Event{Type: models.EventResultEdited, MatchID: 2, OldResult: score(4, 0), NewResult: score(5, 0),
+			Origin: models.OriginManual},
+	)
+	matches[1] = match(2, 2, score(5, 0), models.OriginManual)
+	assert.Equal(t, match(2, 2, score(4, 0), models.OriginManual), newResultLog(matches, events).asOfWeek(2)[1])
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"fmt"
+
+	"insider/models"
+)
+
+// applyScenarios returns a copy of matches with the hypothetical results filled in. An exact score plays the
+// match, an outcome leaves it unplayed but constrains how the predictor may simulate it.
+func applyScenarios(matches []models.Match, scenarios []models.Scenario) ([]models.Match, error) {
+	out := make([]models.Match, len(matches))
+	copy(out, matches)
+
+	rows := make(map[int]int, len(out)) // match ID -> index in out
+	for i, m := range out {
+		rows[m.ID] = i
+	}
+
+	seen := make(map[int]bool, len(scenarios))
+	for _, sc := range scenarios {
+		i, ok := rows[sc.MatchID]
+		if !ok {
+			return nil, ErrMatchNotFound
+		}
+		if seen[sc.MatchID] {
+			return nil, &ValidationError{Message: fmt.Sprintf("match %d appears in more than one scenario", sc.MatchID)}
+		}
+		seen[sc.MatchID] = true
+
+		if out[i].IsPlayed {
+			return nil, &ValidationError{Message: fmt.Sprintf("match %d has already been played", sc.MatchID)}
+		}
+
+		hasScore := sc.HomeScore != nil || sc.AwayScore != nil
+		switch {
+		case hasScore && sc.Outcome != "":
+			return nil, &ValidationError{Message: fmt.Sprintf("match %d: set either a score or an outcome, not both", sc.MatchID)}
+		case hasScore:
+			if sc.HomeScore == nil || sc.AwayScore == nil {
+				return nil, &ValidationError{Message: fmt.Sprintf("match %d: both scores are required", sc.MatchID)}
+			}
+			if *sc.HomeScore < 0 || *sc.AwayScore < 0 {
+				return nil, &ValidationError{Message: fmt.Sprintf("match %d: scores cannot be negative", sc.MatchID)}
+			}
+			out[i].Result = models.MatchResult{HomeScore: *sc.HomeScore, AwayScore: *sc.AwayScore}
+			out[i].IsPlayed = true
+		case sc.Outcome.IsValid():
+			out[i].Outcome = sc.Outcome
+		default:
+			return nil, &ValidationError{Message: fmt.Sprintf("match %d: outcome must be home_win, draw or away_win", sc.MatchID)}
+		}
+	}
+	return out, nil
+}
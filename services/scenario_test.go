@@ -0,0 +1,85 @@
+package services
+
+import (
+	"testing"
+
+	"insider/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func scenarioFixture() []models.Match {
+	a := &models.Team{ID: 1, Name: "A", Attributes: models.TeamAttributes{Attack: 0.9, Defense: 0.9, Midfield: 0.9, HomeBoost: 0.1}}
+	b := &models.Team{ID: 2, Name: "B", Attributes: models.TeamAttributes{Attack: 0.3, Defense: 0.3, Midfield: 0.3, HomeBoost: 0.1}}
+	return []models.Match{
+		{ID: 10, Week: 1, HomeTeam: a, AwayTeam: b, Result: models.MatchResult{HomeScore: 1, AwayScore: 1}, IsPlayed: true},
+		{ID: 11, Week: 2, HomeTeam: b, AwayTeam: a},
+		{ID: 12, Week: 3, HomeTeam: a, AwayTeam: b},
+	}
+}
+
+func TestApplyScenarios(t *testing.T) {
+	matches := scenarioFixture()
+	home, away := 0, 4
+
+	out, err := applyScenarios(matches, []models.Scenario{
+		{MatchID: 11, HomeScore: &home, AwayScore: &away},
+		{MatchID: 12, Outcome: models.OutcomeAwayWin},
+	})
+	assert.NoError(t, err)
+
+	assert.True(t, out[1].IsPlayed)
+	assert.Equal(t, models.MatchResult{HomeScore: 0, AwayScore: 4}, out[1].Result)
+	assert.False(t, out[2].IsPlayed)
+	assert.Equal(t, models.OutcomeAwayWin, out[2].Outcome)
+
+	// The stored matches are left alone
+	assert.False(t, matches[1].IsPlayed)
+	assert.Empty(t, matches[2].Outcome)
+}
+
+func TestApplyScenarios_Invalid(t *testing.T) {
+	zero, negative := 0, -1
+
+	_, err := applyScenarios(scenarioFixture(), []models.Scenario{{MatchID: 99, Outcome: models.OutcomeDraw}})
+	assert.ErrorIs(t, err, ErrMatchNotFound)
+
+	cases := map[string][]models.Scenario{
+		"already played": {{MatchID: 10, Outcome: models.OutcomeDraw}},
+		"duplicate":      {{MatchID: 11, Outcome: models.OutcomeDraw}, {MatchID: 11, Outcome: models.OutcomeHomeWin}},
+		"score and outcome": {
+			{MatchID: 11, HomeScore: &zero, AwayScore: &zero, Outcome: models.OutcomeDraw},
+		},
+		"one score":      {{MatchID: 11, HomeScore: &zero}},
+		"negative score": {{MatchID: 11, HomeScore: &negative, AwayScore: &zero}},
+		"bad outcome":    {{MatchID: 11, Outcome: "walkover"}},
+		"empty":          {{MatchID: 11}},
+	}
+	for name, scenarios := range cases {
+		_, err := applyScenarios(scenarioFixture(), scenarios)
+		var validationErr *ValidationError
+		assert.ErrorAs(t, err, &validationErr, name)
+	}
+}
+
+func TestRandomizedPredictor_HonoursOutcomeConstraints(t *testing.T) {
+	matches, err := applyScenarios(scenarioFixture(), []models.Scenario{
+		{MatchID: 11, Outcome: models.OutcomeHomeWin},
+		{MatchID: 12, Outcome: models.OutcomeAwayWin},
+	})
+	assert.NoError(t, err)
+
+	teams := []models.Team{*matches[0].HomeTeam, *matches[0].AwayTeam}
+	table := NewLeagueTable(teams).CalculateTable(matches)
+	predictor := NewLeaguePredictor(NewMatchSimulator(), nil).WithSeed(7).WithIterations(1000)
+	projections := predictor.PredictStandings(table, matches[1:])
+
+	// The much weaker B wins both remaining matches, so it always finishes first on 7 points
+	for _, proj := range projections {
+		if proj.TeamName == "B" {
+			assert.Equal(t, 1.0, proj.PositionProbabilities[0])
+			assert.Equal(t, 7, proj.PointsDistribution.Min)
+			assert.Equal(t, 7, proj.PointsDistribution.Max)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"insider/database"
+	"insider/models"
+)
+
+// How many entries each all-time record lists
+const recordsListed int = 5
+
+// archiveSeason stores the final table and results of the season in the history of the league. A season without
+// a single played match is not worth keeping and is skipped, as reported by the returned flag.
+func (ls *BasicLeagueService) archiveSeason(tx database.Database, state *models.SimulationState) (bool, error) {
+	matches, err := tx.GetMatches()
+	if err != nil {
+		return false, err
+	}
+
+	played := false
+	for _, match := range matches {
+		played = played || match.IsPlayed
+	}
+	if !played {
+		return false, nil
+	}
+
+	deductions, err := tx.GetDeductions()
+	if err != nil {
+		return false, err
+	}
+
+	// The teams come from the schedule rather than the roster, which a restart may have changed already
+	leagueTable := NewLeagueTable(scheduledTeams(matches)).WithRules(ls.table.Rules()).WithZones(ls.table.Zones()).
+		WithSeed(deriveSeed(state.Seed, seedStreamLots)).WithDeductions(deductions)
+	table := leagueTable.CalculateTable(matches)
+
+	season := models.Season{
+		Number:     state.Season,
+		Seed:       state.Seed,
+		MaxWeeks:   state.MaxWeeks,
+		Completed:  state.CurrentWeek > state.MaxWeeks,
+		ArchivedAt: time.Now().UTC(),
+		Table:      table,
+		Matches:    matches,
+	}
+	if season.Completed {
+		champion := table[0].Team
+		season.Champion = &champion
+	}
+	return true, tx.SaveSeason(season)
+}
+
+// syncArchive keeps the history in step with the season after a change: a completed season is archived again
+// with its latest results, and one reopened by a clear, rewind or undo is taken out of it until it ends again
+func (ls *BasicLeagueService) syncArchive(tx database.Database, state *models.SimulationState) error {
+	if state.CurrentWeek > state.MaxWeeks {
+		_, err := ls.archiveSeason(tx, state)
+		return err
+	}
+	return tx.DeleteSeason(state.Season)
+}
+
+func scheduledTeams(matches []models.Match) []models.Team {
+	seen := make(map[int]bool)
+	var teams []models.Team
+	for _, match := range matches {
+		for _, team := range []*models.Team{match.HomeTeam, match.AwayTeam} {
+			if !seen[team.ID] {
+				seen[team.ID] = true
+				teams = append(teams, *team)
+			}
+		}
+	}
+	return teams
+}
+
+// GetSeasons lists the archived seasons of the league, oldest first, without their tables and results
+func (ls *BasicLeagueService) GetSeasons() ([]models.Season, error) {
+	seasons, err := ls.db.GetSeasons()
+	if err != nil {
+		return nil, err
+	}
+	for i := range seasons {
+		seasons[i].Table = nil
+		seasons[i].Matches = nil
+	}
+	return seasons, nil
+}
+
+func (ls *BasicLeagueService) GetSeason(number int) (*models.Season, error) {
+	season, err := ls.db.GetSeason(number)
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, ErrSeasonNotFound
+	}
+	return season, err
+}
+
+func (ls *BasicLeagueService) GetRecords() (*models.LeagueRecords, error) {
+	seasons, err := ls.db.GetSeasons()
+	if err != nil {
+		return nil, err
+	}
+	return AllTimeRecords(seasons), nil
+}
+
+// AllTimeRecords works out the all-time records from the archived seasons. Only completed seasons count, so an
+// unfinished season cannot hold a points record its teams did not have the matches to challenge.
+func AllTimeRecords(seasons []models.Season) *models.LeagueRecords {
+	records := &models.LeagueRecords{
+		Titles:       make([]models.TitleCount, 0),
+		BiggestWins:  make([]models.RecordWin, 0),
+		MostPoints:   make([]models.PointsRecord, 0),
+		FewestPoints: make([]models.PointsRecord, 0),
+	}
+
+	titles := make(map[int]int) // team ID -> index in records.Titles
+	var points []models.PointsRecord
+	for _, season := range seasons {
+		if !season.Completed {
+			continue
+		}
+		records.Seasons++
+
+		if champion := season.Champion; champion != nil {
+			i, ok := titles[champion.ID]
+			if !ok {
+				i = len(records.Titles)
+				titles[champion.ID] = i
+				records.Titles = append(records.Titles, models.TitleCount{TeamID: champion.ID, TeamName: champion.Name})
+			}
+			records.Titles[i].Titles++
+			records.Titles[i].Seasons = append(records.Titles[i].Seasons, season.Number)
+		}
+
+		for _, match := range season.Matches {
+			if match.IsPlayed && !match.Result.IsDraw() {
+				records.BiggestWins = append(records.BiggestWins, models.RecordWin{Season: season.Number, Match: match})
+			}
+		}
+
+		for _, e := range season.Table {
+			points = append(points, models.PointsRecord{
+				Season:   season.Number,
+				TeamID:   e.Team.ID,
+				TeamName: e.Team.Name,
+				Points:   e.Points,
+				Played:   e.Played,
+			})
+		}
+	}
+
+	// Ties go to whoever got there first
+	sort.SliceStable(records.Titles, func(i, j int) bool {
+		return records.Titles[i].Titles > records.Titles[j].Titles
+	})
+
+	// By margin, then by goals scored by the winner
+	sort.SliceStable(records.BiggestWins, func(i, j int) bool {
+		a, b := records.BiggestWins[i].Match.Result, records.BiggestWins[j].Match.Result
+		if winMargin(a) != winMargin(b) {
+			return winMargin(a) > winMargin(b)
+		}
+		return max(a.HomeScore, a.AwayScore) > max(b.HomeScore, b.AwayScore)
+	})
+	records.BiggestWins = records.BiggestWins[:min(len(records.BiggestWins), recordsListed)]
+
+	sort.SliceStable(points, func(i, j int) bool { return points[i].Points > points[j].Points })
+	records.MostPoints = append(records.MostPoints, points[:min(len(points), recordsListed)]...)
+
+	sort.SliceStable(points, func(i, j int) bool { return points[i].Points < points[j].Points })
+	records.FewestPoints = append(records.FewestPoints, points[:min(len(points), recordsListed)]...)
+	return records
+}
+
+func winMargin(result models.MatchResult) int {
+	if result.HomeScore > result.AwayScore {
+		return result.HomeScore - result.AwayScore
+	}
+	return result.AwayScore - result.HomeScore
+}
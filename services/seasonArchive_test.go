@@ -0,0 +1,64 @@
+package services
+
+import (
+	"testing"
+
+	"insider/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func archivedSeason(number int, completed bool, champion *models.Team, table []models.LeagueTableEntry,
+	results ...models.MatchResult) models.Season {
+	a := &models.Team{ID: 1, Name: "A"}
+	b := &models.Team{ID: 2, Name: "B"}
+	season := models.Season{Number: number, Completed: completed, Champion: champion, Table: table}
+	for i, result := range results {
+		season.Matches = append(season.Matches, models.Match{ID: number*10 + i, Week: i + 1, HomeTeam: a, AwayTeam: b,
+			Result: result, IsPlayed: true})
+	}
+	return season
+}
+
+func TestAllTimeRecords(t *testing.T) {
+	a := models.Team{ID: 1, Name: "A"}
+	b := models.Team{ID: 2, Name: "B"}
+	table := func(aPoints, bPoints int) []models.LeagueTableEntry {
+		return []models.LeagueTableEntry{{Team: a, Points: aPoints, Played: 2}, {Team: b, Points: bPoints, Played: 2}}
+	}
+
+	records := AllTimeRecords([]models.Season{
+		archivedSeason(1, true, &b, table(1, 4), models.MatchResult{HomeScore: 1, AwayScore: 1},
+			models.MatchResult{HomeScore: 0, AwayScore: 3}),
+		archivedSeason(2, true, &a, table(6, 0), models.MatchResult{HomeScore: 4, AwayScore: 1},
+			models.MatchResult{HomeScore: 2, AwayScore: 0}),
+		archivedSeason(3, true, &a, table(4, 1), models.MatchResult{HomeScore: 5, AwayScore: 2}),
+		// Unfinished, so its 9-0 and its points do not count
+		archivedSeason(4, false, nil, table(3, 0), models.MatchResult{HomeScore: 9, AwayScore: 0}),
+	})
+
+	assert.Equal(t, 3, records.Seasons)
+	assert.Equal(t, []models.TitleCount{
+		{TeamID: 1, TeamName: "A", Titles: 2, Seasons: []int{2, 3}},
+		{TeamID: 2, TeamName: "B", Titles: 1, Seasons: []int{1}},
+	}, records.Titles)
+
+	// Level on margin, the 5-2 beats the 4-1 and the 3-0 on goals, and draws are never wins
+	var wins []int
+	for _, w := range records.BiggestWins {
+		wins = append(wins, w.Match.ID)
+	}
+	assert.Equal(t, []int{30, 20, 11, 21}, wins)
+
+	assert.Len(t, records.MostPoints, 5)
+	assert.Equal(t, models.PointsRecord{Season: 2, TeamID: 1, TeamName: "A", Points: 6, Played: 2}, records.MostPoints[0])
+	assert.Equal(t, models.PointsRecord{Season: 2, TeamID: 2, TeamName: "B", Points: 0, Played: 2}, records.FewestPoints[0])
+}
+
+func TestAllTimeRecords_Empty(t *testing.T) {
+	records := AllTimeRecords(nil)
+	assert.Equal(t, 0, records.Seasons)
+	assert.Empty(t, records.Titles)
+	assert.NotNil(t, records.BiggestWins)
+	assert.NotNil(t, records.MostPoints)
+}
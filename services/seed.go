@@ -0,0 +1,51 @@
+package services
+
+import (
+	"log"
+	"math/rand"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Streams keep the randomness used by each part of a season independent of the others,
+// so e.g. reading the odds never changes what the next simulated week produces
+const (
+	seedStreamSchedule int64 = iota + 1
+	seedStreamMatch
+	seedStreamOdds
+	seedStreamLots
+	seedStreamPlayoff
+	seedStreamNextSeason
+)
+
+// NewSeed returns the seed for a new season.
+// SIMULATION_SEED is used when set, so every reset replays the same season; otherwise the seed is time based.
+func NewSeed() int64 {
+	if value, ok := os.LookupEnv("SIMULATION_SEED"); ok && value != "" {
+		seed, err := strconv.ParseInt(value, 10, 64)
+		if err == nil {
+			return seed
+		}
+		log.Printf("Ignoring invalid SIMULATION_SEED %q: %v", value, err)
+	}
+	return time.Now().UnixNano()
+}
+
+func newRandom(seed int64) *rand.Rand {
+	return rand.New(rand.NewSource(seed))
+}
+
+// deriveSeed mixes the season seed with the given keys (splitmix64 finalizer)
+func deriveSeed(seed int64, keys ...int64) int64 {
+	x := uint64(seed)
+	for _, key := range keys {
+		x ^= uint64(key) + 0x9e3779b97f4a7c15 + (x << 6) + (x >> 2)
+		x ^= x >> 30
+		x *= 0xbf58476d1ce4e5b9
+		x ^= x >> 27
+		x *= 0x94d049bb133111eb
+		x ^= x >> 31
+	}
+	return int64(x)
+}
@@ -5,28 +5,187 @@ import "insider/models"
 // MatchSimulator defines the interface for simulating match results
 type MatchSimulator interface {
 	SimulateMatch(homeTeam, awayTeam models.Team) models.MatchResult
+	// PredictMatch returns the exact probabilities of the scorelines SimulateMatch plays, and what they add up to
+	PredictMatch(homeTeam, awayTeam models.Team) models.MatchPrediction
+	// WithSeed returns an independent copy of the simulator drawing from the given seed
+	WithSeed(seed int64) MatchSimulator
+}
+
+// RatedMatchSimulator is a MatchSimulator playing teams by their ratings rather than by their attributes alone
+type RatedMatchSimulator interface {
+	MatchSimulator
+	// WithRatings returns a copy of the simulator playing from the given ratings, by team ID
+	WithRatings(ratings map[int]float64) MatchSimulator
 }
 
 // LeagueTable defines the interface for calculating league tables
 type LeagueTable interface {
 	CalculateTable(matches []models.Match) []models.LeagueTableEntry
+	// Rank orders entries by points and the league's tiebreakers and assigns positions.
+	// matches provide the results the head-to-head tiebreakers look at.
+	Rank(table []models.LeagueTableEntry, matches []models.Match)
+	// Rules returns the rules results are scored and ranked by
+	Rules() models.LeagueRules
+	// WithRules returns a copy of the table scoring and ranking by the given rules
+	WithRules(rules models.LeagueRules) LeagueTable
+	// WithDeductions returns a copy of the table taking the given points off
+	WithDeductions(deductions []models.PointDeduction) LeagueTable
+	// WithSeed returns a copy of the table drawing lots with the given seed
+	WithSeed(seed int64) LeagueTable
+	// Zones returns the places marked as leading out of the division
+	Zones() models.TableZones
+	// WithZones returns a copy of the table marking the given promotion, playoff and relegation places
+	WithZones(zones models.TableZones) LeagueTable
 }
 
 // LeaguePredictor defines the interface for predicting the championship odds for teams
 type LeaguePredictor interface {
-	CalculateChampionshipOdds(table []models.LeagueTableEntry, remaining []models.Match) []models.ChampionshipOdds
+	CalculateChampionshipOdds(table []models.LeagueTableEntry, matches []models.Match) []models.ChampionshipOdds
+	// PredictStandings simulates the unplayed matches and returns, per team in table order, the probability
+	// of each finishing position, the expected final points and goal difference, and the distribution of
+	// final points. Played matches are only read by head-to-head tiebreakers.
+	PredictStandings(table []models.LeagueTableEntry, matches []models.Match) []models.TeamProjection
+	// WithSeed returns an independent copy of the predictor drawing from the given seed
+	WithSeed(seed int64) LeaguePredictor
+	// WithIterations returns a copy of the predictor running the given number of simulations
+	WithIterations(iterations int) LeaguePredictor
+	// WithTable returns a copy of the predictor ranking simulated seasons like the given table
+	WithTable(table LeagueTable) LeaguePredictor
+	// WithSimulator returns a copy of the predictor playing the remaining matches with the given simulator
+	WithSimulator(simulator MatchSimulator) LeaguePredictor
+}
+
+// OddsGenerator prices predicted outcomes as a bookmaker would, shortening the fair odds by a margin
+type OddsGenerator interface {
+	// MatchMarkets prices the result, correct score and total goals of a match from a prediction with its
+	// scoreline grid
+	MatchMarkets(prediction models.MatchPrediction) []models.Market
+	// OutrightMarket prices the title from the championship odds, leaving the teams eliminated in race unpriced
+	OutrightMarket(odds []models.ChampionshipOdds, race []models.TitleStatus) models.Market
+	// Margin is how much the implied probabilities of a market add up to over 1
+	Margin() float64
+	// WithMargin returns a copy of the generator pricing with the given margin
+	WithMargin(margin float64) OddsGenerator
 }
 
 // MatchScheduler defines the interface for generating match schedules
 type MatchScheduler interface {
 	GenerateSchedule(teams []models.Team) []models.Match
+	// WithSeed returns an independent copy of the scheduler drawing from the given seed
+	WithSeed(seed int64) MatchScheduler
+}
+
+// LeagueManager defines the interface for creating, listing and addressing independent leagues
+type LeagueManager interface {
+	GetLeagues() ([]models.League, error)
+	GetLeague(leagueID int) (LeagueService, error)
+	// GetMatchLeague returns the league a match is played in, match IDs being unique across leagues
+	GetMatchLeague(matchID int) (LeagueService, error)
+	CreateLeague(name string, teamIDs []int, seed int64, rules models.LeagueRules) (*models.League, error)
+	DeleteLeague(leagueID int) error
+	// SetLeagueTeams replaces the roster of a league and regenerates its schedule.
+	// A season with played matches is only restarted when restart is set.
+	SetLeagueTeams(leagueID int, teamIDs []int, restart bool) (*models.League, error)
+	// SetLeagueRules changes how a league ranks its table, keeping its results. Like the changes of a
+	// LeagueService, it fails with ErrStaleState unless the league is at expectedWeek, 0 accepting any.
+	SetLeagueRules(leagueID int, rules models.LeagueRules, expectedWeek int) (*models.League, error)
+	// ReloadLeagues drops the cached league services so team changes are picked up on next use
+	ReloadLeagues()
+
+	GetPyramids() ([]models.Pyramid, error)
+	GetPyramid(pyramidID int) (*models.Pyramid, error)
+	// CreatePyramid links leagues as divisions, listed from the top down. places teams swap between each two
+	// neighbouring divisions at the end of a season, the last promotion place going to a playoff if asked.
+	CreatePyramid(name string, leagueIDs []int, places int, playoffs bool) (*models.Pyramid, error)
+	DeletePyramid(pyramidID int) error
+	// StartNextSeason promotes and relegates teams once every division of the pyramid has finished its
+	// season, and starts the next season of each with its new roster
+	StartNextSeason(pyramidID int) (*models.PyramidSeason, error)
+}
+
+// TeamService defines the interface for managing the teams leagues are built from
+type TeamService interface {
+	GetTeams() ([]models.Team, error)
+	GetTeam(teamID int) (*models.Team, error)
+	CreateTeam(team models.Team) (*models.Team, error)
+	UpdateTeam(team models.Team) (*models.Team, error)
+	DeleteTeam(teamID int) error
+	// Calibrate fits the attack, defense and home boost of the teams to historical results and, unless dryRun
+	// is set, writes them to the teams
+	Calibrate(entries []models.ResultEntry, dryRun bool) (*models.Calibration, error)
+}
+
+// StateOptions tunes how a league state is computed, zero values use the defaults
+type StateOptions struct {
+	// Iterations of the Monte Carlo run behind the odds, trading accuracy for latency
+	Iterations int
+	// Week returns the state as it stood at the end of that completed week instead of the current one
+	Week int
+}
+
+// MarketOptions tunes how the odds of a league are priced, zero values use the defaults
+type MarketOptions struct {
+	// Iterations of the Monte Carlo run behind the outright market
+	Iterations int
+	// Margin overrides the margin of the generator when set
+	Margin *float64
+}
+
+// EventQuery selects a page of the audit log, zero values use the defaults
+type EventQuery struct {
+	// Limit is the size of the page, 50 by default and at most 500
+	Limit  int
+	Offset int
+	// MatchID only returns the events of that match
+	MatchID int
 }
 
 // LeagueService defines the main service interface
 type LeagueService interface {
-	GetCurrentState() (*models.LeagueSimulation, error)
+	GetCurrentState(options StateOptions) (*models.LeagueSimulation, error)
+	// PredictScenario returns the state as if the scenarios had happened, without persisting them
+	PredictScenario(scenarios []models.Scenario, options StateOptions) (*models.LeagueSimulation, error)
 	SimulateNextWeek() (*models.WeekSimulation, error)
 	SimulateRemainingWeeks() (*models.LeagueSimulation, error)
-	ResetSimulation() error
-	UpdateMatchResult(matchID int, homeScore, awayScore int) error
+	// SimulateUntilWeek plays every week up to and including week
+	SimulateUntilWeek(week int) (*models.LeagueSimulation, error)
+	// SimulateMatch plays a single match up to the current week, which ends once all of them are played
+	SimulateMatch(matchID int) (*models.Match, error)
+	// ResetSimulation starts a new season from seed, archiving the current one if any of it was played
+	ResetSimulation(seed int64) error
+	// UpdateMatchResult sets the score, and the shootout of a draw, of a match up to the current week. Scoring
+	// the last open match up to the current week ends it, as simulating it would.
+	UpdateMatchResult(matchID int, result models.MatchResult) error
+	// UpdateMatchResults sets the scores of a batch of matches at once. Nothing is applied unless every
+	// row is valid, see BatchError.
+	UpdateMatchResults(entries []models.ResultEntry) error
+	// ClearMatchResult sets a played match back to unplayed, to be played again with the next week
+	ClearMatchResult(matchID int) error
+	// RewindToWeek clears every result after week and makes the week after it the current one again
+	RewindToWeek(week int) error
+	// Undo reverts the last simulated week or weeks or match, result edit, import or clear, or rewind. Only one
+	// step is kept.
+	Undo() error
+	GetDeductions() ([]models.PointDeduction, error)
+	// AddDeduction takes points off a team of the league for the rest of the season
+	AddDeduction(deduction models.PointDeduction) (*models.PointDeduction, error)
+	RemoveDeduction(deductionID int) error
+	// GetEvents pages through the audit log of every result and week change, newest first
+	GetEvents(query EventQuery) (*models.EventPage, error)
+	// GetSeasons lists the seasons in the history of the league, see models.Season
+	GetSeasons() ([]models.Season, error)
+	// GetSeason returns an archived season with its final table and results
+	GetSeason(number int) (*models.Season, error)
+	// GetRecords returns the all-time records of the league over its completed seasons
+	GetRecords() (*models.LeagueRecords, error)
+	// PredictMatches returns the exact outcome probabilities of every unplayed match, see MatchSimulator.PredictMatch
+	PredictMatches() ([]models.FixturePrediction, error)
+	// PredictMatch returns the exact outcome probabilities of an unplayed match with its full scoreline grid
+	PredictMatch(matchID int) (*models.FixturePrediction, error)
+	// GetRatings returns the current Elo ratings of the teams with their history over the season
+	GetRatings() ([]models.TeamRating, error)
+	// GetMarkets prices the unplayed matches and the title, see OddsGenerator
+	GetMarkets(options MarketOptions) (*models.OddsBoard, error)
+	// WithExpectedWeek returns a copy whose changes fail with ErrStaleState unless the league is still at week
+	WithExpectedWeek(week int) LeagueService
 }
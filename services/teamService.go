@@ -0,0 +1,137 @@
+package services
+
+import (
+	"errors"
+	"strings"
+
+	"insider/database"
+	"insider/models"
+)
+
+type BasicTeamService struct {
+	db      database.Database
+	leagues LeagueManager
+}
+
+func NewTeamService(db database.Database, leagues LeagueManager) TeamService {
+	return &BasicTeamService{
+		db:      db,
+		leagues: leagues,
+	}
+}
+
+func (ts *BasicTeamService) GetTeams() ([]models.Team, error) {
+	return ts.db.GetAllTeams()
+}
+
+func (ts *BasicTeamService) GetTeam(teamID int) (*models.Team, error) {
+	team, err := ts.db.GetTeam(teamID)
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, ErrTeamNotFound
+	}
+	return team, err
+}
+
+// CreateTeam adds a team. It does not join any league until it is added to a roster.
+func (ts *BasicTeamService) CreateTeam(team models.Team) (*models.Team, error) {
+	team.ID = 0
+	if err := ts.validateTeam(&team); err != nil {
+		return nil, err
+	}
+
+	teamID, err := ts.db.InsertTeam(team)
+	if err != nil {
+		return nil, err
+	}
+	return ts.GetTeam(teamID)
+}
+
+// UpdateTeam replaces the name, attributes and play style of a team.
+// Played results stay as they are, the next simulated matches and odds of every league use the new values.
+func (ts *BasicTeamService) UpdateTeam(team models.Team) (*models.Team, error) {
+	if _, err := ts.GetTeam(team.ID); err != nil {
+		return nil, err
+	}
+
+	if err := ts.validateTeam(&team); err != nil {
+		return nil, err
+	}
+
+	if err := ts.db.UpdateTeam(team); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return nil, ErrTeamNotFound
+		}
+		return nil, err
+	}
+
+	ts.leagues.ReloadLeagues()
+	return ts.GetTeam(team.ID)
+}
+
+// DeleteTeam removes a team that is not part of any league
+func (ts *BasicTeamService) DeleteTeam(teamID int) error {
+	if _, err := ts.GetTeam(teamID); err != nil {
+		return err
+	}
+
+	leagues, err := ts.db.GetLeagues()
+	if err != nil {
+		return err
+	}
+	for _, league := range leagues {
+		for _, team := range league.Teams {
+			if team.ID == teamID {
+				return ErrTeamInUse
+			}
+		}
+	}
+
+	if err := ts.db.DeleteTeam(teamID); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return ErrTeamNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (ts *BasicTeamService) validateTeam(team *models.Team) error {
+	team.Name = strings.TrimSpace(team.Name)
+	if team.Name == "" {
+		return &ValidationError{Message: "team name is required"}
+	}
+
+	if team.PlayStyle == "" {
+		team.PlayStyle = models.PlayStyleBalanced
+	}
+	if !team.PlayStyle.IsValid() {
+		return &ValidationError{Message: "play_style must be one of attacking, defensive, possession, balanced"}
+	}
+
+	attrs := team.Attributes
+	ratings := []struct {
+		name  string
+		value float64
+	}{
+		{"attack", attrs.Attack},
+		{"defense", attrs.Defense},
+		{"midfield", attrs.Midfield},
+		{"home_boost", attrs.HomeBoost},
+	}
+	for _, rating := range ratings {
+		if rating.value < 0 || rating.value > 1 {
+			return &ValidationError{Message: rating.name + " must be between 0 and 1"}
+		}
+	}
+
+	teams, err := ts.db.GetAllTeams()
+	if err != nil {
+		return err
+	}
+	for _, other := range teams {
+		if other.ID != team.ID && strings.EqualFold(other.Name, team.Name) {
+			return &ValidationError{Message: "a team named " + team.Name + " already exists"}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,316 @@
+package services
+
+import (
+	"sort"
+
+	"insider/models"
+)
+
+// Elimination under 3/1/0 scoring is NP-hard in general, so the search is capped.
+// A team whose search runs out of budget is reported as still in the race.
+const titleRaceSearchBudget int = 100_000
+
+// AnalyzeTitleRace works out from the remaining fixtures which teams have mathematically won the title,
+// which can no longer win it and how many more points each needs to be certain under the points system.
+// Finishing level on points counts as still in the race, since tiebreakers are undecided until the end.
+func AnalyzeTitleRace(table []models.LeagueTableEntry, remaining []models.Match, system models.PointsSystem) []models.TitleStatus {
+	out := make([]models.TitleStatus, 0, len(table))
+
+	if len(remaining) == 0 {
+		// Every tiebreaker is settled too, so the table is final
+		for _, e := range table {
+			out = append(out, models.TitleStatus{
+				TeamID:      e.Team.ID,
+				TeamName:    e.Team.Name,
+				Points:      e.Points,
+				MaxPoints:   e.Points,
+				Clinched:    e.Position == 1,
+				Eliminated:  e.Position != 1,
+				MagicNumber: magicNumber(0, e.Position == 1),
+			})
+		}
+		return out
+	}
+
+	outcomes := matchOutcomes(system)
+	best := 0 // the most points a side can take from one match
+	for _, o := range outcomes {
+		best = max(best, o[0])
+	}
+
+	points := make(map[int]int, len(table))
+	left := make(map[int]int, len(table))
+	for _, e := range table {
+		points[e.Team.ID] = e.Points
+	}
+	for _, m := range remaining {
+		left[m.HomeTeam.ID]++
+		left[m.AwayTeam.ID]++
+	}
+
+	for _, e := range table {
+		id := e.Team.ID
+		status := models.TitleStatus{
+			TeamID:    id,
+			TeamName:  e.Team.Name,
+			Points:    points[id],
+			MaxPoints: points[id] + best*left[id],
+		}
+
+		status.Clinched = true
+		for _, other := range table {
+			if other.Team.ID != id && points[other.Team.ID]+best*left[other.Team.ID] >= points[id] {
+				status.Clinched = false
+				break
+			}
+		}
+
+		if !status.Clinched {
+			status.Eliminated = isEliminated(id, status.MaxPoints, points, remaining, outcomes)
+		}
+
+		if !status.Eliminated {
+			needed := 0
+			for _, other := range table {
+				if other.Team.ID != id {
+					needed = max(needed, pointsNeededAgainst(id, other.Team.ID, points, left, remaining, outcomes))
+				}
+			}
+			status.MagicNumber = magicNumber(needed, needed <= best*left[id])
+		}
+
+		out = append(out, status)
+	}
+	return out
+}
+
+// matchOutcomes lists every split of points a single match can produce under the points system, as
+// (home, away) pairs. The list is symmetric, and a drawn match in a shootout league is always decided.
+func matchOutcomes(system models.PointsSystem) [][2]int {
+	// Scores past both bonus thresholds combined earn nothing new
+	limit := system.GoalsBonusThreshold + system.MarginBonusThreshold + 1
+
+	seen := make(map[[2]int]bool)
+	var out [][2]int
+	add := func(result models.MatchResult) {
+		home, away := matchPoints(result, system)
+		if !seen[[2]int{home, away}] {
+			seen[[2]int{home, away}] = true
+			out = append(out, [2]int{home, away})
+		}
+	}
+
+	for hs := 0; hs <= limit; hs++ {
+		for as := 0; as <= limit; as++ {
+			result := models.MatchResult{HomeScore: hs, AwayScore: as}
+			if result.IsDraw() && system.Shootout {
+				result.HomePenalties = 1
+				add(result)
+				result.HomePenalties, result.AwayPenalties = 0, 1
+			}
+			add(result)
+		}
+	}
+
+	// Best for the home side first
+	sort.Slice(out, func(i, j int) bool {
+		if out[i][0] != out[j][0] {
+			return out[i][0] > out[j][0]
+		}
+		return out[i][1] < out[j][1]
+	})
+	return out
+}
+
+func magicNumber(needed int, reachable bool) *int {
+	if !reachable {
+		return nil
+	}
+	return &needed
+}
+
+// pointsNeededAgainst returns the fewest points team must still earn to finish above rival whatever
+// else happens. The worst case has the rival take the most points from everything else, so only their
+// direct meetings and the points team can earn elsewhere need to be enumerated.
+func pointsNeededAgainst(team, rival int, points, left map[int]int, remaining []models.Match, outcomes [][2]int) int {
+	meetings := 0
+	for _, m := range remaining {
+		if (m.HomeTeam.ID == team && m.AwayTeam.ID == rival) || (m.HomeTeam.ID == rival && m.AwayTeam.ID == team) {
+			meetings++
+		}
+	}
+
+	// Points team can earn from its other matches, one outcome per match
+	best := 0
+	sides := make(map[int]bool)
+	for _, o := range outcomes {
+		sides[o[0]] = true
+		best = max(best, o[0])
+	}
+
+	elsewhere := map[int]bool{0: true}
+	for range left[team] - meetings {
+		next := make(map[int]bool)
+		for total := range elsewhere {
+			for side := range sides {
+				next[total+side] = true
+			}
+		}
+		elsewhere = next
+	}
+
+	rivalBase := points[rival] + best*(left[rival]-meetings)
+
+	// The largest number of points team can earn and still not finish above the rival
+	worst := -1
+	var meet func(n, teamPts, rivalPts int)
+	meet = func(n, teamPts, rivalPts int) {
+		if n == meetings {
+			for pts := range elsewhere {
+				earned := pts + teamPts
+				if points[team]+earned <= rivalBase+rivalPts {
+					worst = max(worst, earned)
+				}
+			}
+			return
+		}
+		// The outcomes are symmetric, so who hosts does not matter
+		for _, o := range outcomes {
+			meet(n+1, teamPts+o[0], rivalPts+o[1])
+		}
+	}
+	meet(0, 0, 0)
+
+	return worst + 1
+}
+
+// isEliminated reports whether team can no longer reach the top even by taking the most points from
+// every remaining match, i.e. whether no outcome of the other fixtures keeps every rival on at most target points
+func isEliminated(team, target int, points map[int]int, remaining []models.Match, outcomes [][2]int) bool {
+	// What an opponent still takes when team gets the most out of their match, e.g. a losing bonus
+	best, conceded := outcomes[0][0], outcomes[0][1]
+	for _, o := range outcomes {
+		if o[0] > best || (o[0] == best && o[1] < conceded) {
+			best, conceded = o[0], o[1]
+		}
+	}
+
+	capacity := make(map[int]int, len(points))
+	for id, pts := range points {
+		if id != team {
+			capacity[id] = target - pts
+		}
+	}
+
+	var fixtures [][2]int
+	for _, m := range remaining {
+		switch team {
+		case m.HomeTeam.ID:
+			capacity[m.AwayTeam.ID] -= conceded
+		case m.AwayTeam.ID:
+			capacity[m.HomeTeam.ID] -= conceded
+		default:
+			fixtures = append(fixtures, [2]int{m.HomeTeam.ID, m.AwayTeam.ID})
+		}
+	}
+
+	for _, c := range capacity {
+		if c < 0 {
+			return true
+		}
+	}
+
+	// Deciding the most constrained teams first finds contradictions early
+	sort.SliceStable(fixtures, func(i, j int) bool {
+		a, b := fixtures[i], fixtures[j]
+		return min(capacity[a[0]], capacity[a[1]]) < min(capacity[b[0]], capacity[b[1]])
+	})
+
+	least := outcomes[0][0] + outcomes[0][1]
+	for _, o := range outcomes {
+		least = min(least, o[0]+o[1])
+	}
+
+	search := &eliminationSearch{
+		capacity: capacity,
+		fixtures: fixtures,
+		outcomes: outcomes,
+		best:     best,
+		least:    least,
+		left:     make(map[int]int, len(capacity)),
+		budget:   titleRaceSearchBudget,
+	}
+	for _, f := range fixtures {
+		search.left[f[0]]++
+		search.left[f[1]]++
+	}
+
+	feasible := search.run(0)
+	return !feasible && !search.exhausted
+}
+
+type eliminationSearch struct {
+	capacity  map[int]int // points each rival may still earn
+	fixtures  [][2]int
+	outcomes  [][2]int    // point splits of a match, best for the home side first
+	best      int         // the most points a side can take from one match
+	least     int         // the fewest points a match hands out in total
+	left      map[int]int // undecided fixtures per rival
+	budget    int
+	exhausted bool
+}
+
+// run tries to decide fixtures[n:] without any rival going over its capacity
+func (s *eliminationSearch) run(n int) bool {
+	if n == len(s.fixtures) {
+		return true
+	}
+	if s.budget == 0 {
+		s.exhausted = true
+		return false
+	}
+	s.budget--
+
+	// Every match hands out some points, so the rivals must be able to absorb them
+	absorb := 0
+	for id, c := range s.capacity {
+		absorb += min(c, s.best*s.left[id])
+	}
+	if absorb < s.least*(len(s.fixtures)-n) {
+		return false
+	}
+
+	home, away := s.fixtures[n][0], s.fixtures[n][1]
+	s.left[home]--
+	s.left[away]--
+	defer func() {
+		s.left[home]++
+		s.left[away]++
+	}()
+
+	// Try handing the most points to whichever side has more room first
+	for i := range s.outcomes {
+		o := s.outcomes[i]
+		if s.capacity[away] > s.capacity[home] {
+			o = s.outcomes[len(s.outcomes)-1-i]
+		}
+
+		if s.capacity[home] < o[0] || s.capacity[away] < o[1] {
+			continue
+		}
+
+		s.capacity[home] -= o[0]
+		s.capacity[away] -= o[1]
+		ok := s.run(n + 1)
+		s.capacity[home] += o[0]
+		s.capacity[away] += o[1]
+
+		if ok {
+			return true
+		}
+		if s.exhausted {
+			return false
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"testing"
+
+	"insider/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func titleRaceFixture(points map[string]int, fixtures [][2]string) ([]models.LeagueTableEntry, []models.Match) {
+	teams := make(map[string]*models.Team)
+	var table []models.LeagueTableEntry
+	for i, name := range []string{"A", "B", "C", "D"} {
+		if _, ok := points[name]; !ok {
+			continue
+		}
+		teams[name] = &models.Team{ID: i + 1, Name: name}
+		table = append(table, models.LeagueTableEntry{Team: *teams[name], Points: points[name]})
+	}
+	rankStandings(table, nil, models.LeagueRules{}.WithDefaults(), 0)
+
+	var remaining []models.Match
+	for _, f := range fixtures {
+		remaining = append(remaining, models.Match{HomeTeam: teams[f[0]], AwayTeam: teams[f[1]]})
+	}
+	return table, remaining
+}
+
+func statusByName(statuses []models.TitleStatus) map[string]models.TitleStatus {
+	out := make(map[string]models.TitleStatus)
+	for _, s := range statuses {
+		out[s.TeamName] = s
+	}
+	return out
+}
+
+func TestAnalyzeTitleRace_Clinched(t *testing.T) {
+	table, remaining := titleRaceFixture(
+		map[string]int{"A": 10, "B": 3, "C": 2},
+		[][2]string{{"B", "C"}},
+	)
+
+	race := statusByName(AnalyzeTitleRace(table, remaining, models.DefaultPointsSystem))
+
+	assert.True(t, race["A"].Clinched)
+	assert.Equal(t, 0, *race["A"].MagicNumber)
+	assert.True(t, race["B"].Eliminated)
+	assert.True(t, race["C"].Eliminated)
+	assert.Nil(t, race["B"].MagicNumber)
+}
+
+func TestAnalyzeTitleRace_EliminatedByRemainingFixtures(t *testing.T) {
+	// D can still reach 10 points, but A and B meet, so one of them must pass 10
+	table, remaining := titleRaceFixture(
+		map[string]int{"A": 10, "B": 10, "C": 3, "D": 7},
+		[][2]string{{"A", "B"}, {"D", "C"}},
+	)
+
+	race := statusByName(AnalyzeTitleRace(table, remaining, models.DefaultPointsSystem))
+
+	assert.Equal(t, 10, race["D"].MaxPoints)
+	assert.True(t, race["D"].Eliminated, "D cannot stay level with both A and B")
+	assert.False(t, race["A"].Clinched)
+	assert.False(t, race["A"].Eliminated)
+	assert.Equal(t, 2, *race["A"].MagicNumber, "a draw with B is not enough, A needs the win")
+	assert.Equal(t, 2, *race["B"].MagicNumber)
+}
+
+func TestAnalyzeTitleRace_LevelOnPointsIsStillAlive(t *testing.T) {
+	table, remaining := titleRaceFixture(
+		map[string]int{"A": 10, "B": 7, "C": 0},
+		[][2]string{{"B", "C"}},
+	)
+
+	race := statusByName(AnalyzeTitleRace(table, remaining, models.DefaultPointsSystem))
+
+	assert.False(t, race["A"].Clinched, "B can still draw level on points")
+	assert.False(t, race["B"].Eliminated)
+	assert.Nil(t, race["B"].MagicNumber, "B cannot finish above A on its own")
+	assert.Nil(t, race["A"].MagicNumber, "A has no matches left, so only B dropping points decides it")
+}
+
+func TestAnalyzeTitleRace_SeasonOver(t *testing.T) {
+	table, _ := titleRaceFixture(map[string]int{"A": 6, "B": 6}, nil)
+	table[0].GoalsFor = 5 // tiebreakers are final once every match is played
+	rankStandings(table, nil, models.LeagueRules{}.WithDefaults(), 0)
+
+	race := statusByName(AnalyzeTitleRace(table, nil, models.DefaultPointsSystem))
+
+	assert.True(t, race["A"].Clinched)
+	assert.True(t, race["B"].Eliminated)
+}
+
+func TestMatchOutcomes(t *testing.T) {
+	assert.Equal(t, [][2]int{{3, 0}, {1, 1}, {0, 3}}, matchOutcomes(models.DefaultPointsSystem))
+
+	shootout := models.PointsSystem{Win: 3, Loss: 0, Shootout: true, ShootoutWin: 2, ShootoutLoss: 1}
+	assert.Equal(t, [][2]int{{3, 0}, {2, 1}, {1, 2}, {0, 3}}, matchOutcomes(shootout))
+}
+
+func TestAnalyzeTitleRace_PointsSystem(t *testing.T) {
+	table, remaining := titleRaceFixture(
+		map[string]int{"A": 10, "B": 5, "C": 0, "D": 0},
+		[][2]string{{"B", "C"}, {"D", "B"}},
+	)
+
+	// Two wins take B to 11 at three points a win, but only to 9 at two
+	race := statusByName(AnalyzeTitleRace(table, remaining, models.DefaultPointsSystem))
+	assert.False(t, race["A"].Clinched)
+	assert.Equal(t, 11, race["B"].MaxPoints)
+
+	race = statusByName(AnalyzeTitleRace(table, remaining, models.PointsSystem{Win: 2, Draw: 1}))
+	assert.True(t, race["A"].Clinched)
+	assert.True(t, race["B"].Eliminated)
+	assert.Equal(t, 9, race["B"].MaxPoints)
+}